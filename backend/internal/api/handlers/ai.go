@@ -1,11 +1,22 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"personal-website/internal/models"
 	"personal-website/internal/service/ai"
-	"personal-website/pkg/crypto"
+	"personal-website/internal/service/guard"
+	"personal-website/internal/service/moderation"
+	"personal-website/internal/service/prompt"
+	"personal-website/internal/service/rag"
+	"personal-website/internal/service/sitetools"
+	"personal-website/internal/service/usage"
+	"personal-website/pkg/ratelimit"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,26 +24,76 @@ import (
 	"gorm.io/gorm"
 )
 
+// maxHistoryMessages 每次最多发送的历史消息条数
+// 实际发送的条数由上下文Token预算决定
+const maxHistoryMessages = 200
+
+// visionUnsupportedMessage 可用的模型都不支持图片输入时返回给访客的提示
+const visionUnsupportedMessage = "当前模型不支持图片，请更换支持图片的模型或移除图片后重试"
+
+// maxMessageLength 访客消息的最大长度，导入的消息超出时截断
+const maxMessageLength = 10000
+
+// maxErrorMessageRunes 消息上保存的失败原因最大长度
+const maxErrorMessageRunes = 500
+
+// errEmptyReply AI调用成功但没有返回回复
+var errEmptyReply = errors.New("AI没有返回响应")
+
+// 请求、角色和Provider都没有设置时使用的生成参数
+var (
+	defaultChatTemperature float32 = 0.7
+	defaultChatSettings            = ai.GenerationSettings{Temperature: &defaultChatTemperature, MaxTokens: 2000}
+)
+
 // AIHandler AI聊天处理器
 type AIHandler struct {
 	db        *gorm.DB
 	aiManager *ai.AIManager
 	// 缓存
-	characterCache     []models.AICharacter
+	characterCache     []PublicCharacter
 	characterCacheTime time.Time
 	modelCache         []map[string]interface{}
 	modelCacheTime     time.Time
 	cacheMu            sync.RWMutex
 	cacheDuration      time.Duration
+	// 滚动摘要
+	summarizer  *ai.Summarizer
+	summarizing sync.Map // 正在生成摘要的对话ID
+	// 站内工具，为nil时不启用工具调用
+	toolbox *ai.Toolbox
+	// 文章检索，为nil时不启用RAG
+	articleIndex *rag.Service
+	// 系统提示模板渲染
+	prompts *prompt.Renderer
+	// 用量账本，记录每次AI调用的Token用量和费用
+	ledger *usage.Ledger
+	// sessionDailyTokens 每个会话每天的Token配额，0表示不限制
+	sessionDailyTokens int64
+	// 内容审核，检查访客消息和AI回复
+	moderation *moderation.Service
 }
 
 // NewAIHandler 创建AI处理器实例
-func NewAIHandler(db *gorm.DB) *AIHandler {
+// guestbookLimiter 为留言接口的按IP限流器，站内工具代访客留言时共用
+func NewAIHandler(db *gorm.DB, guestbookLimiter *ratelimit.Limiter) *AIHandler {
 	handler := &AIHandler{
 		db:            db,
 		aiManager:     ai.NewAIManager(),
 		cacheDuration: 5 * time.Minute,
+		prompts:       prompt.NewRenderer(db, os.Getenv("SITE_OWNER")),
 	}
+	// 摘要使用廉价的Provider，未配置时使用默认Provider
+	handler.summarizer = ai.NewSummarizer(handler.aiManager, os.Getenv("AI_SUMMARY_PROVIDER"))
+	// 站内工具默认启用，设置 AI_TOOLS_ENABLED=false 关闭
+	if os.Getenv("AI_TOOLS_ENABLED") != "false" {
+		handler.toolbox = sitetools.NewToolbox(db, guestbookLimiter)
+	}
+
+	// 记录每次AI调用的用量，预算通过 AI_BUDGET_DAILY、AI_BUDGET_MONTHLY 配置
+	handler.ledger = usage.NewLedger(db, usage.BudgetFromEnv())
+	handler.aiManager.SetUsageRecorder(handler.ledger.Record)
+	handler.sessionDailyTokens = sessionQuotaFromEnv()
 
 	// 从数据库加载Provider配置
 	handler.loadProvidersFromDB()
@@ -42,6 +103,16 @@ func NewAIHandler(db *gorm.DB) *AIHandler {
 		handler.aiManager.LoadProvidersFromEnv()
 	}
 
+	// 加载降级链和熔断配置
+	handler.aiManager.LoadFailoverFromEnv()
+
+	// 内容审核规则保存在数据库中，可选使用大模型分类兜底
+	handler.moderation = newModerationService(handler)
+
+	// 文章检索使用支持向量化的Provider，未配置时不启用
+	handler.articleIndex = rag.NewService(db, handler.aiManager, os.Getenv("AI_EMBEDDING_PROVIDER"))
+	go handler.articleIndex.Backfill(context.Background())
+
 	return handler
 }
 
@@ -54,38 +125,10 @@ func (h *AIHandler) loadProvidersFromDB() {
 	}
 
 	for _, p := range providers {
-		// 解密API密钥
-		apiKey, err := crypto.Decrypt(p.APIKeyEncrypted)
-		if err != nil {
-			log.Printf("[AIHandler] 解密API密钥失败: %v", err)
-			// 尝试直接使用（可能是未加密的）
-			apiKey = p.APIKeyEncrypted
-		}
-
-		if apiKey == "" {
-			log.Printf("[AIHandler] Provider %s 没有配置API密钥，跳过", p.Name)
-			continue
-		}
-
-		// 根据Provider类型创建实例
-		var provider ai.AIProvider
-		switch p.Name {
-		case "glm":
-			provider = ai.NewGLMProvider(p.APIEndpoint, apiKey, p.ModelName)
-		case "deepseek":
-			provider = ai.NewDeepSeekProvider(p.APIEndpoint, apiKey, p.ModelName)
-		case "qwen":
-			provider = ai.NewQwenProvider(p.APIEndpoint, apiKey, p.ModelName)
-		case "kimi":
-			provider = ai.NewKimiProvider(p.APIEndpoint, apiKey, p.ModelName)
-		case "openai":
-			provider = ai.NewOpenAIProvider(p.APIEndpoint, apiKey, p.ModelName)
-		default:
-			log.Printf("[AIHandler] 未知的Provider类型: %s", p.Name)
-			continue
+		cfg := providerConfig(p)
+		if err := h.aiManager.RegisterProviderConfig(cfg); err != nil {
+			log.Printf("[AIHandler] 加载Provider %s 失败，跳过: %v", p.Name, err)
 		}
-
-		h.aiManager.RegisterProvider(p.Name, provider)
 	}
 
 	log.Printf("[AIHandler] 从数据库加载了 %d 个Provider", len(h.aiManager.GetAllProviders()))
@@ -100,25 +143,57 @@ func (h *AIHandler) ReloadProviders(c *gin.Context) {
 		h.aiManager.LoadProvidersFromEnv()
 	}
 
-	// 清空缓存
-	h.cacheMu.Lock()
-	h.modelCache = nil
-	h.modelCacheTime = time.Time{}
-	h.cacheMu.Unlock()
+	// 向量模型可能已更换，后台补建索引
+	go h.articleIndex.Backfill(context.Background())
+
+	h.invalidateModelCache()
+	h.invalidateCharacterCache()
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "Provider配置已重新加载",
-		"providers": h.aiManager.GetAllProviders(),
+		"message":        "Provider配置已重新加载",
+		"providers":      h.aiManager.GetAllProviders(),
+		"fallback_chain": h.aiManager.GetFallbackChain(),
 	})
 }
 
 // ChatRequest 聊天请求
+// Temperature/TopP/MaxTokens/Stop为可选的生成参数，优先于角色和Provider的配置
+// VisitorName为访客自称，用于系统提示模板中的 {{.VisitorName}}
+// Attachments为通过 /ai/attachments 上传的图片，有图片时Message可以为空
+// SessionID由服务端根据会话令牌填写，请求中的值会被忽略
+// Regenerate为true时重新生成当前分支最后一条回复；EditMessageID不为0时编辑该用户消息，
+// 两者都会在对话树中产生新的分支，见 resolveBranch
 type ChatRequest struct {
-	Message        string `json:"message" binding:"required"`
-	CharacterID    uint   `json:"character_id"`
-	Provider       string `json:"provider"`
-	SessionID      string `json:"session_id"`
-	ConversationID uint   `json:"conversation_id"`
+	Message        string             `json:"message"`
+	Attachments    models.Attachments `json:"attachments"`
+	CharacterID    uint               `json:"character_id"`
+	Provider       string             `json:"provider"`
+	SessionID      string             `json:"session_id"`
+	ConversationID uint               `json:"conversation_id"`
+	VisitorName    string             `json:"visitor_name"`
+	Temperature    *float32           `json:"temperature"`
+	TopP           *float32           `json:"top_p"`
+	MaxTokens      int                `json:"max_tokens"`
+	Stop           []string           `json:"stop"`
+	Regenerate     bool               `json:"regenerate"`
+	EditMessageID  uint               `json:"edit_message_id"`
+	// retryMessageID 重试的用户消息，由 RetryMessage 设置
+	retryMessageID uint
+}
+
+// reusesMessage 是否复用已保存的用户消息（重新生成或重试）
+func (r *ChatRequest) reusesMessage() bool {
+	return r.Regenerate || r.retryMessageID > 0
+}
+
+// settings 请求指定的生成参数
+func (r *ChatRequest) settings() ai.GenerationSettings {
+	return ai.GenerationSettings{
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		MaxTokens:   r.MaxTokens,
+		Stop:        r.Stop,
+	}
 }
 
 // ChatResponse 聊天响应
@@ -126,12 +201,52 @@ type ChatResponse struct {
 	Reply          string `json:"reply"`
 	SessionID      string `json:"session_id"`
 	ConversationID uint   `json:"conversation_id"`
-	Model          string `json:"model"`
-	TokenUsage     struct {
+	// MessageID AI回复的消息ID，ParentID为本轮用户消息的ID，用于重新生成和编辑
+	MessageID  uint   `json:"message_id"`
+	ParentID   uint   `json:"parent_id"`
+	Model      string `json:"model"`
+	Provider   string `json:"provider"`
+	TokenUsage struct {
 		Prompt     int `json:"prompt"`
 		Completion int `json:"completion"`
 		Total      int `json:"total"`
 	} `json:"token_usage"`
+	// Segments 按分隔符切分后的聊天气泡，客户端逐条展示
+	Segments []ai.Segment `json:"segments"`
+	// Sources 回复引用的文章
+	Sources []rag.Source `json:"sources,omitempty"`
+	// Moderation 回复被审核修改时为处理方式（mask 遮盖、block 替换为提示），流式输出时客户端应以此响应为准
+	Moderation string `json:"moderation,omitempty"`
+}
+
+// chatSession 一次聊天请求的准备结果
+type chatSession struct {
+	conversation models.Conversation
+	character    models.AICharacter
+	// provider 本次使用的Provider名称，为空表示默认Provider
+	provider string
+	// branch 本轮对话在对话树中的位置
+	branch *chatBranch
+	aiReq  *ai.ChatCompletionRequest
+	// toSummarize 超出上下文预算、需要压缩进摘要的历史消息
+	toSummarize []models.ChatMessage
+	// sources 注入上下文的文章
+	sources []rag.Source
+	// autoTitle 对话标题是否为自动生成的临时标题，第一轮对话后需要生成正式标题
+	autoTitle bool
+	// inputModeration/replyModeration 访客消息和AI回复的审核结果
+	inputModeration moderation.Result
+	replyModeration moderation.Result
+	// injections 访客消息命中的注入话术
+	injections []guard.Detection
+	// leakDetector 检查回复是否照搬了角色的系统提示，replyRefused表示回复已替换为拒绝话术
+	leakDetector *guard.LeakDetector
+	replyRefused bool
+	// userMessage 本轮的用户消息，在调用AI之前保存
+	userMessage *models.ChatMessage
+	// userMessageID/replyID 保存后的用户消息和AI回复ID
+	userMessageID uint
+	replyID       uint
 }
 
 // Chat 处理聊天请求
@@ -142,150 +257,409 @@ func (h *AIHandler) Chat(c *gin.Context) {
 		return
 	}
 
-	// 验证消息长度
-	if len(req.Message) > 10000 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "消息长度不能超过10000字符"})
+	h.chat(c, &req)
+}
+
+// chat 执行一轮非流式聊天并返回完整回复
+// 用户消息在调用AI之前保存，调用失败时错误响应中的message_id为该消息，可以通过 RetryMessage 重试
+func (h *AIHandler) chat(c *gin.Context, req *ChatRequest) {
+	session := h.prepareChat(c, req)
+	if session == nil {
+		return
+	}
+	if !h.saveUserMessage(c, req, session) {
+		return
+	}
+
+	result, err := h.aiManager.ChatCompletionWithTools(h.chatContext(c, req, session), session.provider, session.aiReq, h.toolbox)
+	if err != nil {
+		log.Printf("[AIHandler] AI调用失败: %v", err)
+		h.failExchange(c, session, err)
+		if errors.Is(err, ai.ErrVisionUnsupported) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": visionUnsupportedMessage, "message_id": session.userMessageID})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务暂时不可用，请稍后重试", "message_id": session.userMessageID})
 		return
 	}
 
+	resp := result.Response
+	if len(resp.Choices) == 0 {
+		h.failExchange(c, session, errEmptyReply)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errEmptyReply.Error(), "message_id": session.userMessageID})
+		return
+	}
+
+	h.guardReply(req, session, resp)
+	h.moderateReply(h.chatContext(c, req, session), session, resp)
+	h.saveExchange(c, req, session, result, models.MessageStatusCompleted)
+
+	c.JSON(http.StatusOK, newChatResponse(req, session, resp))
+}
+
+// prepareChat 校验请求、确保对话存在并构建发送给AI的消息列表
+// 校验失败时已写入错误响应，返回nil
+func (h *AIHandler) prepareChat(c *gin.Context, req *ChatRequest) *chatSession {
+	// 会话ID以令牌为准，忽略请求中的session_id
+	req.SessionID = currentSession(c)
+	if req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": missingSessionMessage})
+		return nil
+	}
+	if (req.Regenerate || req.EditMessageID > 0) && req.ConversationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少对话ID"})
+		return nil
+	}
+	// 重新生成和重试时使用已保存的用户消息，不需要校验
+	if !req.reusesMessage() {
+		// 验证消息长度
+		if strings.TrimSpace(req.Message) == "" && len(req.Attachments) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "消息不能为空"})
+			return nil
+		}
+		if len(req.Message) > maxMessageLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("消息长度不能超过%d字符", maxMessageLength)})
+			return nil
+		}
+		if err := validateAttachments(req.Attachments); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil
+		}
+	}
+	if err := req.settings().Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	if !h.checkSessionQuota(c, req.SessionID) {
+		return nil
+	}
+	// 重新生成和重试时用户消息已审核过
+	var inputModeration moderation.Result
+	if !req.reusesMessage() {
+		result, ok := h.moderateInput(c, req)
+		if !ok {
+			return nil
+		}
+		inputModeration = result
+	}
+
 	// 确保对话存在
 	var conversation models.Conversation
 	if req.ConversationID > 0 {
 		if err := h.db.First(&conversation, req.ConversationID).Error; err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "对话不存在"})
-			return
+			return nil
 		}
-	} else {
-		// 创建新对话
-		title := req.Message
-		if len(title) > 30 {
-			title = title[:30] + "..."
+		if !canAccessConversation(c, conversation, true) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenConversationMessage})
+			return nil
 		}
+	} else {
+		// 创建新对话，先使用消息开头作为临时标题，第一轮对话完成后再生成标题
 		conversation = models.Conversation{
 			SessionID: req.SessionID,
-			Title:     title,
+			Title:     ai.PlaceholderTitle(req.Message),
 		}
 		if err := h.db.Create(&conversation).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建对话失败"})
-			return
+			return nil
 		}
 		req.ConversationID = conversation.ID
 	}
 
+	// 确定本轮对话在对话树中的位置
+	tree, err := loadConversationTree(h.db, conversation.ID)
+	if err != nil {
+		log.Printf("[AIHandler] 读取对话 %d 的消息失败: %v", conversation.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取对话失败"})
+		return nil
+	}
+	branch, err := resolveBranch(tree, conversation, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	current, err := userMessage(req)
+	if err != nil {
+		log.Printf("[AIHandler] 读取聊天图片失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "图片不存在或已失效，请重新上传"})
+		return nil
+	}
+
 	// 获取AI角色
 	var character models.AICharacter
 	if req.CharacterID > 0 {
-		if err := h.db.First(&character, req.CharacterID).Error; err != nil {
+		if err := h.db.Where("is_active = ?", true).First(&character, req.CharacterID).Error; err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "AI角色不存在"})
-			return
+			return nil
 		}
 	} else {
 		// 默认使用第一个活跃角色
 		if err := h.db.Where("is_active = ?", true).First(&character).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "没有可用的AI角色，请先配置"})
-			return
+			return nil
 		}
 	}
 
 	// 检查Provider是否可用
 	if !h.aiManager.HasProvider(req.Provider) && req.Provider != "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "指定的AI模型不可用"})
-		return
+		return nil
 	}
+	providerName, fallback := h.characterProviders(req.Provider, character)
 
-	// 获取对话历史消息（限制20条）
-	var chatHistory []models.ChatMessage
-	h.db.Where("conversation_id = ?", req.ConversationID).
-		Order("created_at ASC").
-		Limit(20).
-		Find(&chatHistory)
+	// 对话的第一轮（包括重试第一条消息）且标题未被修改过时，回复后生成标题
+	autoTitle := len(branch.history) == 0 && (branch.reused == nil || req.retryMessageID > 0) &&
+		(conversation.Title == ai.DefaultTitle || conversation.Title == ai.PlaceholderTitle(req.Message))
 
-	// 构建消息列表
-	messages := []ai.ChatMessage{
-		{
-			Role:    "system",
-			Content: character.SystemPrompt,
-		},
+	// 摘要只对压缩时所在的分支有效，本轮分支不包含已压缩的消息时不使用摘要
+	summary, summarizedUntil := conversation.Summary, conversation.SummarizedUntilID
+	if summarizedUntil != 0 && !onPath(branch.history, summarizedUntil) {
+		summary, summarizedUntil = "", 0
 	}
 
-	// 添加历史消息
+	// 获取当前分支上摘要之后最近的对话历史，没有得到回复的用户消息不发送
+	chatHistory := make([]models.ChatMessage, 0, len(branch.history))
+	for _, msg := range branch.history {
+		if msg.ID > summarizedUntil && !msg.Unanswered() {
+			chatHistory = append(chatHistory, msg)
+		}
+	}
+	if len(chatHistory) > maxHistoryMessages {
+		chatHistory = chatHistory[len(chatHistory)-maxHistoryMessages:]
+	}
+	history := make([]ai.ChatMessage, 0, len(chatHistory))
 	for _, msg := range chatHistory {
-		messages = append(messages, ai.ChatMessage{
-			Role:    msg.MessageType,
-			Content: msg.Content,
-		})
+		history = append(history, toAIMessage(msg))
 	}
 
-	// 添加用户消息
-	messages = append(messages, ai.ChatMessage{
-		Role:    "user",
-		Content: req.Message,
-	})
+	// 系统提示，已有摘要时作为额外的系统消息放在最近的对话之前
+	system := []ai.ChatMessage{{Role: "system", Content: h.renderSystemPrompt(c.Request.Context(), character, req.VisitorName)}}
+	if summary != "" {
+		system = append(system, ai.SummaryMessage(summary))
+	}
+	// 识别注入话术，重新生成时检查复用的用户消息
+	inputText := req.Message
+	if branch.reused != nil {
+		inputText = branch.reused.Content
+	}
+	injections := guardInput(req.SessionID, inputText, &system[0])
 
-	// 调用AI
-	aiReq := &ai.ChatCompletionRequest{
-		Messages:    messages,
-		Temperature: 0.7,
-		MaxTokens:   2000,
+	// 检索相关文章片段，作为参考资料放在对话历史之前，向量化用量计入会话
+	retrievalCtx := ai.WithUsageLabels(c.Request.Context(), ai.UsageLabels{
+		SessionID:      req.SessionID,
+		ConversationID: req.ConversationID,
+		CharacterID:    character.ID,
+	})
+	articles, sources := h.retrieveArticles(retrievalCtx, req.Message)
+	if articles != nil {
+		system = append(system, *articles)
 	}
 
-	resp, err := h.aiManager.ChatCompletion(c.Request.Context(), req.Provider, aiReq)
-	if err != nil {
-		log.Printf("[AIHandler] AI调用失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI服务暂时不可用，请稍后重试"})
-		return
+	// 按Provider的Token预算裁剪上下文，保留系统提示和最近的对话
+	// 生成参数优先级: 请求 > 角色 > Provider默认值
+	providerCfg, _ := h.aiManager.GetProviderConfig(providerName)
+	settings := ai.ResolveSettings(req.settings(), characterSettings(character), providerCfg.Settings(), defaultChatSettings)
+	built := ai.NewContextBuilder(providerCfg, settings.MaxTokens).Build(
+		system,
+		history,
+		current,
+	)
+
+	// 超出预算时，把丢弃的消息连同保留部分中较早的一半压缩进摘要
+	// 多压缩一些可以避免之后每轮对话都触发摘要
+	var toSummarize []models.ChatMessage
+	if built.Dropped > 0 {
+		cut := built.Dropped + (len(chatHistory)-built.Dropped)/2
+		toSummarize = chatHistory[:cut]
+		log.Printf("[AIHandler] 对话 %d 超出上下文预算，丢弃了 %d 条较早的消息，将压缩 %d 条进摘要",
+			req.ConversationID, built.Dropped, cut)
 	}
 
-	if len(resp.Choices) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI没有返回响应"})
-		return
+	aiReq := &ai.ChatCompletionRequest{Messages: built.Messages, Fallback: fallback}
+	settings.Apply(aiReq)
+
+	return &chatSession{
+		conversation:    conversation,
+		character:       character,
+		provider:        providerName,
+		branch:          branch,
+		autoTitle:       autoTitle,
+		aiReq:           aiReq,
+		inputModeration: inputModeration,
+		injections:      injections,
+		leakDetector:    guard.NewLeakDetector(character.SystemPrompt),
+		toSummarize:     toSummarize,
+		sources:         sources,
 	}
+}
 
-	reply := resp.Choices[0].Message.Content
+// saveUserMessage 在调用AI之前保存用户消息，状态为pending，AI调用失败时访客的消息不会丢失
+// 新消息保存后成为对话当前分支的末尾；重试时复用的消息重新标记为pending
+// 保存失败时已写入错误响应，返回false
+func (h *AIHandler) saveUserMessage(c *gin.Context, req *ChatRequest, session *chatSession) bool {
+	if reused := session.branch.reused; reused != nil {
+		if reused.Unanswered() {
+			reused.Status = models.MessageStatusPending
+			reused.ErrorMessage = ""
+			h.db.Model(reused).UpdateColumns(map[string]interface{}{"status": reused.Status, "error_message": ""})
+		}
+		session.userMessage = reused
+		session.userMessageID = reused.ID
+		return true
+	}
 
-	// 保存用户消息
 	userMsg := &models.ChatMessage{
 		ConversationID: req.ConversationID,
+		ParentID:       session.branch.parentID,
 		SessionID:      req.SessionID,
 		UserIP:         c.ClientIP(),
-		CharacterID:    character.ID,
+		CharacterID:    session.character.ID,
 		MessageType:    "user",
 		Content:        req.Message,
-		TokenCount:     resp.Usage.PromptTokens,
+		Attachments:    req.Attachments,
+		Status:         models.MessageStatusPending,
+	}
+	applyModeration(userMsg, session.inputModeration)
+	if len(session.injections) > 0 {
+		flagMessage(userMsg, "guard:"+detectionRules(session.injections))
 	}
 	if err := h.db.Create(userMsg).Error; err != nil {
 		log.Printf("[AIHandler] 保存用户消息失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存消息失败，请稍后重试"})
+		return false
+	}
+	session.userMessage = userMsg
+	session.userMessageID = userMsg.ID
+
+	// 用户消息成为当前分支末尾，生成失败后刷新页面也能看到
+	path := append(append([]models.ChatMessage{}, session.branch.history...), *userMsg)
+	updates := activeBranchUpdates(session.conversation, path)
+	updates["updated_at"] = time.Now()
+	h.db.Model(&session.conversation).Updates(updates)
+	return true
+}
+
+// failExchange AI调用失败后更新用户消息的状态
+// 访客中途断开时为cancelled，否则为failed并记录失败原因；重新生成已有回复的消息失败时不改变状态
+func (h *AIHandler) failExchange(c *gin.Context, session *chatSession, err error) {
+	msg := session.userMessage
+	if msg.Status != models.MessageStatusPending {
+		return
+	}
+	msg.Status = models.MessageStatusFailed
+	if errors.Is(err, context.Canceled) || c.Request.Context().Err() != nil {
+		msg.Status = models.MessageStatusCancelled
+	}
+	msg.ErrorMessage = ai.TruncateRunes(err.Error(), maxErrorMessageRunes)
+	if err := h.db.Model(msg).UpdateColumns(map[string]interface{}{
+		"status":        msg.Status,
+		"error_message": msg.ErrorMessage,
+	}).Error; err != nil {
+		log.Printf("[AIHandler] 更新消息 %d 的状态失败: %v", msg.ID, err)
+	}
+}
+
+// saveExchange 保存工具调用过程和AI回复，将用户消息标记为completed，并更新对话时间
+// 消息依次作为下一条消息的父消息，保存后AI回复成为对话的当前分支末尾
+// status为AI回复的状态，访客中途断开时保存的部分回复为cancelled
+func (h *AIHandler) saveExchange(c *gin.Context, req *ChatRequest, session *chatSession, result *ai.ToolResult, status string) {
+	resp := result.Response
+	reply := resp.Choices[0].Message.Content
+
+	// 用户消息已经得到回复，新保存的消息记录发送时的Token数
+	userMsg := session.userMessage
+	statusUpdates := map[string]interface{}{"status": models.MessageStatusCompleted, "error_message": ""}
+	if session.branch.reused == nil {
+		userMsg.TokenCount = resp.Usage.PromptTokens
+		statusUpdates["token_count"] = userMsg.TokenCount
+	}
+	userMsg.Status = models.MessageStatusCompleted
+	userMsg.ErrorMessage = ""
+	if err := h.db.Model(userMsg).UpdateColumns(statusUpdates).Error; err != nil {
+		log.Printf("[AIHandler] 更新消息 %d 的状态失败: %v", userMsg.ID, err)
+	}
+
+	// saved 本轮保存的消息，追加到分支历史之后即为新的当前分支
+	saved := []models.ChatMessage{*userMsg}
+	parentID := userMsg.ID
+	save := func(msg *models.ChatMessage) error {
+		msg.ConversationID = req.ConversationID
+		msg.SessionID = req.SessionID
+		msg.UserIP = c.ClientIP()
+		msg.CharacterID = session.character.ID
+		msg.ParentID = parentID
+		if err := h.db.Create(msg).Error; err != nil {
+			return err
+		}
+		parentID = msg.ID
+		saved = append(saved, *msg)
+		return nil
+	}
+
+	// 按顺序保存工具调用过程，之后的对话回放历史时需要完整的调用和结果
+	for _, step := range result.Steps {
+		if err := save(fromAIMessage(step)); err != nil {
+			log.Printf("[AIHandler] 保存工具调用记录失败: %v", err)
+		}
 	}
 
 	// 保存AI回复
 	assistantMsg := &models.ChatMessage{
-		ConversationID: req.ConversationID,
-		SessionID:      req.SessionID,
-		UserIP:         c.ClientIP(),
-		CharacterID:    character.ID,
-		MessageType:    "assistant",
-		Content:        reply,
-		TokenCount:     resp.Usage.CompletionTokens,
+		MessageType: "assistant",
+		Content:     reply,
+		Segments:    ai.SplitSegmentTexts(reply),
+		ProviderID:  h.providerID(resp.Provider),
+		TokenCount:  resp.Usage.CompletionTokens,
+		Status:      status,
 	}
-	if err := h.db.Create(assistantMsg).Error; err != nil {
+	applyModeration(assistantMsg, session.replyModeration)
+	if session.replyRefused {
+		flagMessage(assistantMsg, "guard:prompt_leak")
+	}
+	if err := save(assistantMsg); err != nil {
 		log.Printf("[AIHandler] 保存AI回复失败: %v", err)
 	}
+	session.replyID = assistantMsg.ID
 
-	// 更新对话时间
-	h.db.Model(&conversation).Update("updated_at", time.Now())
+	// 切换到新分支并更新对话时间
+	path := append(append([]models.ChatMessage{}, session.branch.history...), saved...)
+	updates := activeBranchUpdates(session.conversation, path)
+	updates["updated_at"] = time.Now()
+	h.db.Model(&session.conversation).Updates(updates)
 
-	// 返回响应
+	// 后台刷新摘要和生成标题，不阻塞本次响应
+	if len(session.toSummarize) > 0 {
+		go h.refreshSummary(req.ConversationID, session.toSummarize)
+	}
+	if session.autoTitle {
+		go h.generateTitle(session.conversation.SessionID, req.ConversationID, session.conversation.Title, req.Message, reply)
+	}
+}
+
+// newChatResponse 根据AI响应构建聊天响应
+func newChatResponse(req *ChatRequest, session *chatSession, resp *ai.ChatCompletionResponse) ChatResponse {
 	response := ChatResponse{
-		Reply:          reply,
+		Reply:          resp.Choices[0].Message.Content,
 		SessionID:      req.SessionID,
 		ConversationID: req.ConversationID,
+		MessageID:      session.replyID,
+		ParentID:       session.userMessageID,
 		Model:          resp.Model,
+		Provider:       resp.Provider,
+		Segments:       ai.SplitSegments(resp.Choices[0].Message.Content),
+		Sources:        session.sources,
+	}
+	if action := session.replyModeration.Action; action == moderation.ActionMask || action == moderation.ActionBlock {
+		response.Moderation = string(action)
+	}
+	if session.replyRefused {
+		response.Moderation = string(moderation.ActionBlock)
 	}
 	response.TokenUsage.Prompt = resp.Usage.PromptTokens
 	response.TokenUsage.Completion = resp.Usage.CompletionTokens
 	response.TokenUsage.Total = resp.Usage.TotalTokens
-
-	c.JSON(http.StatusOK, response)
+	return response
 }
 
 // GetModels 获取可用模型列表
@@ -308,6 +682,7 @@ func (h *AIHandler) GetModels(c *gin.Context) {
 	for _, p := range providers {
 		modelList = append(modelList, map[string]interface{}{
 			"name":         p.Name,
+			"kind":         p.ProviderKind(),
 			"model":        p.ModelName,
 			"display_name": p.DisplayName,
 		})
@@ -320,6 +695,7 @@ func (h *AIHandler) GetModels(c *gin.Context) {
 			if provider != nil {
 				modelList = append(modelList, map[string]interface{}{
 					"name":         name,
+					"kind":         provider.GetProviderName(),
 					"model":        provider.GetModelName(),
 					"display_name": name + " - " + provider.GetModelName(),
 				})
@@ -336,7 +712,25 @@ func (h *AIHandler) GetModels(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"models": modelList})
 }
 
-// GetCharacters 获取AI角色列表
+// GetProviderModels 获取Provider服务端可用的模型列表
+// 用于本地Provider（如ollama）查看已下载的模型，路径参数可以是数据库ID或Provider名称
+func (h *AIHandler) GetProviderModels(c *gin.Context) {
+	name := h.providerName(c.Param("id"))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	modelNames, err := h.aiManager.ListModels(ctx, name)
+	if err != nil {
+		log.Printf("[AIHandler] 获取Provider %s 模型列表失败: %v", name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "获取模型列表失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"provider": name, "models": modelNames})
+}
+
+// GetCharacters 获取启用的AI角色列表，公开接口只返回展示用的信息，见 PublicCharacter
 func (h *AIHandler) GetCharacters(c *gin.Context) {
 	// 检查缓存
 	h.cacheMu.RLock()
@@ -349,8 +743,12 @@ func (h *AIHandler) GetCharacters(c *gin.Context) {
 	h.cacheMu.RUnlock()
 
 	// 从数据库获取
-	var characters []models.AICharacter
-	h.db.Where("is_active = ?", true).Find(&characters)
+	var records []models.AICharacter
+	h.db.Where("is_active = ?", true).Find(&records)
+	characters := make([]PublicCharacter, 0, len(records))
+	for _, record := range records {
+		characters = append(characters, newPublicCharacter(record))
+	}
 
 	// 更新缓存
 	h.cacheMu.Lock()
@@ -361,27 +759,28 @@ func (h *AIHandler) GetCharacters(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"characters": characters})
 }
 
-// GetHistory 获取聊天历史
+// GetHistory 获取当前会话的聊天历史，管理员可以通过session_id参数查看任意会话
 func (h *AIHandler) GetHistory(c *gin.Context) {
-	sessionID := c.Query("session_id")
+	sessionID := querySession(c)
 	if sessionID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少session_id参数"})
 		return
 	}
 
 	var messages []models.ChatMessage
-	h.db.Where("session_id = ?", sessionID).
+	h.db.Scopes(models.VisibleChatMessages).
+		Where("session_id = ?", sessionID).
 		Order("created_at ASC").
 		Find(&messages)
 
 	c.JSON(http.StatusOK, gin.H{"messages": messages})
 }
 
-// ClearHistory 清空聊天历史
+// ClearHistory 清空当前会话的聊天历史
 func (h *AIHandler) ClearHistory(c *gin.Context) {
-	sessionID := c.Query("session_id")
+	sessionID := currentSession(c)
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少session_id参数"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": missingSessionMessage})
 		return
 	}
 
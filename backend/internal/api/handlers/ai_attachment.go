@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 聊天附件的限制
+const (
+	// maxAttachmentSize 单张图片的最大字节数
+	maxAttachmentSize = 5 << 20
+	// maxChatAttachments 单条消息的最大附件数
+	maxChatAttachments = 4
+	// chatUploadDir 聊天附件在uploads下的子目录
+	chatUploadDir = "chat"
+)
+
+// chatUploadPrefix 聊天附件的站内访问地址前缀
+var chatUploadPrefix = "/" + uploadRoot + "/" + chatUploadDir + "/"
+
+// attachmentImageTypes 允许上传的图片类型及保存时使用的扩展名
+var attachmentImageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UploadAttachment 上传聊天图片，返回的附件信息放入聊天请求的attachments中
+// 按文件内容识别类型，不信任客户端提供的扩展名和Content-Type
+func (h *AIHandler) UploadAttachment(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件上传失败"})
+		return
+	}
+	if file.Size > maxAttachmentSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("图片不能超过%dMB", maxAttachmentSize>>20)})
+		return
+	}
+
+	mimeType, err := sniffUpload(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return
+	}
+	ext, ok := attachmentImageTypes[mimeType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只支持PNG、JPEG、GIF和WebP格式的图片"})
+		return
+	}
+
+	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
+	url, err := saveUpload(c, file, chatUploadDir, filename)
+	if err != nil {
+		log.Printf("[AIHandler] 保存聊天图片失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Attachment{
+		Type:     "image",
+		URL:      url,
+		MimeType: mimeType,
+		Size:     file.Size,
+	})
+}
+
+// sniffUpload 读取文件开头识别内容类型
+func sniffUpload(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// validateAttachments 校验聊天请求中的附件
+// 只接受本站上传的聊天图片和公网https图片，避免请求引用服务器上的其他文件
+func validateAttachments(attachments models.Attachments) error {
+	if len(attachments) > maxChatAttachments {
+		return fmt.Errorf("每条消息最多附带%d张图片", maxChatAttachments)
+	}
+	for i := range attachments {
+		attachment := &attachments[i]
+		if attachment.Type == "" {
+			attachment.Type = "image"
+		}
+		if attachment.Type != "image" {
+			return errors.New("不支持的附件类型: " + attachment.Type)
+		}
+		switch {
+		case strings.HasPrefix(attachment.URL, chatUploadPrefix):
+			name := strings.TrimPrefix(attachment.URL, chatUploadPrefix)
+			if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+				return errors.New("无效的图片地址")
+			}
+		case strings.HasPrefix(attachment.URL, "https://"):
+		default:
+			return errors.New("图片地址必须是上传后返回的地址或https地址")
+		}
+	}
+	return nil
+}
+
+// attachmentPart 将附件转换为发送给模型的图片内容
+// 站内图片模型无法访问，读取文件后以base64内联；公网图片直接传地址
+func attachmentPart(attachment models.Attachment) (ai.ContentPart, error) {
+	if !strings.HasPrefix(attachment.URL, chatUploadPrefix) {
+		return ai.ImagePart(attachment.URL), nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(uploadRoot, chatUploadDir, strings.TrimPrefix(attachment.URL, chatUploadPrefix)))
+	if err != nil {
+		return ai.ContentPart{}, err
+	}
+	mimeType := http.DetectContentType(data)
+	if _, ok := attachmentImageTypes[mimeType]; !ok {
+		return ai.ContentPart{}, errors.New("不支持的图片格式: " + mimeType)
+	}
+	return ai.ImagePart("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// userMessage 构建本轮的用户消息，有附件时使用文本加图片的多段内容
+func userMessage(req *ChatRequest) (ai.ChatMessage, error) {
+	msg := ai.ChatMessage{Role: "user", Content: req.Message}
+	if len(req.Attachments) == 0 {
+		return msg, nil
+	}
+
+	if req.Message != "" {
+		msg.Parts = append(msg.Parts, ai.TextPart(req.Message))
+	}
+	for _, attachment := range req.Attachments {
+		part, err := attachmentPart(attachment)
+		if err != nil {
+			return msg, err
+		}
+		msg.Parts = append(msg.Parts, part)
+	}
+	return msg, nil
+}
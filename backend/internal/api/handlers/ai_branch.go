@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"personal-website/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatBranch 本次聊天在对话树中的位置
+type chatBranch struct {
+	// parentID 本轮用户消息的父消息，为0表示对话的第一条消息
+	parentID uint
+	// history 从第一条消息到parentID的消息，即本轮之前的对话
+	history []models.ChatMessage
+	// reused 重新生成或重试时复用的用户消息，为nil表示需要保存新的用户消息
+	reused *models.ChatMessage
+}
+
+// resolveBranch 根据请求确定本轮对话接在对话树的哪条消息之后
+// 普通聊天接在当前分支末尾；编辑消息时与原消息共用父消息，成为原消息的兄弟分支；
+// 重新生成时复用当前分支最后一条用户消息，新回复成为原回复的兄弟分支；重试时复用指定的用户消息
+func resolveBranch(tree *models.MessageTree, conversation models.Conversation, req *ChatRequest) (*chatBranch, error) {
+	switch {
+	case req.retryMessageID > 0:
+		target, ok := tree.Message(req.retryMessageID)
+		if !ok || !retryable(target, time.Now()) {
+			return nil, errors.New("只能重试失败或已取消的消息")
+		}
+		req.Message = target.Content
+		req.Attachments = target.Attachments
+		return &chatBranch{parentID: target.ParentID, history: tree.Path(target.ParentID), reused: &target}, nil
+
+	case req.Regenerate:
+		path := tree.Path(conversation.ActiveLeafID)
+		for i := len(path) - 1; i >= 0; i-- {
+			if path[i].MessageType != "user" {
+				continue
+			}
+			last := path[i]
+			req.Message = last.Content
+			req.Attachments = last.Attachments
+			return &chatBranch{parentID: last.ParentID, history: path[:i], reused: &last}, nil
+		}
+		return nil, errors.New("没有可以重新生成的回复")
+
+	case req.EditMessageID > 0:
+		target, ok := tree.Message(req.EditMessageID)
+		if !ok || target.MessageType != "user" {
+			return nil, errors.New("只能编辑该对话中的用户消息")
+		}
+		return &chatBranch{parentID: target.ParentID, history: tree.Path(target.ParentID)}, nil
+
+	default:
+		return &chatBranch{parentID: conversation.ActiveLeafID, history: tree.Path(conversation.ActiveLeafID)}, nil
+	}
+}
+
+// Regenerate 重新生成当前分支最后一条回复，原回复作为分支保留
+// 请求参数同Chat，必须指定conversation_id，message和attachments会被忽略
+func (h *AIHandler) Regenerate(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	req.Regenerate = true
+	req.EditMessageID = 0
+
+	h.chat(c, &req)
+}
+
+// EditMessage 编辑之前的用户消息并重新发送，编辑后的消息作为原消息的兄弟分支
+// 路径参数为要编辑的消息ID，请求参数同Chat
+func (h *AIHandler) EditMessage(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var target models.ChatMessage
+	if err := h.db.First(&target, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+		return
+	}
+	req.ConversationID = target.ConversationID
+	req.EditMessageID = target.ID
+	req.Regenerate = false
+
+	h.chat(c, &req)
+}
+
+// stalePendingAfter 等待回复超过该时间的消息视为生成中断（如服务重启），可以重试
+const stalePendingAfter = 5 * time.Minute
+
+// retryable 用户消息是否可以重试
+func retryable(msg models.ChatMessage, now time.Time) bool {
+	if !msg.Unanswered() {
+		return false
+	}
+	if msg.Status == models.MessageStatusPending {
+		return now.Sub(msg.CreatedAt) > stalePendingAfter
+	}
+	return true
+}
+
+// RetryMessage 重新发送失败或已取消的用户消息，回复接在原消息之后
+// 路径参数为用户消息ID，请求体可以为空，也可以指定同Chat的角色、模型和生成参数
+func (h *AIHandler) RetryMessage(c *gin.Context) {
+	var req ChatRequest
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+	}
+
+	var target models.ChatMessage
+	if err := h.db.First(&target, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+		return
+	}
+	req.ConversationID = target.ConversationID
+	req.retryMessageID = target.ID
+	req.Regenerate = false
+	req.EditMessageID = 0
+
+	h.chat(c, &req)
+}
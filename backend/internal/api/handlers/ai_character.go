@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/prompt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 角色配置的校验限制
+const (
+	// maxSystemPromptRunes 系统提示的最大字符数，过长会挤占对话的上下文预算
+	maxSystemPromptRunes = 4000
+	// maxPersonalityTags 性格标签的最大数量
+	maxPersonalityTags = 10
+	// maxPersonalityTagRunes 单个性格标签的最大字符数
+	maxPersonalityTagRunes = 20
+)
+
+// CharacterRequest 创建或更新AI角色的请求
+// IsActive为nil时创建默认启用、更新保持不变
+// 模型参数未设置时使用Provider的默认值
+type CharacterRequest struct {
+	Name              string   `json:"name" binding:"required"`
+	Description       string   `json:"description"`
+	SystemPrompt      string   `json:"system_prompt" binding:"required"`
+	Avatar            string   `json:"avatar"`
+	PersonalityTags   []string `json:"personality_tags"`
+	GreetingMessage   string   `json:"greeting_message"`
+	RefusalMessage    string   `json:"refusal_message"`
+	PreferredProvider string   `json:"preferred_provider"`
+	FallbackProvider  string   `json:"fallback_provider"`
+	Temperature       *float32 `json:"temperature"`
+	TopP              *float32 `json:"top_p"`
+	MaxTokens         int      `json:"max_tokens"`
+	StopSequences     []string `json:"stop_sequences"`
+	IsActive          *bool    `json:"is_active"`
+}
+
+// PublicCharacter 公开角色列表返回的角色信息
+// 不包含系统提示、拒绝话术、Provider和生成参数，完整配置只能通过管理后台的 ListAllCharacters 查看
+type PublicCharacter struct {
+	ID               uint     `json:"id"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Avatar           string   `json:"avatar"`
+	PersonalityTags  []string `json:"personality_tags"`
+	GreetingMessage  string   `json:"greeting_message"`
+	GreetingSegments []string `json:"greeting_segments,omitempty"`
+}
+
+// newPublicCharacter 构建公开的角色信息
+func newPublicCharacter(character models.AICharacter) PublicCharacter {
+	return PublicCharacter{
+		ID:               character.ID,
+		Name:             character.Name,
+		Description:      character.Description,
+		Avatar:           character.Avatar,
+		PersonalityTags:  character.PersonalityTags,
+		GreetingMessage:  character.GreetingMessage,
+		GreetingSegments: ai.SplitSegmentTexts(character.GreetingMessage),
+	}
+}
+
+// invalidateCharacterCache 清空角色列表缓存，角色修改后立即生效
+func (h *AIHandler) invalidateCharacterCache() {
+	h.cacheMu.Lock()
+	h.characterCache = nil
+	h.characterCacheTime = time.Time{}
+	h.cacheMu.Unlock()
+}
+
+// normalizeTags 去除空白和重复的标签，并校验数量和长度
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxPersonalityTagRunes {
+			return nil, fmt.Errorf("性格标签「%s」不能超过%d字", tag, maxPersonalityTagRunes)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxPersonalityTags {
+		return nil, fmt.Errorf("性格标签不能超过%d个", maxPersonalityTags)
+	}
+	return normalized, nil
+}
+
+// applyCharacterRequest 校验请求并写入角色记录
+func applyCharacterRequest(character *models.AICharacter, req *CharacterRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.SystemPrompt = strings.TrimSpace(req.SystemPrompt)
+	if req.Name == "" {
+		return errors.New("角色名称不能为空")
+	}
+	if req.SystemPrompt == "" {
+		return errors.New("系统提示不能为空")
+	}
+	if utf8.RuneCountInString(req.SystemPrompt) > maxSystemPromptRunes {
+		return fmt.Errorf("系统提示不能超过%d字", maxSystemPromptRunes)
+	}
+	if err := prompt.Validate(req.SystemPrompt); err != nil {
+		return err
+	}
+
+	tags, err := normalizeTags(req.PersonalityTags)
+	if err != nil {
+		return err
+	}
+
+	settings := ai.GenerationSettings{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.StopSequences,
+	}
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	character.Name = req.Name
+	character.Description = req.Description
+	character.SystemPrompt = req.SystemPrompt
+	character.Avatar = req.Avatar
+	character.PersonalityTags = tags
+	character.GreetingMessage = req.GreetingMessage
+	character.RefusalMessage = req.RefusalMessage
+	character.PreferredProvider = strings.TrimSpace(req.PreferredProvider)
+	character.FallbackProvider = strings.TrimSpace(req.FallbackProvider)
+	character.Temperature = req.Temperature
+	character.TopP = req.TopP
+	character.MaxTokens = req.MaxTokens
+	character.StopSequences = req.StopSequences
+	if req.IsActive != nil {
+		character.IsActive = *req.IsActive
+	}
+	return nil
+}
+
+// isLastActiveCharacter 判断是否为最后一个启用的角色，聊天至少需要一个启用的角色
+func (h *AIHandler) isLastActiveCharacter(character models.AICharacter) bool {
+	if !character.IsActive {
+		return false
+	}
+	var count int64
+	h.db.Model(&models.AICharacter{}).Where("is_active = ? AND id <> ?", true, character.ID).Count(&count)
+	return count == 0
+}
+
+// nameTaken 判断角色名称是否已被其他角色使用
+func (h *AIHandler) nameTaken(name string, excludeID uint) bool {
+	var count int64
+	h.db.Model(&models.AICharacter{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count)
+	return count > 0
+}
+
+// ListAllCharacters 获取全部角色（包括停用的），用于管理后台
+func (h *AIHandler) ListAllCharacters(c *gin.Context) {
+	var characters []models.AICharacter
+	if err := h.db.Order("id").Find(&characters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"characters": characters})
+}
+
+// CreateCharacter 创建AI角色
+func (h *AIHandler) CreateCharacter(c *gin.Context) {
+	var req CharacterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	character := models.AICharacter{IsActive: true}
+	if err := applyCharacterRequest(&character, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if h.nameTaken(character.Name, 0) {
+		c.JSON(http.StatusConflict, gin.H{"error": "角色名称已存在"})
+		return
+	}
+
+	if err := h.db.Create(&character).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	h.invalidateCharacterCache()
+
+	c.JSON(http.StatusCreated, character)
+}
+
+// UpdateCharacter 更新AI角色
+func (h *AIHandler) UpdateCharacter(c *gin.Context) {
+	var character models.AICharacter
+	if err := h.db.First(&character, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	original := character
+
+	var req CharacterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := applyCharacterRequest(&character, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if h.nameTaken(character.Name, character.ID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "角色名称已存在"})
+		return
+	}
+	if !character.IsActive && h.isLastActiveCharacter(original) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要保留一个启用的角色"})
+		return
+	}
+
+	if err := h.db.Save(&character).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	h.invalidateCharacterCache()
+
+	c.JSON(http.StatusOK, character)
+}
+
+// DeleteCharacter 删除AI角色，历史消息中的角色ID保留不变
+func (h *AIHandler) DeleteCharacter(c *gin.Context) {
+	var character models.AICharacter
+	if err := h.db.First(&character, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if h.isLastActiveCharacter(character) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要保留一个启用的角色"})
+		return
+	}
+
+	if err := h.db.Delete(&character).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	h.invalidateCharacterCache()
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ActivateCharacter 启用AI角色
+func (h *AIHandler) ActivateCharacter(c *gin.Context) {
+	h.setCharacterActive(c, true)
+}
+
+// DeactivateCharacter 停用AI角色，停用后访客不能再选择该角色
+func (h *AIHandler) DeactivateCharacter(c *gin.Context) {
+	h.setCharacterActive(c, false)
+}
+
+// setCharacterActive 修改角色的启用状态
+func (h *AIHandler) setCharacterActive(c *gin.Context, active bool) {
+	var character models.AICharacter
+	if err := h.db.First(&character, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if !active && h.isLastActiveCharacter(character) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要保留一个启用的角色"})
+		return
+	}
+
+	if err := h.db.Model(&character).Update("is_active", active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	h.invalidateCharacterCache()
+
+	c.JSON(http.StatusOK, character)
+}
+
+// characterSettings 角色配置的生成参数
+func characterSettings(character models.AICharacter) ai.GenerationSettings {
+	return ai.GenerationSettings{
+		Temperature: character.Temperature,
+		TopP:        character.TopP,
+		MaxTokens:   character.MaxTokens,
+		Stop:        character.StopSequences,
+	}
+}
+
+// characterProviders 确定本次聊天使用的Provider和降级Provider
+// 请求指定的Provider优先；否则使用角色的首选Provider，未加载时退回默认Provider
+func (h *AIHandler) characterProviders(requested string, character models.AICharacter) (string, []string) {
+	provider := requested
+	if provider == "" && character.PreferredProvider != "" {
+		if h.aiManager.HasProvider(character.PreferredProvider) {
+			provider = character.PreferredProvider
+		} else {
+			log.Printf("[AIHandler] 角色 %s 的首选Provider %s 不可用，使用默认Provider", character.Name, character.PreferredProvider)
+		}
+	}
+
+	var fallback []string
+	if character.FallbackProvider != "" && character.FallbackProvider != provider {
+		fallback = []string{character.FallbackProvider}
+	}
+	return provider, fallback
+}
+
+// renderSystemPrompt 渲染角色的系统提示模板
+// 模板在保存时已校验，渲染仍失败时（如旧数据）记录日志并使用原始文本
+func (h *AIHandler) renderSystemPrompt(ctx context.Context, character models.AICharacter, visitorName string) string {
+	rendered, err := h.prompts.Render(ctx, character.SystemPrompt, visitorName)
+	if err != nil {
+		log.Printf("[AIHandler] 渲染角色 %s 的系统提示失败: %v", character.Name, err)
+		return character.SystemPrompt
+	}
+	return rendered
+}
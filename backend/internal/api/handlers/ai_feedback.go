@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/finetune"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFeedbackCommentRunes 评价意见的最大字符数
+const maxFeedbackCommentRunes = 500
+
+// FeedbackRequest 评价AI回复的请求
+// Rating: 1 好评，-1 差评，0 取消评价
+type FeedbackRequest struct {
+	Rating  int    `json:"rating" binding:"oneof=-1 0 1"`
+	Comment string `json:"comment"`
+}
+
+// RateMessage 访客评价AI回复，重复评价会覆盖之前的评价
+// 只能评价自己会话中的消息
+func (h *AIHandler) RateMessage(c *gin.Context) {
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(req.Comment) > maxFeedbackCommentRunes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("评价意见不能超过%d字", maxFeedbackCommentRunes)})
+		return
+	}
+
+	var message models.ChatMessage
+	if err := h.db.First(&message, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+		return
+	}
+	if sessionID := currentSession(c); sessionID == "" || message.SessionID != sessionID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权评价该消息"})
+		return
+	}
+	if message.MessageType != "assistant" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能评价AI的回复"})
+		return
+	}
+	if message.Imported {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "导入的消息不能评价"})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"rating":           req.Rating,
+		"feedback_comment": req.Comment,
+		"rated_at":         time.Now(),
+	}
+	if req.Rating == 0 {
+		updates["feedback_comment"] = ""
+		updates["rated_at"] = nil
+	}
+	if err := h.db.Model(&message).UpdateColumns(updates).Error; err != nil {
+		log.Printf("[AIHandler] 保存消息 %d 的评价失败: %v", message.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存评价失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "评价成功", "rating": req.Rating})
+}
+
+// ExportFineTuning 导出角色的好评对话，格式为OpenAI微调使用的JSONL
+// 查询参数character_id为角色ID，每条样本以角色当前渲染后的系统提示开头
+// 导入的消息、未完成的回复和命中审核或泄露检查的回复不会导出，见 finetune.BuildExamples
+func (h *AIHandler) ExportFineTuning(c *gin.Context) {
+	characterID, err := strconv.ParseUint(c.Query("character_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少character_id参数"})
+		return
+	}
+	var character models.AICharacter
+	if err := h.db.First(&character, characterID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	var conversationIDs []uint
+	if err := h.db.Model(&models.ChatMessage{}).
+		Where("character_id = ? AND message_type = ? AND rating = ? AND imported = ?", character.ID, "assistant", models.RatingUp, false).
+		Distinct().
+		Pluck("conversation_id", &conversationIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	systemPrompt := h.renderSystemPrompt(c.Request.Context(), character, "")
+	filename := fmt.Sprintf("finetune-%d-%s.jsonl", character.ID, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/jsonl; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// 按对话逐个读取并写出，避免一次加载全部消息
+	total := 0
+	for _, conversationID := range conversationIDs {
+		var messages []models.ChatMessage
+		if err := h.db.Where("conversation_id = ?", conversationID).Order("id ASC").Find(&messages).Error; err != nil {
+			log.Printf("[AIHandler] 读取对话 %d 失败，跳过: %v", conversationID, err)
+			continue
+		}
+
+		examples := finetune.BuildExamples(systemPrompt, character.ID, messages)
+		if err := finetune.WriteJSONL(c.Writer, examples); err != nil {
+			log.Printf("[AIHandler] 写出微调数据失败: %v", err)
+			return
+		}
+		total += len(examples)
+	}
+
+	log.Printf("[AIHandler] 导出角色 %s 的微调数据 %d 条", character.Name, total)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/guard"
+)
+
+// errPromptLeaked 流式输出中检测到系统提示泄露，用于中止输出
+var errPromptLeaked = errors.New("回复泄露了系统提示")
+
+// guardInput 检查访客消息中的注入话术，命中时在系统提示末尾追加提醒
+// 返回命中的规则，用于在保存消息时标记待复核
+func guardInput(sessionID, message string, system *ai.ChatMessage) []guard.Detection {
+	detections := guard.DetectInjection(message)
+	if len(detections) == 0 {
+		return nil
+	}
+	log.Printf("[AIHandler] 会话 %s 的消息疑似提示注入: %s", sessionID, detectionRules(detections))
+	system.Content += guard.Reminder
+	return detections
+}
+
+// detectionRules 命中的规则名称，用逗号分隔
+func detectionRules(detections []guard.Detection) string {
+	rules := make([]string, 0, len(detections))
+	for _, d := range detections {
+		rules = append(rules, d.Rule)
+	}
+	return strings.Join(rules, ",")
+}
+
+// refusal 角色的拒绝话术
+func refusal(character models.AICharacter) string {
+	if text := strings.TrimSpace(character.RefusalMessage); text != "" {
+		return text
+	}
+	return guard.DefaultRefusal
+}
+
+// guardReply 检查AI回复是否泄露了系统提示，泄露时替换为角色的拒绝话术
+func (h *AIHandler) guardReply(req *ChatRequest, session *chatSession, resp *ai.ChatCompletionResponse) {
+	reply := &resp.Choices[0].Message
+	if !session.leakDetector.Leaked(reply.Content) {
+		return
+	}
+	log.Printf("[AIHandler] 会话 %s 的回复泄露了角色 %s 的系统提示，已替换为拒绝话术", req.SessionID, session.character.Name)
+	reply.Content = refusal(session.character)
+	session.replyRefused = true
+}
+
+// streamLeakGuard 流式输出时逐段检查泄露，泄露时返回errPromptLeaked中止输出
+// 末尾保留判定阈值长度的内容暂不放行，检测到泄露时照搬的内容还没有输出给访客
+type streamLeakGuard struct {
+	detector *guard.LeakDetector
+	content  strings.Builder
+	released int // 已放行的字符数
+}
+
+// feed 追加增量内容并检查，返回可以放行的内容
+func (g *streamLeakGuard) feed(delta string) (string, error) {
+	if g.detector == nil {
+		return delta, nil
+	}
+	g.content.WriteString(delta)
+	text := g.content.String()
+	if g.detector.Leaked(text) {
+		return "", errPromptLeaked
+	}
+
+	runes := []rune(text)
+	end := len(runes) - g.detector.Threshold()
+	if end <= g.released {
+		return "", nil
+	}
+	released := string(runes[g.released:end])
+	g.released = end
+	return released, nil
+}
+
+// refusalResult 流式输出因泄露中止后，用拒绝话术构造的回复
+func refusalResult(session *chatSession) *ai.ToolResult {
+	session.replyRefused = true
+	return textResult(session, refusal(session.character))
+}
+
+// flagMessage 标记消息待复核并追加原因
+func flagMessage(msg *models.ChatMessage, reason string) {
+	msg.Flagged = true
+	if msg.ModerationReason != "" {
+		reason = msg.ModerationReason + "," + reason
+	}
+	msg.ModerationReason = ai.TruncateRunes(reason, maxModerationReasonRunes)
+}
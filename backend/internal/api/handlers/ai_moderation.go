@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/moderation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 审核拦截时返回给访客的提示
+const (
+	inputBlockedMessage = "消息包含不当内容，请修改后重试"
+	replyBlockedMessage = "抱歉，这个话题我没办法回答，我们聊点别的吧。"
+)
+
+// errReplyBlocked 流式输出中回复命中block规则，用于中止输出
+var errReplyBlocked = errors.New("回复被审核拦截")
+
+// maxModerationReasonRunes 消息上保存的命中原因最大长度
+const maxModerationReasonRunes = 255
+
+// ModerationRuleRequest 创建或更新审核规则的请求
+// IsActive为nil时创建默认启用、更新保持不变
+type ModerationRuleRequest struct {
+	Kind        string `json:"kind" binding:"required,oneof=keyword regex"`
+	Category    string `json:"category" binding:"required"`
+	Action      string `json:"action" binding:"required,oneof=block mask flag"`
+	Pattern     string `json:"pattern" binding:"required"`
+	Description string `json:"description"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// ReviewRequest 复核消息的请求，Unflag为true时取消标记
+type ReviewRequest struct {
+	Unflag bool `json:"unflag"`
+}
+
+// Moderation 获取审核服务，供导入对话时审核访客消息
+func (h *AIHandler) Moderation() *moderation.Service {
+	return h.moderation
+}
+
+// newModerationService 创建审核服务
+// 大模型分类使用 AI_MODERATION_PROVIDER 指定的Provider，未配置时只使用关键词和正则规则；
+// 命中后的处理方式由 AI_MODERATION_LLM_ACTION 指定，默认flag
+func newModerationService(h *AIHandler) *moderation.Service {
+	action := moderation.ActionFlag
+	if value := os.Getenv("AI_MODERATION_LLM_ACTION"); value != "" {
+		parsed, err := moderation.ParseAction(value)
+		if err != nil {
+			log.Printf("[AIHandler] AI_MODERATION_LLM_ACTION格式错误，使用flag: %v", err)
+		} else {
+			action = parsed
+		}
+	}
+	return moderation.NewService(h.db, h.aiManager, os.Getenv("AI_MODERATION_PROVIDER"), action)
+}
+
+// moderateInput 审核访客消息，被拒绝时已写入错误响应并返回false
+// mask规则命中的内容在发送给AI和保存前被遮盖
+func (h *AIHandler) moderateInput(c *gin.Context, req *ChatRequest) (moderation.Result, bool) {
+	ctx := ai.WithUsageLabels(c.Request.Context(), ai.UsageLabels{SessionID: req.SessionID, ConversationID: req.ConversationID})
+	result := h.moderation.Check(ctx, req.Message)
+	if result.Blocked() {
+		log.Printf("[AIHandler] 会话 %s 的消息被审核拦截: %s", req.SessionID, result.Reason())
+		c.JSON(http.StatusBadRequest, gin.H{"error": inputBlockedMessage})
+		return result, false
+	}
+	req.Message = result.Text
+	return result, true
+}
+
+// moderateReply 审核AI回复，直接修改resp中的回复内容
+// 被拒绝的回复替换为固定的提示，mask规则命中的内容被遮盖
+func (h *AIHandler) moderateReply(ctx context.Context, session *chatSession, resp *ai.ChatCompletionResponse) {
+	reply := &resp.Choices[0].Message
+	result := h.moderation.Check(ctx, reply.Content)
+	switch {
+	case result.Blocked():
+		log.Printf("[AIHandler] 对话 %d 的回复被审核拦截: %s", session.conversation.ID, result.Reason())
+		reply.Content = replyBlockedMessage
+	default:
+		reply.Content = result.Text
+	}
+	session.replyModeration = result
+}
+
+// applyModeration 将审核结果记录到消息上
+// 命中flag规则或被拒绝的消息标记为待复核，只命中mask规则的消息只记录原因
+func applyModeration(msg *models.ChatMessage, result moderation.Result) {
+	if len(result.Matches) == 0 {
+		return
+	}
+	msg.Flagged = result.Flagged() || result.Blocked()
+	msg.ModerationReason = ai.TruncateRunes(result.Reason(), maxModerationReasonRunes)
+}
+
+// ListFlaggedMessages 获取待复核的消息
+// 查询参数status: pending 未复核（默认）、reviewed 已复核、all 全部；page/page_size分页
+func (h *AIHandler) ListFlaggedMessages(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.ChatMessage{}).Where("flagged = ?", true)
+	switch c.DefaultQuery("status", "pending") {
+	case "pending":
+		query = query.Where("reviewed_at IS NULL")
+	case "reviewed":
+		query = query.Where("reviewed_at IS NOT NULL")
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status只支持pending/reviewed/all"})
+		return
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var messages []models.ChatMessage
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":  messages,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ReviewMessage 复核被标记的消息，记录复核时间，可以同时取消标记
+func (h *AIHandler) ReviewMessage(c *gin.Context) {
+	var req ReviewRequest
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+			return
+		}
+	}
+
+	var message models.ChatMessage
+	if err := h.db.First(&message, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+		return
+	}
+
+	updates := map[string]interface{}{"reviewed_at": time.Now()}
+	if req.Unflag {
+		updates["flagged"] = false
+	}
+	if err := h.db.Model(&message).UpdateColumns(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "复核成功"})
+}
+
+// ListModerationRules 获取全部审核规则（包括停用的）
+func (h *AIHandler) ListModerationRules(c *gin.Context) {
+	var rules []models.ModerationRule
+	if err := h.db.Order("id").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// applyModerationRuleRequest 将请求内容写入规则并校验
+func applyModerationRuleRequest(rule *models.ModerationRule, req *ModerationRuleRequest) error {
+	rule.Kind = req.Kind
+	rule.Category = strings.TrimSpace(req.Category)
+	rule.Action = req.Action
+	rule.Pattern = req.Pattern
+	rule.Description = req.Description
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	return moderation.ValidateRule(*rule)
+}
+
+// reloadModeration 规则修改后重新加载审核流程
+func (h *AIHandler) reloadModeration() {
+	if err := h.moderation.Reload(); err != nil {
+		log.Printf("[AIHandler] 重新加载审核规则失败: %v", err)
+	}
+}
+
+// CreateModerationRule 创建审核规则，保存后立即生效
+func (h *AIHandler) CreateModerationRule(c *gin.Context) {
+	var req ModerationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	rule := models.ModerationRule{IsActive: true}
+	if err := applyModerationRuleRequest(&rule, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	h.reloadModeration()
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateModerationRule 更新审核规则，保存后立即生效
+func (h *AIHandler) UpdateModerationRule(c *gin.Context) {
+	var rule models.ModerationRule
+	if err := h.db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+		return
+	}
+
+	var req ModerationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := applyModerationRuleRequest(&rule, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	h.reloadModeration()
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteModerationRule 删除审核规则
+func (h *AIHandler) DeleteModerationRule(c *gin.Context) {
+	var rule models.ModerationRule
+	if err := h.db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+		return
+	}
+
+	if err := h.db.Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	h.reloadModeration()
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
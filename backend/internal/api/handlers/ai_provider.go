@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/pkg/crypto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// providerTestTimeout 连通性测试的超时时间
+const providerTestTimeout = 30 * time.Second
+
+// ProviderRequest 创建或更新Provider的请求
+// APIKey为nil时保留原密钥，为空字符串时清除密钥；IsActive为nil时创建默认启用、更新保持不变
+// Temperature为nil时使用模型的默认温度，0会原样发送
+type ProviderRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Kind        string            `json:"kind"`
+	DisplayName string            `json:"display_name"`
+	APIEndpoint string            `json:"api_endpoint"`
+	ModelName   string            `json:"model_name"`
+	MaxTokens   int               `json:"max_tokens"`
+	Temperature *float32          `json:"temperature"`
+	Options     map[string]string `json:"options"`
+	APIKey      *string           `json:"api_key"`
+	IsActive    *bool             `json:"is_active"`
+}
+
+// ProviderView 返回给管理后台的Provider信息，密钥只显示末尾几位
+type ProviderView struct {
+	models.AIProvider
+	APIKeyMasked string `json:"api_key_masked"`
+	// Loaded 是否已加载到AIManager，启用但配置错误的Provider不会被加载
+	Loaded bool `json:"loaded"`
+}
+
+// providerConfig 将数据库中的Provider配置转换为AI服务配置
+func providerConfig(p models.AIProvider) ai.ProviderConfig {
+	return ai.ProviderConfig{
+		ID:          p.ID,
+		Name:        p.Name,
+		Kind:        p.ProviderKind(),
+		APIURL:      p.APIEndpoint,
+		APIKey:      decryptAPIKey(p),
+		Model:       p.ModelName,
+		MaxTokens:   p.MaxTokens,
+		Temperature: p.Temperature,
+		Options:     p.Options,
+	}
+}
+
+// decryptAPIKey 解密API密钥，本地Provider（如ollama）可以没有密钥
+func decryptAPIKey(p models.AIProvider) string {
+	if p.APIKeyEncrypted == "" {
+		return ""
+	}
+	decrypted, err := crypto.Decrypt(p.APIKeyEncrypted)
+	if err != nil {
+		log.Printf("[AIHandler] 解密Provider %s 的API密钥失败: %v", p.Name, err)
+		// 尝试直接使用（可能是未加密的）
+		return p.APIKeyEncrypted
+	}
+	return decrypted
+}
+
+// maskAPIKey 遮盖API密钥，只保留末尾4位
+func maskAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// newProviderView 构建Provider的展示信息
+func (h *AIHandler) newProviderView(p models.AIProvider) ProviderView {
+	return ProviderView{
+		AIProvider:   p,
+		APIKeyMasked: maskAPIKey(decryptAPIKey(p)),
+		Loaded:       h.aiManager.HasProvider(p.Name),
+	}
+}
+
+// providerName 将路径参数解析为Provider名称
+// 参数为数字且数据库中存在对应记录时使用记录的名称，否则视为名称（如环境变量加载的Provider）
+func (h *AIHandler) providerName(param string) string {
+	if id, err := strconv.ParseUint(param, 10, 64); err == nil {
+		var p models.AIProvider
+		if err := h.db.Select("name").First(&p, id).Error; err == nil {
+			return p.Name
+		}
+	}
+	return param
+}
+
+// invalidateModelCache 清空模型列表缓存
+func (h *AIHandler) invalidateModelCache() {
+	h.cacheMu.Lock()
+	h.modelCache = nil
+	h.modelCacheTime = time.Time{}
+	h.cacheMu.Unlock()
+}
+
+// syncProvider 将保存后的Provider热加载到AIManager
+// previousName为更新前的名称，改名时移除旧实例；停用的Provider只移除
+// 启用的Provider原地替换，编辑默认Provider不会改变默认Provider
+func (h *AIHandler) syncProvider(p models.AIProvider, previousName string) error {
+	h.invalidateModelCache()
+	if !p.IsActive {
+		if previousName != "" && previousName != p.Name {
+			h.aiManager.RemoveProvider(previousName)
+		}
+		h.aiManager.RemoveProvider(p.Name)
+		return nil
+	}
+	return h.aiManager.ReplaceProvider(previousName, providerConfig(p))
+}
+
+// applyProviderRequest 将请求内容写入Provider记录，并校验配置能否创建Provider
+func applyProviderRequest(p *models.AIProvider, req *ProviderRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Kind == "" {
+		req.Kind = p.Kind
+	}
+	if req.Kind == "" {
+		req.Kind = req.Name
+	}
+	if _, exists := ai.LookupKind(req.Kind); !exists {
+		return errors.New("未知的Provider类型: " + req.Kind)
+	}
+	// MaxTokens是上下文预算而不是回复长度，这里只校验不为负数
+	settings := ai.GenerationSettings{Temperature: req.Temperature, MaxTokens: req.MaxTokens}
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	p.Name = req.Name
+	p.Kind = req.Kind
+	p.DisplayName = req.DisplayName
+	p.APIEndpoint = req.APIEndpoint
+	p.ModelName = req.ModelName
+	p.MaxTokens = req.MaxTokens
+	p.Temperature = req.Temperature
+	p.Options = req.Options
+	if req.IsActive != nil {
+		p.IsActive = *req.IsActive
+	}
+	if req.APIKey != nil {
+		encrypted, err := crypto.Encrypt(strings.TrimSpace(*req.APIKey))
+		if err != nil {
+			return errors.New("加密API密钥失败")
+		}
+		p.APIKeyEncrypted = encrypted
+	}
+
+	// 保存前构建一次，提前发现缺少密钥或端点等配置错误
+	if _, err := ai.NewProvider(providerConfig(*p)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListProviders 获取全部Provider配置（包括停用的）和支持的Provider类型
+func (h *AIHandler) ListProviders(c *gin.Context) {
+	var providers []models.AIProvider
+	if err := h.db.Order("id").Find(&providers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	views := make([]ProviderView, 0, len(providers))
+	for _, p := range providers {
+		views = append(views, h.newProviderView(p))
+	}
+
+	kinds := make([]gin.H, 0)
+	for _, kind := range ai.RegisteredKinds() {
+		kinds = append(kinds, gin.H{
+			"kind":             kind.Kind,
+			"display_name":     kind.DisplayName,
+			"default_url":      kind.DefaultURL,
+			"default_model":    kind.DefaultModel,
+			"requires_api_key": kind.RequiresAPIKey,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": views, "kinds": kinds})
+}
+
+// CreateProvider 创建Provider，保存后立即生效
+func (h *AIHandler) CreateProvider(c *gin.Context) {
+	var req ProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	provider := models.AIProvider{IsActive: true}
+	if err := applyProviderRequest(&provider, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.AIProvider{}).Where("name = ?", provider.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Provider名称已存在"})
+		return
+	}
+
+	if err := h.db.Create(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败"})
+		return
+	}
+
+	if err := h.syncProvider(provider, ""); err != nil {
+		log.Printf("[AIHandler] 加载Provider %s 失败: %v", provider.Name, err)
+	}
+
+	c.JSON(http.StatusCreated, h.newProviderView(provider))
+}
+
+// UpdateProvider 更新Provider，保存后立即生效
+func (h *AIHandler) UpdateProvider(c *gin.Context) {
+	var provider models.AIProvider
+	if err := h.db.First(&provider, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider不存在"})
+		return
+	}
+	previousName := provider.Name
+
+	var req ProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := applyProviderRequest(&provider, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.AIProvider{}).Where("name = ? AND id <> ?", provider.Name, provider.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Provider名称已存在"})
+		return
+	}
+
+	if err := h.db.Save(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败"})
+		return
+	}
+
+	if err := h.syncProvider(provider, previousName); err != nil {
+		log.Printf("[AIHandler] 加载Provider %s 失败: %v", provider.Name, err)
+	}
+
+	c.JSON(http.StatusOK, h.newProviderView(provider))
+}
+
+// DeleteProvider 删除Provider，并从AIManager中移除
+func (h *AIHandler) DeleteProvider(c *gin.Context) {
+	var provider models.AIProvider
+	if err := h.db.First(&provider, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider不存在"})
+		return
+	}
+
+	if err := h.db.Delete(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	h.aiManager.RemoveProvider(provider.Name)
+	h.invalidateModelCache()
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// TestProvider 发送一条简短的消息测试Provider的连通性
+// 直接按数据库配置创建Provider，不经过降级链，停用的Provider也可以测试
+func (h *AIHandler) TestProvider(c *gin.Context) {
+	var provider models.AIProvider
+	if err := h.db.First(&provider, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider不存在"})
+		return
+	}
+
+	instance, err := ai.NewProvider(providerConfig(provider))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), providerTestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := instance.ChatCompletion(ctx, &ai.ChatCompletionRequest{
+		Messages:  []ai.ChatMessage{{Role: "user", Content: "ping，请只回复 pong"}},
+		MaxTokens: 16,
+	})
+	latency := time.Since(start).Milliseconds()
+	if err != nil {
+		log.Printf("[AIHandler] 测试Provider %s 失败: %v", provider.Name, err)
+		c.JSON(http.StatusOK, gin.H{"success": false, "latency_ms": latency, "error": err.Error()})
+		return
+	}
+
+	reply := ""
+	if len(resp.Choices) > 0 {
+		reply = resp.Choices[0].Message.Content
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"latency_ms": latency,
+		"model":      resp.Model,
+		"reply":      reply,
+		"usage":      resp.Usage,
+	})
+}
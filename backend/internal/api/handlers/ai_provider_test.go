@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"personal-website/internal/models"
+)
+
+// 测试Provider请求的生成参数校验：温度超出范围或预算为负数时拒绝，0温度原样保存
+func TestApplyProviderRequest_Settings(t *testing.T) {
+	float32Ptr := func(v float32) *float32 { return &v }
+
+	testCases := []struct {
+		name    string
+		req     ProviderRequest
+		wantErr bool
+	}{
+		{name: "zero temperature", req: ProviderRequest{Temperature: float32Ptr(0)}},
+		{name: "unset temperature", req: ProviderRequest{}},
+		{name: "negative temperature", req: ProviderRequest{Temperature: float32Ptr(-0.5)}, wantErr: true},
+		{name: "temperature too high", req: ProviderRequest{Temperature: float32Ptr(5)}, wantErr: true},
+		{name: "negative max_tokens", req: ProviderRequest{MaxTokens: -1}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.Name = "ollama"
+			tc.req.APIEndpoint = "http://localhost:11434"
+			var p models.AIProvider
+			err := applyProviderRequest(&p, &tc.req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("applyProviderRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err == nil && p.Temperature != tc.req.Temperature {
+				t.Errorf("Expected temperature %v kept, got %v", tc.req.Temperature, p.Temperature)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/rag"
+)
+
+// retrievalTimeout 检索文章的超时时间，超时后不注入文章直接回答
+const retrievalTimeout = 3 * time.Second
+
+// ArticleIndex 获取文章检索服务，供文章管理在发布和更新后重建索引
+func (h *AIHandler) ArticleIndex() *rag.Service {
+	return h.articleIndex
+}
+
+// retrieveArticles 检索与问题相关的文章片段
+// 返回注入上下文的系统消息和引用的文章，未启用或检索失败时返回nil
+func (h *AIHandler) retrieveArticles(ctx context.Context, message string) (*ai.ChatMessage, []rag.Source) {
+	if h.articleIndex == nil || strings.TrimSpace(message) == "" {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, retrievalTimeout)
+	defer cancel()
+
+	result, err := h.articleIndex.Search(ctx, message, rag.DefaultTopK)
+	if err != nil {
+		log.Printf("[AIHandler] 检索文章失败，跳过: %v", err)
+		return nil, nil
+	}
+
+	msg, ok := result.ContextMessage()
+	if !ok {
+		return nil, nil
+	}
+	return &msg, result.Sources()
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChatStream 以Server-Sent Events流式返回聊天回复
+// 事件顺序: meta(对话信息) -> delta(增量内容，多次) -> done(完整响应) 或 error
+// 每凑齐一条按"$"切分的聊天气泡时额外发送segment事件，客户端可以直接按气泡展示
+// 用户消息和AI回复在流结束后统一保存
+// AI回复在输出过程中按关键词和正则规则审核，末尾保留一段内容暂不输出，命中block规则时立即中止输出，done事件中的回复为固定的提示
+// 大模型分类在流结束后执行，回复被遮盖或替换时done事件中的moderation不为空，客户端应以done事件中的回复为准
+// 回复末尾保留泄露判定阈值长度的内容暂不输出，开始照搬角色的系统提示时立即中止输出，done事件中的回复为角色的拒绝话术
+// 用户消息在调用AI之前保存，meta事件中的parent_id为该消息，调用失败时可以通过 RetryMessage 重试
+// 访客中途断开时已输出的部分回复保存为cancelled的消息
+// 请求中设置regenerate或edit_message_id时，与 Regenerate、EditMessage 一样在对话树中产生新分支
+func (h *AIHandler) ChatStream(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	session := h.prepareChat(c, &req)
+	if session == nil {
+		return
+	}
+	if !h.saveUserMessage(c, &req, session) {
+		return
+	}
+
+	// 设置SSE响应头，X-Accel-Buffering用于关闭nginx的代理缓冲
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.SSEvent("meta", gin.H{
+		"session_id":      req.SessionID,
+		"conversation_id": req.ConversationID,
+		"parent_id":       session.userMessageID,
+	})
+	c.Writer.Flush()
+
+	var segmenter ai.Segmenter
+	segmentIndex := 0
+	emitSegment := func(text string) {
+		segment := ai.NewSegment(text)
+		c.SSEvent("segment", gin.H{"index": segmentIndex, "content": segment.Content, "delay_ms": segment.DelayMs})
+		segmentIndex++
+	}
+
+	// partial 已输出给访客的内容，访客中途断开时保存
+	var partial strings.Builder
+	emitDelta := func(delta string) {
+		partial.WriteString(delta)
+		c.SSEvent("delta", gin.H{"content": delta})
+		for _, text := range segmenter.Feed(delta) {
+			emitSegment(text)
+		}
+	}
+
+	leakGuard := &streamLeakGuard{detector: session.leakDetector}
+	replyStream := h.moderation.Stream()
+	result, err := h.aiManager.ChatCompletionStreamWithTools(h.chatContext(c, &req, session), session.provider, session.aiReq, h.toolbox, func(delta string) error {
+		delta, err := leakGuard.feed(delta)
+		if err != nil {
+			return err
+		}
+		delta, ok := replyStream.Feed(c.Request.Context(), delta)
+		if !ok {
+			return errReplyBlocked
+		}
+		if delta == "" {
+			return c.Request.Context().Err()
+		}
+		emitDelta(delta)
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if errors.Is(err, errPromptLeaked) {
+		// 回复开始照搬系统提示，中止输出并用拒绝话术作为完整回复
+		log.Printf("[AIHandler] 会话 %s 的流式回复泄露了角色 %s 的系统提示，已中止输出", req.SessionID, session.character.Name)
+		result, err = refusalResult(session), nil
+		segmenter = ai.Segmenter{}
+	}
+	if errors.Is(err, errReplyBlocked) {
+		// 回复命中block规则，中止输出并用固定的提示作为完整回复
+		session.replyModeration = replyStream.Result()
+		log.Printf("[AIHandler] 对话 %d 的流式回复被审核拦截，已中止输出: %s", session.conversation.ID, session.replyModeration.Reason())
+		result, err = textResult(session, replyBlockedMessage), nil
+		segmenter = ai.Segmenter{}
+	}
+	if err != nil && c.Request.Context().Err() != nil && partial.Len() > 0 {
+		// 访客中途断开，保存已输出的部分回复，审核不再受请求取消的影响
+		log.Printf("[AIHandler] 会话 %s 在生成中途断开，保存已输出的部分回复", req.SessionID)
+		partialResult := textResult(session, partial.String())
+		h.guardReply(&req, session, partialResult.Response)
+		h.moderateReply(context.WithoutCancel(h.chatContext(c, &req, session)), session, partialResult.Response)
+		h.saveExchange(c, &req, session, partialResult, models.MessageStatusCancelled)
+		return
+	}
+	if err != nil {
+		log.Printf("[AIHandler] AI流式调用失败: %v", err)
+		h.failExchange(c, session, err)
+		message := "AI服务暂时不可用，请稍后重试"
+		if errors.Is(err, ai.ErrVisionUnsupported) {
+			message = visionUnsupportedMessage
+		}
+		c.SSEvent("error", gin.H{"error": message, "message_id": session.userMessageID})
+		c.Writer.Flush()
+		return
+	}
+
+	resp := result.Response
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		h.failExchange(c, session, errEmptyReply)
+		c.SSEvent("error", gin.H{"error": errEmptyReply.Error(), "message_id": session.userMessageID})
+		c.Writer.Flush()
+		return
+	}
+
+	h.guardReply(&req, session, resp)
+	if !session.replyModeration.Blocked() {
+		h.moderateReply(h.chatContext(c, &req, session), session, resp)
+	}
+	// 输出末尾保留的内容在完整检查后补发，回复被替换时不再补发
+	// 遮盖按字符替换，已输出的内容与最终回复的开头字符数相同
+	if !session.replyRefused && !session.replyModeration.Blocked() {
+		reply := []rune(resp.Choices[0].Message.Content)
+		if sent := utf8.RuneCountInString(partial.String()); sent < len(reply) {
+			emitDelta(string(reply[sent:]))
+		}
+	}
+	if last := segmenter.Flush(); last != "" {
+		emitSegment(last)
+	}
+
+	h.saveExchange(c, &req, session, result, models.MessageStatusCompleted)
+
+	c.SSEvent("done", newChatResponse(&req, session, resp))
+	c.Writer.Flush()
+}
+
+// textResult 用指定内容构造AI回复，用于流式输出中止后保存和返回
+func textResult(session *chatSession, content string) *ai.ToolResult {
+	return &ai.ToolResult{Response: &ai.ChatCompletionResponse{
+		Provider: session.provider,
+		Choices: []ai.ChatChoice{{
+			Message:      ai.ChatMessage{Role: "assistant", Content: content},
+			FinishReason: "stop",
+		}},
+	}}
+}
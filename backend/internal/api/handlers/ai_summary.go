@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"time"
+)
+
+// summaryTimeout 后台生成摘要的超时时间
+const summaryTimeout = 60 * time.Second
+
+// titleTimeout 后台生成标题的超时时间
+const titleTimeout = 30 * time.Second
+
+// refreshSummary 将较早的消息压缩进对话的滚动摘要
+// 在后台goroutine中执行，同一对话同时只会有一个摘要任务
+func (h *AIHandler) refreshSummary(conversationID uint, messages []models.ChatMessage) {
+	if _, running := h.summarizing.LoadOrStore(conversationID, true); running {
+		return
+	}
+	defer h.summarizing.Delete(conversationID)
+
+	// 重新读取对话，其他请求可能已经更新了摘要
+	var conversation models.Conversation
+	if err := h.db.First(&conversation, conversationID).Error; err != nil {
+		log.Printf("[AIHandler] 读取对话 %d 失败，跳过摘要: %v", conversationID, err)
+		return
+	}
+
+	pending := make([]ai.ChatMessage, 0, len(messages))
+	var lastID uint
+	for _, msg := range messages {
+		if msg.ID <= conversation.SummarizedUntilID {
+			continue
+		}
+		pending = append(pending, ai.ChatMessage{Role: msg.MessageType, Content: msg.Content})
+		lastID = msg.ID
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+	ctx = ai.WithUsageLabels(ctx, ai.UsageLabels{
+		Purpose:        ai.PurposeSummary,
+		SessionID:      conversation.SessionID,
+		ConversationID: conversationID,
+	})
+
+	summary, err := h.summarizer.Summarize(ctx, conversation.Summary, pending)
+	if err != nil {
+		log.Printf("[AIHandler] 生成对话 %d 的摘要失败: %v", conversationID, err)
+		return
+	}
+
+	// 使用UpdateColumns，不改变对话的更新时间
+	if err := h.db.Model(&models.Conversation{}).Where("id = ?", conversationID).UpdateColumns(map[string]interface{}{
+		"summary":             summary,
+		"summarized_until_id": lastID,
+	}).Error; err != nil {
+		log.Printf("[AIHandler] 保存对话 %d 的摘要失败: %v", conversationID, err)
+		return
+	}
+
+	log.Printf("[AIHandler] 对话 %d 的摘要已更新，压缩了 %d 条消息", conversationID, len(pending))
+}
+
+// generateTitle 根据第一轮对话生成标题
+// 只在标题仍为临时标题时更新，避免覆盖访客在生成期间修改的标题；失败时保留临时标题
+func (h *AIHandler) generateTitle(sessionID string, conversationID uint, placeholder, userMessage, reply string) {
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+	ctx = ai.WithUsageLabels(ctx, ai.UsageLabels{
+		Purpose:        ai.PurposeTitle,
+		SessionID:      sessionID,
+		ConversationID: conversationID,
+	})
+
+	title, err := h.summarizer.Title(ctx, userMessage, reply)
+	if err != nil {
+		log.Printf("[AIHandler] 生成对话 %d 的标题失败: %v", conversationID, err)
+		return
+	}
+
+	// 使用UpdateColumn，不改变对话的更新时间
+	if err := h.db.Model(&models.Conversation{}).
+		Where("id = ? AND title = ?", conversationID, placeholder).
+		UpdateColumn("title", title).Error; err != nil {
+		log.Printf("[AIHandler] 保存对话 %d 的标题失败: %v", conversationID, err)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/sitetools"
+
+	"github.com/gin-gonic/gin"
+)
+
+// toolContext 构建工具执行使用的context，附带访客信息
+func (h *AIHandler) toolContext(c *gin.Context) context.Context {
+	return sitetools.WithVisitor(c.Request.Context(), sitetools.Visitor{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// toAIMessage 将数据库中的聊天记录转换为发送给AI的消息
+// 历史消息中的图片只保留占位文本
+func toAIMessage(msg models.ChatMessage) ai.ChatMessage {
+	result := ai.ChatMessage{
+		Role:    msg.MessageType,
+		Content: msg.TextContent(),
+	}
+	switch msg.MessageType {
+	case "tool":
+		result.ToolCallID = msg.ToolCallID
+		result.Name = msg.ToolName
+	case "assistant":
+		if len(msg.ToolCalls) > 0 {
+			if err := json.Unmarshal(msg.ToolCalls, &result.ToolCalls); err != nil {
+				log.Printf("[AIHandler] 解析消息 %d 的工具调用失败: %v", msg.ID, err)
+			}
+		}
+	}
+	return result
+}
+
+// fromAIMessage 将工具调用过程中的消息转换为聊天记录（不含会话信息）
+func fromAIMessage(msg ai.ChatMessage) *models.ChatMessage {
+	result := &models.ChatMessage{
+		MessageType: msg.Role,
+		Content:     msg.Content,
+		ToolCallID:  msg.ToolCallID,
+		ToolName:    msg.Name,
+	}
+	if len(msg.ToolCalls) > 0 {
+		data, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			log.Printf("[AIHandler] 序列化工具调用失败: %v", err)
+		} else {
+			result.ToolCalls = data
+		}
+	}
+	return result
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/usage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultSessionDailyTokens 每个会话每天默认可以使用的Token数
+const defaultSessionDailyTokens = 200000
+
+// 费用统计默认的时间范围
+const (
+	defaultDailyReportDays     = 30
+	defaultMonthlyReportMonths = 12
+)
+
+// PriceRequest 设置模型价格的请求，价格为每百万Token的费用
+// Provider为空时对所有Provider生效；同一Provider和模型已有价格时覆盖
+type PriceRequest struct {
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model" binding:"required"`
+	InputPrice  float64 `json:"input_price" binding:"gte=0"`
+	OutputPrice float64 `json:"output_price" binding:"gte=0"`
+}
+
+// chatContext 构建聊天调用使用的context，附带访客信息和用量归属
+func (h *AIHandler) chatContext(c *gin.Context, req *ChatRequest, session *chatSession) context.Context {
+	return ai.WithUsageLabels(h.toolContext(c), ai.UsageLabels{
+		Purpose:        ai.PurposeChat,
+		SessionID:      req.SessionID,
+		ConversationID: req.ConversationID,
+		CharacterID:    session.character.ID,
+	})
+}
+
+// sessionQuotaFromEnv 从环境变量 AI_SESSION_DAILY_TOKENS 读取每个会话每天的Token配额，0表示不限制
+func sessionQuotaFromEnv() int64 {
+	value := os.Getenv("AI_SESSION_DAILY_TOKENS")
+	if value == "" {
+		return defaultSessionDailyTokens
+	}
+	quota, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || quota < 0 {
+		log.Printf("[AIHandler] AI_SESSION_DAILY_TOKENS格式错误，使用默认值 %d: %s", defaultSessionDailyTokens, value)
+		return defaultSessionDailyTokens
+	}
+	return quota
+}
+
+// checkSessionQuota 检查会话当天的Token用量是否超出配额，用量按用量账本中的记录统计，包括正在后台保存的记录
+// 超出配额时返回429并在Retry-After中给出到第二天的秒数；检查失败时放行，不影响正常聊天
+// 未通过检查时已写入错误响应，返回false
+func (h *AIHandler) checkSessionQuota(c *gin.Context, sessionID string) bool {
+	if h.sessionDailyTokens <= 0 {
+		return true
+	}
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少会话ID"})
+		return false
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	used, err := h.ledger.SessionTokens(sessionID, today)
+	if err != nil {
+		log.Printf("[AIHandler] 统计会话 %s 的用量失败: %v", sessionID, err)
+		return true
+	}
+	if used < h.sessionDailyTokens {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(today.AddDate(0, 0, 1).Sub(now).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "今天的对话额度已用完，请明天再来", "retry_after": retryAfter})
+	return false
+}
+
+// providerID 获取Provider在数据库中的ID，环境变量加载的Provider返回0
+func (h *AIHandler) providerID(name string) uint {
+	cfg, _ := h.aiManager.GetProviderConfig(name)
+	return cfg.ID
+}
+
+// reportRange 解析统计的时间范围，from和to为日期（包含to当天）
+// 未指定时按日统计最近30天，按月统计最近12个月
+func reportRange(c *gin.Context, period string) (time.Time, time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	to := today.AddDate(0, 0, 1)
+	if value := c.Query("to"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to格式应为 2006-01-02")
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+
+	from := to.AddDate(0, 0, -defaultDailyReportDays)
+	if period == usage.PeriodMonthly {
+		from = time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, 1-defaultMonthlyReportMonths, 0)
+	}
+	if value := c.Query("from"); value != "" {
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from格式应为 2006-01-02")
+		}
+		from = day
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from不能晚于to")
+	}
+	return from, to, nil
+}
+
+// GetUsageCosts 按日或按月统计AI调用的用量和费用
+// 查询参数: period=daily|monthly（默认daily），group_by=provider|character（默认provider），from/to为日期
+func (h *AIHandler) GetUsageCosts(c *gin.Context) {
+	period := c.DefaultQuery("period", usage.PeriodDaily)
+	groupBy := c.DefaultQuery("group_by", usage.GroupByProvider)
+	from, to, err := reportRange(c, period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := h.ledger.DailyRows(from, to)
+	if err != nil {
+		log.Printf("[AIHandler] 统计用量失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	report, err := usage.Rollup(rows, period, groupBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total usage.ReportRow
+	for _, row := range report {
+		total.Calls += row.Calls
+		total.Failures += row.Failures
+		total.PromptTokens += row.PromptTokens
+		total.CompletionTokens += row.CompletionTokens
+		total.TotalTokens += row.TotalTokens
+		total.Cost += row.Cost
+	}
+
+	response := gin.H{
+		"period":   period,
+		"group_by": groupBy,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"rows":     report,
+		"total":    total,
+	}
+
+	// 按角色分组时附带角色名称
+	if groupBy == usage.GroupByCharacter {
+		var characters []models.AICharacter
+		h.db.Select("id", "name").Find(&characters)
+		names := make(map[uint]string, len(characters))
+		for _, character := range characters {
+			names[character.ID] = character.Name
+		}
+		response["characters"] = names
+	}
+
+	if statuses, err := h.ledger.BudgetStatus(time.Now()); err == nil {
+		response["budget"] = statuses
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUsageBudget 获取预算配置和当天、当月的预算使用情况
+func (h *AIHandler) GetUsageBudget(c *gin.Context) {
+	statuses, err := h.ledger.BudgetStatus(time.Now())
+	if err != nil {
+		log.Printf("[AIHandler] 统计预算使用情况失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"budget": h.ledger.Budget(), "statuses": statuses})
+}
+
+// ListPrices 获取模型价格表
+func (h *AIHandler) ListPrices(c *gin.Context) {
+	var prices []models.ModelPrice
+	if err := h.db.Order("provider, model").Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices": prices})
+}
+
+// SetPrice 设置模型价格，同一Provider和模型已有价格时覆盖
+// 新价格只影响之后的调用，已记录的费用不变
+func (h *AIHandler) SetPrice(c *gin.Context) {
+	var req PriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	req.Provider = strings.TrimSpace(req.Provider)
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "模型名称不能为空"})
+		return
+	}
+
+	var price models.ModelPrice
+	err := h.db.Where("provider = ? AND model = ?", req.Provider, req.Model).First(&price).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		return
+	}
+	price.Provider = req.Provider
+	price.Model = req.Model
+	price.InputPrice = req.InputPrice
+	price.OutputPrice = req.OutputPrice
+	if err := h.db.Save(&price).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败"})
+		return
+	}
+
+	if err := h.ledger.ReloadPrices(); err != nil {
+		log.Printf("[AIHandler] 重新加载模型价格失败: %v", err)
+	}
+
+	c.JSON(http.StatusOK, price)
+}
+
+// DeletePrice 删除模型价格
+func (h *AIHandler) DeletePrice(c *gin.Context) {
+	var price models.ModelPrice
+	if err := h.db.First(&price, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "价格不存在"})
+		return
+	}
+
+	if err := h.db.Delete(&price).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+
+	if err := h.ledger.ReloadPrices(); err != nil {
+		log.Printf("[AIHandler] 重新加载模型价格失败: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
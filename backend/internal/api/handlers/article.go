@@ -1,21 +1,39 @@
 package handlers
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"strconv"
 	
 	"personal-website/internal/models"
+	"personal-website/internal/service/rag"
 	
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type ArticleHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	index *rag.Service
 }
 
-func NewArticleHandler(db *gorm.DB) *ArticleHandler {
-	return &ArticleHandler{db: db}
+// NewArticleHandler 创建文章处理器
+// index为文章检索服务，为nil时不维护AI聊天的文章索引
+func NewArticleHandler(db *gorm.DB, index *rag.Service) *ArticleHandler {
+	return &ArticleHandler{db: db, index: index}
+}
+
+// reindex 后台重建文章索引，向量化较慢，不阻塞响应
+func (h *ArticleHandler) reindex(article models.Article) {
+	if h.index == nil {
+		return
+	}
+	go func() {
+		if err := h.index.IndexArticle(context.Background(), &article); err != nil {
+			log.Printf("[ArticleHandler] 文章 %d 建立索引失败: %v", article.ID, err)
+		}
+	}()
 }
 
 // List 获取文章列表
@@ -76,6 +94,8 @@ func (h *ArticleHandler) Create(c *gin.Context) {
 		return
 	}
 
+	h.reindex(article)
+
 	c.JSON(http.StatusCreated, article)
 }
 
@@ -99,6 +119,8 @@ func (h *ArticleHandler) Update(c *gin.Context) {
 		return
 	}
 
+	h.reindex(article)
+
 	c.JSON(http.StatusOK, article)
 }
 
@@ -111,5 +133,11 @@ func (h *ArticleHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	if articleID, err := strconv.ParseUint(id, 10, 64); err == nil {
+		if err := h.index.RemoveArticle(uint(articleID)); err != nil {
+			log.Printf("[ArticleHandler] 删除文章 %d 的索引失败: %v", articleID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
@@ -1,9 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/internal/service/chatexport"
+	"personal-website/internal/service/guard"
+	"personal-website/internal/service/moderation"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,17 +22,18 @@ import (
 
 // ConversationHandler 对话管理处理器
 type ConversationHandler struct {
-	db *gorm.DB
+	db         *gorm.DB
+	moderation *moderation.Service
 }
 
-// NewConversationHandler 创建对话处理器
-func NewConversationHandler(db *gorm.DB) *ConversationHandler {
-	return &ConversationHandler{db: db}
+// NewConversationHandler 创建对话处理器，moderationService用于审核导入的访客消息
+func NewConversationHandler(db *gorm.DB, moderationService *moderation.Service) *ConversationHandler {
+	return &ConversationHandler{db: db, moderation: moderationService}
 }
 
-// List 获取对话列表
+// List 获取当前会话的对话列表，管理员可以通过session_id参数查看任意会话
 func (h *ConversationHandler) List(c *gin.Context) {
-	sessionID := c.Query("session_id")
+	sessionID := querySession(c)
 	if sessionID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少session_id参数"})
 		return
@@ -39,24 +50,28 @@ func (h *ConversationHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"conversations": conversations})
 }
 
-// Create 创建新对话
+// Create 在当前会话下创建新对话
 func (h *ConversationHandler) Create(c *gin.Context) {
 	var req struct {
-		SessionID string `json:"session_id" binding:"required"`
-		Title     string `json:"title"`
+		Title string `json:"title"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
 		return
 	}
+	sessionID := currentSession(c)
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": missingSessionMessage})
+		return
+	}
 
 	if req.Title == "" {
-		req.Title = "新对话"
+		req.Title = ai.DefaultTitle
 	}
 
 	conversation := models.Conversation{
-		SessionID: req.SessionID,
+		SessionID: sessionID,
 		Title:     req.Title,
 	}
 
@@ -69,44 +84,95 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 }
 
 
-// Get 获取单个对话详情（包含消息）
-func (h *ConversationHandler) Get(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+// loadConversation 读取路径参数指定的对话并校验访问权限，write为true时管理员也必须是对话所属的会话
+// 失败时已写入错误响应，返回false
+func (h *ConversationHandler) loadConversation(c *gin.Context, write bool) (models.Conversation, bool) {
+	var conversation models.Conversation
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对话ID"})
-		return
+		return conversation, false
 	}
 
-	var conversation models.Conversation
 	if err := h.db.First(&conversation, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "对话不存在"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话失败"})
 		}
+		return conversation, false
+	}
+	if !canAccessConversation(c, conversation, write) {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbiddenConversationMessage})
+		return conversation, false
+	}
+	return conversation, true
+}
+
+// Get 获取单个对话详情（包含消息）
+func (h *ConversationHandler) Get(c *gin.Context) {
+	conversation, ok := h.loadConversation(c, false)
+	if !ok {
 		return
 	}
 
-	// 获取对话消息
-	var messages []models.ChatMessage
-	h.db.Where("conversation_id = ?", id).Order("created_at ASC").Find(&messages)
+	// 获取当前分支的消息
+	tree, err := loadConversationTree(h.db, conversation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话失败"})
+		return
+	}
+	path := tree.Path(conversation.ActiveLeafID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"conversation": conversation,
-		"messages":     messages,
+		"messages":     visibleMessages(path),
+		"branches":     tree.Siblings(path),
 	})
 }
 
-// Update 更新对话标题
-func (h *ConversationHandler) Update(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+// SwitchBranch 切换对话的当前分支
+// message_id为要切换到的消息（通常是某条消息的兄弟分支），切换后显示该分支下最近一次的对话
+func (h *ConversationHandler) SwitchBranch(c *gin.Context) {
+	var req struct {
+		MessageID uint `json:"message_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	conversation, ok := h.loadConversation(c, true)
+	if !ok {
+		return
+	}
+
+	tree, err := loadConversationTree(h.db, conversation.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对话ID"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话失败"})
+		return
+	}
+	if _, ok := tree.Message(req.MessageID); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "消息不属于该对话"})
+		return
+	}
+
+	path := tree.Path(tree.LatestLeaf(req.MessageID))
+	if err := h.db.Model(&conversation).UpdateColumns(activeBranchUpdates(conversation, path)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "切换分支失败"})
 		return
 	}
+	h.db.First(&conversation, conversation.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation": conversation,
+		"messages":     visibleMessages(path),
+		"branches":     tree.Siblings(path),
+	})
+}
 
+// Update 更新对话标题
+func (h *ConversationHandler) Update(c *gin.Context) {
 	var req struct {
 		Title string `json:"title" binding:"required"`
 	}
@@ -116,7 +182,12 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Model(&models.Conversation{}).Where("id = ?", id).Updates(map[string]interface{}{
+	conversation, ok := h.loadConversation(c, true)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Model(&conversation).Updates(map[string]interface{}{
 		"title":      req.Title,
 		"updated_at": time.Now(),
 	}).Error; err != nil {
@@ -129,24 +200,22 @@ func (h *ConversationHandler) Update(c *gin.Context) {
 
 // Delete 删除对话
 func (h *ConversationHandler) Delete(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的对话ID"})
+	conversation, ok := h.loadConversation(c, true)
+	if !ok {
 		return
 	}
 
 	tx := h.db.Begin()
 
 	// 删除对话消息
-	if err := tx.Where("conversation_id = ?", id).Delete(&models.ChatMessage{}).Error; err != nil {
+	if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&models.ChatMessage{}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除消息失败"})
 		return
 	}
 
 	// 删除对话
-	if err := tx.Delete(&models.Conversation{}, id).Error; err != nil {
+	if err := tx.Delete(&conversation).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除对话失败"})
 		return
@@ -155,3 +224,232 @@ func (h *ConversationHandler) Delete(c *gin.Context) {
 	tx.Commit()
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
+
+// Export 导出对话的当前分支
+// 查询参数format: markdown、json（默认）或 jsonl，json格式可以通过 Import 重新导入
+func (h *ConversationHandler) Export(c *gin.Context) {
+	conversation, ok := h.loadConversation(c, false)
+	if !ok {
+		return
+	}
+
+	tree, err := loadConversationTree(h.db, conversation.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取对话失败"})
+		return
+	}
+	path := tree.Path(conversation.ActiveLeafID)
+
+	// 回复消息显示角色名称
+	characterIDs := make([]uint, 0)
+	for _, msg := range path {
+		if msg.CharacterID != 0 {
+			characterIDs = append(characterIDs, msg.CharacterID)
+		}
+	}
+	var characters []models.AICharacter
+	if len(characterIDs) > 0 {
+		h.db.Select("id", "name").Where("id IN ?", characterIDs).Find(&characters)
+	}
+	names := make(map[uint]string, len(characters))
+	for _, character := range characters {
+		names[character.ID] = character.Name
+	}
+
+	doc := chatexport.FromConversation(conversation, path, names)
+	filename := fmt.Sprintf("conversation-%d", conversation.ID)
+	switch c.DefaultQuery("format", "json") {
+	case "markdown", "md":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.md"`, filename))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(doc.Markdown()))
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, doc)
+	case "jsonl":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.jsonl"`, filename))
+		c.Header("Content-Type", "application/jsonl; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := doc.WriteJSONL(c.Writer); err != nil {
+			log.Printf("[ConversationHandler] 导出对话 %d 失败: %v", conversation.ID, err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式，可选 markdown、json、jsonl"})
+	}
+}
+
+// 导入限制
+const (
+	// maxImportBytes 导入文件的最大字节数
+	maxImportBytes = 20 << 20
+	// maxImportConversations 单次导入的最大对话数
+	maxImportConversations = 500
+	// maxImportTitleRunes 导入对话标题的最大字符数
+	maxImportTitleRunes = 100
+)
+
+// errImportBlocked 导入的访客消息命中block规则
+var errImportBlocked = errors.New("导入的对话包含不当内容，请修改后重试")
+
+// Import 导入对话到当前会话
+// 支持本站导出的JSON和ChatGPT导出的 conversations.json，可以上传文件（字段file）或直接提交JSON
+// 没有消息的对话跳过，超长的消息截断；访客消息与聊天输入一样经过审核和注入检测，命中block规则时拒绝整个导入
+func (h *ConversationHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	sessionID := currentSession(c)
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": missingSessionMessage})
+		return
+	}
+
+	data, err := readImportData(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取导入文件失败，文件不能超过20MB"})
+		return
+	}
+
+	parsed, err := chatexport.Parse(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	docs := make([]chatexport.Document, 0, len(parsed))
+	for _, doc := range parsed {
+		if len(doc.Messages) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "没有可以导入的对话"})
+		return
+	}
+	if len(docs) > maxImportConversations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多导入%d个对话", maxImportConversations)})
+		return
+	}
+
+	// 按名称匹配角色，找不到时回复消息不关联角色
+	var characters []models.AICharacter
+	h.db.Select("id", "name").Find(&characters)
+	characterIDs := make(map[string]uint, len(characters))
+	for _, character := range characters {
+		characterIDs[character.Name] = character.ID
+	}
+
+	conversationIDs := make([]uint, 0, len(docs))
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for _, doc := range docs {
+			id, err := h.importDocument(tx, doc, sessionID, c.ClientIP(), characterIDs)
+			if err != nil {
+				return err
+			}
+			conversationIDs = append(conversationIDs, id)
+		}
+		return nil
+	})
+	if errors.Is(err, errImportBlocked) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[ConversationHandler] 导入对话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导入对话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"imported":         len(conversationIDs),
+		"conversation_ids": conversationIDs,
+	})
+}
+
+// readImportData 读取导入内容，优先读取上传的文件，否则读取请求体
+func readImportData(c *gin.Context) ([]byte, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, maxImportBytes))
+	}
+	return io.ReadAll(c.Request.Body)
+}
+
+// importDocument 将一个导入的对话保存为对话和按顺序相连的消息
+func (h *ConversationHandler) importDocument(tx *gorm.DB, doc chatexport.Document, sessionID, userIP string, characterIDs map[string]uint) (uint, error) {
+	title := strings.TrimSpace(doc.Title)
+	if title == "" {
+		title = "导入的对话"
+	}
+	if runes := []rune(title); len(runes) > maxImportTitleRunes {
+		title = string(runes[:maxImportTitleRunes])
+	}
+
+	conversation := models.Conversation{SessionID: sessionID, Title: title}
+	if !doc.CreatedAt.IsZero() {
+		conversation.CreatedAt = doc.CreatedAt
+	}
+	if err := tx.Create(&conversation).Error; err != nil {
+		return 0, err
+	}
+
+	var parentID uint
+	for _, msg := range doc.Messages {
+		// 图片地址需要与聊天附件同样校验，不合法时丢弃图片
+		attachments := models.Attachments(msg.Attachments)
+		if err := validateAttachments(attachments); err != nil {
+			attachments = nil
+		}
+		message := models.ChatMessage{
+			ConversationID: conversation.ID,
+			ParentID:       parentID,
+			SessionID:      sessionID,
+			UserIP:         userIP,
+			MessageType:    msg.Role,
+			Content:        ai.TruncateRunes(msg.Content, maxMessageLength),
+			Attachments:    attachments,
+			Imported:       true,
+			CreatedAt:      msg.CreatedAt,
+		}
+		if msg.Role == "user" {
+			if err := h.moderateImported(tx.Statement.Context, sessionID, &message); err != nil {
+				return 0, err
+			}
+		}
+		// 只有本站导出的回复才按分隔符切分气泡，ChatGPT的回复中"$"可能是公式
+		if msg.Role == "assistant" && msg.Character != "" {
+			message.CharacterID = characterIDs[msg.Character]
+			message.Segments = ai.SplitSegmentTexts(msg.Content)
+		}
+		if err := tx.Create(&message).Error; err != nil {
+			return 0, err
+		}
+		parentID = message.ID
+	}
+
+	if err := tx.Model(&conversation).UpdateColumn("active_leaf_id", parentID).Error; err != nil {
+		return 0, err
+	}
+	return conversation.ID, nil
+}
+
+// moderateImported 审核导入的访客消息，与聊天输入一样遮盖mask规则命中的内容并标记注入话术
+// 导入可能包含大量消息，只使用关键词和正则规则
+func (h *ConversationHandler) moderateImported(ctx context.Context, sessionID string, msg *models.ChatMessage) error {
+	result := h.moderation.CheckRules(ctx, msg.Content)
+	if result.Blocked() {
+		log.Printf("[ConversationHandler] 会话 %s 导入的消息被审核拦截: %s", sessionID, result.Reason())
+		return errImportBlocked
+	}
+	msg.Content = result.Text
+	applyModeration(msg, result)
+	if detections := guard.DetectInjection(msg.Content); len(detections) > 0 {
+		flagMessage(msg, "guard:"+detectionRules(detections))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"personal-website/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// loadConversationTree 读取对话的全部消息（包括其他分支）并构建消息树
+func loadConversationTree(db *gorm.DB, conversationID uint) (*models.MessageTree, error) {
+	var messages []models.ChatMessage
+	if err := db.Where("conversation_id = ?", conversationID).Order("id ASC").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return models.NewMessageTree(messages), nil
+}
+
+// visibleMessages 过滤出展示给访客的消息
+func visibleMessages(messages []models.ChatMessage) []models.ChatMessage {
+	visible := make([]models.ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Visible() {
+			visible = append(visible, msg)
+		}
+	}
+	return visible
+}
+
+// onPath 判断消息是否在路径上
+func onPath(path []models.ChatMessage, id uint) bool {
+	for _, msg := range path {
+		if msg.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// activeBranchUpdates 切换当前分支时需要更新的对话字段
+// 摘要只对压缩时所在的分支有效，切换到不包含该消息的分支后清空，之后按需重新生成
+func activeBranchUpdates(conversation models.Conversation, path []models.ChatMessage) map[string]interface{} {
+	updates := map[string]interface{}{"active_leaf_id": uint(0)}
+	if len(path) > 0 {
+		updates["active_leaf_id"] = path[len(path)-1].ID
+	}
+	if conversation.SummarizedUntilID != 0 && !onPath(path, conversation.SummarizedUntilID) {
+		updates["summary"] = ""
+		updates["summarized_until_id"] = 0
+	}
+	return updates
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"personal-website/internal/models"
+	"personal-website/pkg/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 会话校验失败时返回的提示
+const (
+	missingSessionMessage        = "缺少会话令牌"
+	forbiddenConversationMessage = "无权访问该对话"
+)
+
+// SessionHandler 访客匿名会话处理器
+type SessionHandler struct {
+	signer *session.Signer
+}
+
+// NewSessionHandler 创建会话处理器
+func NewSessionHandler(signer *session.Signer) *SessionHandler {
+	return &SessionHandler{signer: signer}
+}
+
+// Create 签发新的匿名会话
+// 访客之后的聊天和对话请求需要在 X-Session-Token 头中携带返回的令牌，对话只能由创建它的会话访问
+func (h *SessionHandler) Create(c *gin.Context) {
+	sessionID, token, err := h.signer.Issue()
+	if err != nil {
+		log.Printf("[SessionHandler] 签发会话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":    sessionID,
+		"session_token": token,
+	})
+}
+
+// currentSession 当前请求的会话ID，由 SessionRequired 中间件校验令牌后写入
+// 只携带JWT的管理员请求为空
+func currentSession(c *gin.Context) string {
+	return c.GetString("session_id")
+}
+
+// isAdmin 当前请求是否由已登录的管理员发起
+func isAdmin(c *gin.Context) bool {
+	return c.GetBool("is_admin")
+}
+
+// querySession 查询接口使用的会话ID
+// 访客只能查询自己的会话，没有会话令牌的管理员可以通过session_id参数查询任意会话
+func querySession(c *gin.Context) string {
+	if sessionID := currentSession(c); sessionID != "" {
+		return sessionID
+	}
+	if isAdmin(c) {
+		return c.Query("session_id")
+	}
+	return ""
+}
+
+// canAccessConversation 当前请求是否可以访问对话
+// 对话只能由创建它的会话读写，管理员可以读取所有对话
+func canAccessConversation(c *gin.Context, conversation models.Conversation, write bool) bool {
+	if sessionID := currentSession(c); sessionID != "" && conversation.SessionID == sessionID {
+		return true
+	}
+	return !write && isAdmin(c)
+}
@@ -2,19 +2,39 @@ package handlers
 
 import (
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"os"
 	"path/filepath"
 	"time"
-	
+
 	"github.com/gin-gonic/gin"
 )
 
+// uploadRoot 上传文件的保存目录，返回的访问地址以 "/uploads" 开头
+const uploadRoot = "uploads"
+
 type UploadHandler struct{}
 
 func NewUploadHandler() *UploadHandler {
 	return &UploadHandler{}
 }
 
+// saveUpload 将上传的文件保存到uploads下的子目录，返回访问地址
+// subdir为空时保存在uploads根目录，name为保存的文件名
+func saveUpload(c *gin.Context, file *multipart.FileHeader, subdir, name string) (string, error) {
+	dir := filepath.Join(uploadRoot, subdir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	if err := c.SaveUploadedFile(file, path); err != nil {
+		return "", err
+	}
+	return "/" + filepath.ToSlash(path), nil
+}
+
 // Upload 文件上传
 func (h *UploadHandler) Upload(c *gin.Context) {
 	file, err := c.FormFile("file")
@@ -24,16 +44,14 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 	}
 
 	// 生成文件名
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d%s", time.Now().Unix(), ext)
-	filepath := filepath.Join("uploads", filename)
-
-	if err := c.SaveUploadedFile(file, filepath); err != nil {
+	filename := fmt.Sprintf("%d%s", time.Now().Unix(), filepath.Ext(file.Filename))
+	url, err := saveUpload(c, file, "", filename)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"url": "/" + filepath,
+		"url": url,
 	})
 }
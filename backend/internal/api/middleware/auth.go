@@ -26,11 +26,7 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte("your-secret-key-change-this"), nil
-		})
-
+		token, err := parseJWT(parts[1])
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证令牌"})
 			c.Abort()
@@ -45,3 +41,10 @@ func AuthRequired() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// parseJWT 解析并校验JWT
+func parseJWT(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte("your-secret-key-change-this"), nil
+	})
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"personal-website/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitConfig 一组路由的限流配置
+// Name用于日志和环境变量名，PerIP按客户端IP限流，PerSession按会话ID限流，未启用的维度不检查
+// 会话ID只来自 SessionRequired 校验过的令牌，按会话限流的路由需要放在 SessionRequired 之后
+type RateLimitConfig struct {
+	Name       string
+	PerIP      ratelimit.Rate
+	PerSession ratelimit.Rate
+}
+
+// RateLimitFromEnv 从环境变量读取限流配置，未设置或格式错误时使用默认值
+// 环境变量为 RATE_LIMIT_<NAME>_IP 和 RATE_LIMIT_<NAME>_SESSION，格式如 20/m，off表示不限流
+func RateLimitFromEnv(defaults RateLimitConfig) RateLimitConfig {
+	prefix := "RATE_LIMIT_" + strings.ToUpper(defaults.Name)
+	cfg := defaults
+	cfg.PerIP = rateFromEnv(prefix+"_IP", defaults.PerIP)
+	cfg.PerSession = rateFromEnv(prefix+"_SESSION", defaults.PerSession)
+	return cfg
+}
+
+// rateFromEnv 读取限流速率环境变量
+func rateFromEnv(key string, fallback ratelimit.Rate) ratelimit.Rate {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	rate, err := ratelimit.ParseRate(value)
+	if err != nil {
+		log.Printf("[RateLimit] 环境变量 %s 格式错误，使用默认值 %s: %v", key, fallback, err)
+		return fallback
+	}
+	return rate
+}
+
+// RateLimit 令牌桶限流中间件，同一个中间件实例用于多个路由时共享令牌桶
+// 响应带有 X-RateLimit-Limit、X-RateLimit-Remaining、X-RateLimit-Reset 头（取最严格的维度），
+// 超出限制时返回429和 Retry-After 头
+func RateLimit(cfg RateLimitConfig) gin.HandlerFunc {
+	return RateLimitWith(cfg.Name, ratelimit.NewLimiter(cfg.PerIP), ratelimit.NewLimiter(cfg.PerSession))
+}
+
+// RateLimitWith 使用已创建的限流器构建限流中间件，用于和其他调用方共享令牌桶
+// ipLimiter、sessionLimiter为nil时不检查对应维度
+func RateLimitWith(name string, ipLimiter, sessionLimiter *ratelimit.Limiter) gin.HandlerFunc {
+	log.Printf("[RateLimit] %s: 每IP %s，每会话 %s", name, ipLimiter.Rate(), sessionLimiter.Rate())
+
+	return func(c *gin.Context) {
+		checks := make([]ratelimit.Check, 0, 2)
+		if ipLimiter != nil {
+			checks = append(checks, ratelimit.Check{Limiter: ipLimiter, Key: c.ClientIP()})
+		}
+		if sessionLimiter != nil {
+			if sessionID := c.GetString("session_id"); sessionID != "" {
+				checks = append(checks, ratelimit.Check{Limiter: sessionLimiter, Key: sessionID})
+			}
+		}
+		if len(checks) == 0 {
+			c.Next()
+			return
+		}
+
+		// 全部维度都有令牌才放行，被拒绝的请求不占用其他维度的额度
+		result, rejected := ratelimit.AllowAll(checks...)
+		setRateLimitHeaders(c, result)
+		if rejected >= 0 {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			log.Printf("[RateLimit] %s: %s 请求过于频繁", name, checks[rejected].Key)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       fmt.Sprintf("请求过于频繁，请%d秒后再试", retryAfter),
+				"retry_after": retryAfter,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// setRateLimitHeaders 写入限流状态响应头，Reset为令牌补满所需的秒数
+func setRateLimitHeaders(c *gin.Context, result ratelimit.Result) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+// ceilSeconds 向上取整的秒数
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"personal-website/pkg/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SessionRequired 校验访客的匿名会话令牌
+// 令牌从 X-Session-Token 头或 session_token 查询参数读取，校验通过后会话ID写入上下文的session_id
+// 携带有效JWT的请求视为管理员，上下文的is_admin为true，可以不带会话令牌
+// 既没有有效的会话令牌也不是管理员时返回401
+func SessionRequired(signer *session.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin := false
+		if claims, ok := adminClaims(c); ok {
+			admin = true
+			c.Set("is_admin", true)
+			c.Set("user_id", claims["user_id"])
+			c.Set("username", claims["username"])
+		}
+
+		token := c.GetHeader("X-Session-Token")
+		if token == "" {
+			token = c.Query("session_token")
+		}
+		if token != "" {
+			sessionID, err := signer.Verify(token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "会话令牌无效，请重新获取"})
+				c.Abort()
+				return
+			}
+			c.Set("session_id", sessionID)
+		} else if !admin {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少会话令牌"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// adminClaims 请求携带有效JWT时返回其中的声明
+func adminClaims(c *gin.Context) (jwt.MapClaims, bool) {
+	tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return nil, false
+	}
+	token, err := parseJWT(tokenString)
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
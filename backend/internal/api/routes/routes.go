@@ -1,8 +1,12 @@
 package routes
 
 import (
+	"time"
+
 	"personal-website/internal/api/handlers"
 	"personal-website/internal/api/middleware"
+	"personal-website/pkg/ratelimit"
+	"personal-website/pkg/session"
 	
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,14 +18,42 @@ func Setup(r *gin.Engine, db *gorm.DB) {
 	r.Use(middleware.ErrorHandler()) // 错误处理
 	r.Use(middleware.CORS())        // 跨域支持
 	
+	// 留言的限流器同时用于AI代访客留言，两者共享每个IP的额度
+	guestbookRate := middleware.RateLimitFromEnv(middleware.RateLimitConfig{
+		Name:  "guestbook",
+		PerIP: ratelimit.Rate{Limit: 5, Period: time.Hour},
+	})
+	guestbookLimiter := ratelimit.NewLimiter(guestbookRate.PerIP)
+
 	// 初始化handlers
 	authHandler := handlers.NewAuthHandler(db)
-	articleHandler := handlers.NewArticleHandler(db)
+	aiHandler := handlers.NewAIHandler(db, guestbookLimiter)
+	articleHandler := handlers.NewArticleHandler(db, aiHandler.ArticleIndex())
 	projectHandler := handlers.NewProjectHandler(db)
 	messageHandler := handlers.NewMessageHandler(db)
 	uploadHandler := handlers.NewUploadHandler()
-	aiHandler := handlers.NewAIHandler(db)
-	conversationHandler := handlers.NewConversationHandler(db)
+	conversationHandler := handlers.NewConversationHandler(db, aiHandler.Moderation())
+
+	// 访客匿名会话：聊天和对话接口需要携带服务端签发的会话令牌，管理员凭JWT可以读取所有对话
+	sessions := session.NewSignerFromEnv()
+	sessionHandler := handlers.NewSessionHandler(sessions)
+	sessionRequired := middleware.SessionRequired(sessions)
+
+	// 限流：匿名可访问且有成本的接口按IP和会话限流，可通过 RATE_LIMIT_<NAME>_IP/SESSION 调整
+	loginLimit := middleware.RateLimit(middleware.RateLimitFromEnv(middleware.RateLimitConfig{
+		Name:  "login",
+		PerIP: ratelimit.Rate{Limit: 10, Period: time.Minute},
+	}))
+	guestbookLimit := middleware.RateLimitWith(guestbookRate.Name, guestbookLimiter, nil)
+	sessionLimit := middleware.RateLimit(middleware.RateLimitFromEnv(middleware.RateLimitConfig{
+		Name:  "session",
+		PerIP: ratelimit.Rate{Limit: 20, Period: time.Hour},
+	}))
+	chatLimit := middleware.RateLimit(middleware.RateLimitFromEnv(middleware.RateLimitConfig{
+		Name:       "chat",
+		PerIP:      ratelimit.Rate{Limit: 30, Period: time.Minute},
+		PerSession: ratelimit.Rate{Limit: 10, Period: time.Minute},
+	}))
 	
 	// API v1路由组
 	v1 := r.Group("/api/v1")
@@ -29,7 +61,7 @@ func Setup(r *gin.Engine, db *gorm.DB) {
 		// 认证相关
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/login", authHandler.Login)
+			auth.POST("/login", loginLimit, authHandler.Login)
 			auth.POST("/logout", authHandler.Logout)
 			auth.GET("/profile", middleware.AuthRequired(), authHandler.GetProfile)
 			auth.PUT("/profile", middleware.AuthRequired(), authHandler.UpdateProfile)
@@ -58,32 +90,74 @@ func Setup(r *gin.Engine, db *gorm.DB) {
 		// 留言功能
 		messages := v1.Group("/messages")
 		{
-			messages.POST("", messageHandler.Create)
+			messages.POST("", guestbookLimit, messageHandler.Create)
 			messages.GET("", middleware.AuthRequired(), messageHandler.List)
 			messages.PUT("/:id/read", middleware.AuthRequired(), messageHandler.MarkAsRead)
 		}
 		
 		// 文件上传
 		v1.POST("/upload", middleware.AuthRequired(), uploadHandler.Upload)
+
+		// 访客匿名会话
+		v1.POST("/session", sessionLimit, sessionHandler.Create)
 		
 		// AI聊天功能
 		ai := v1.Group("/ai")
 		{
 			ai.GET("/models", aiHandler.GetModels)
 			ai.GET("/characters", aiHandler.GetCharacters)
-			ai.POST("/chat", aiHandler.Chat)
-			ai.GET("/history", aiHandler.GetHistory)
-			ai.DELETE("/history", aiHandler.ClearHistory)
+			ai.GET("/characters/all", middleware.AuthRequired(), aiHandler.ListAllCharacters)
+			ai.POST("/characters", middleware.AuthRequired(), aiHandler.CreateCharacter)
+			ai.PUT("/characters/:id", middleware.AuthRequired(), aiHandler.UpdateCharacter)
+			ai.DELETE("/characters/:id", middleware.AuthRequired(), aiHandler.DeleteCharacter)
+			ai.POST("/characters/:id/activate", middleware.AuthRequired(), aiHandler.ActivateCharacter)
+			ai.POST("/characters/:id/deactivate", middleware.AuthRequired(), aiHandler.DeactivateCharacter)
+			ai.POST("/chat", sessionRequired, chatLimit, aiHandler.Chat)
+			ai.POST("/chat/stream", sessionRequired, chatLimit, aiHandler.ChatStream)
+			ai.POST("/chat/regenerate", sessionRequired, chatLimit, aiHandler.Regenerate)
+			ai.POST("/messages/:id/edit", sessionRequired, chatLimit, aiHandler.EditMessage)
+			ai.POST("/messages/:id/retry", sessionRequired, chatLimit, aiHandler.RetryMessage)
+			ai.POST("/messages/:id/feedback", sessionRequired, aiHandler.RateMessage)
+			ai.GET("/finetune/export", middleware.AuthRequired(), aiHandler.ExportFineTuning)
+			ai.POST("/attachments", sessionRequired, chatLimit, aiHandler.UploadAttachment)
+			ai.GET("/history", sessionRequired, aiHandler.GetHistory)
+			ai.DELETE("/history", sessionRequired, aiHandler.ClearHistory)
 			ai.POST("/reload", middleware.AuthRequired(), aiHandler.ReloadProviders)
+			ai.GET("/providers/:id/models", middleware.AuthRequired(), aiHandler.GetProviderModels)
+
+			// Provider管理
+			ai.GET("/providers", middleware.AuthRequired(), aiHandler.ListProviders)
+			ai.POST("/providers", middleware.AuthRequired(), aiHandler.CreateProvider)
+			ai.PUT("/providers/:id", middleware.AuthRequired(), aiHandler.UpdateProvider)
+			ai.DELETE("/providers/:id", middleware.AuthRequired(), aiHandler.DeleteProvider)
+			ai.POST("/providers/:id/test", middleware.AuthRequired(), aiHandler.TestProvider)
+
+			// 用量和费用
+			ai.GET("/usage/costs", middleware.AuthRequired(), aiHandler.GetUsageCosts)
+			ai.GET("/usage/budget", middleware.AuthRequired(), aiHandler.GetUsageBudget)
+			ai.GET("/prices", middleware.AuthRequired(), aiHandler.ListPrices)
+			ai.PUT("/prices", middleware.AuthRequired(), aiHandler.SetPrice)
+			ai.DELETE("/prices/:id", middleware.AuthRequired(), aiHandler.DeletePrice)
+
+			// 内容审核
+			ai.GET("/moderation/flagged", middleware.AuthRequired(), aiHandler.ListFlaggedMessages)
+			ai.POST("/moderation/messages/:id/review", middleware.AuthRequired(), aiHandler.ReviewMessage)
+			ai.GET("/moderation/rules", middleware.AuthRequired(), aiHandler.ListModerationRules)
+			ai.POST("/moderation/rules", middleware.AuthRequired(), aiHandler.CreateModerationRule)
+			ai.PUT("/moderation/rules/:id", middleware.AuthRequired(), aiHandler.UpdateModerationRule)
+			ai.DELETE("/moderation/rules/:id", middleware.AuthRequired(), aiHandler.DeleteModerationRule)
 		}
 
 		// 对话管理
-		conversations := v1.Group("/conversations")
+		conversations := v1.Group("/conversations", sessionRequired)
 		{
 			conversations.GET("", conversationHandler.List)
 			conversations.POST("", conversationHandler.Create)
+			conversations.POST("/import", conversationHandler.Import)
 			conversations.GET("/:id", conversationHandler.Get)
+			conversations.GET("/:id/export", conversationHandler.Export)
 			conversations.PUT("/:id", conversationHandler.Update)
+			conversations.PUT("/:id/branch", conversationHandler.SwitchBranch)
 			conversations.DELETE("/:id", conversationHandler.Delete)
 		}
 	}
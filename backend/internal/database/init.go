@@ -17,18 +17,32 @@ func InitDatabase(db *gorm.DB) error {
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Article{},
+		&models.ArticleChunk{},
 		&models.Project{},
 		&models.Message{},
 		&models.AICharacter{},
 		&models.AIProvider{},
 		&models.Conversation{},
 		&models.ChatMessage{},
+		&models.UsageRecord{},
+		&models.ModelPrice{},
+		&models.ModerationRule{},
 	); err != nil {
 		return err
 	}
 
 	log.Println("[Database] 数据库表结构迁移完成")
 
+	// 为旧的Provider配置补全kind字段
+	if err := backfillAIProviderKind(db); err != nil {
+		log.Printf("[Database] 补全Provider类型失败: %v", err)
+	}
+
+	// 为旧的线性对话建立消息树
+	if err := backfillChatMessageTree(db); err != nil {
+		log.Printf("[Database] 建立对话消息树失败: %v", err)
+	}
+
 	// 初始化默认管理员用户
 	if err := initDefaultAdmin(db); err != nil {
 		log.Printf("[Database] 初始化管理员失败: %v", err)
@@ -104,7 +118,10 @@ func initDefaultAICharacters(db *gorm.DB) error {
 请保持角色一致性，用冷娇但关心的语气回应用户。`,
 			PersonalityTags: models.StringArray{"冷娇", "成熟", "电波系", "外冷内热"},
 			GreetingMessage: "嗯$有什么事吗",
-			IsActive:        true,
+			// 话少，低温度短回复
+			Temperature: float32Ptr(0.4),
+			MaxTokens:   300,
+			IsActive:    true,
 		},
 		{
 			Name:        "虹语织",
@@ -122,7 +139,10 @@ func initDefaultAICharacters(db *gorm.DB) error {
 请保持角色一致性，用元气活泼但偶尔笨拙的语气回应用户。`,
 			PersonalityTags: models.StringArray{"元气", "笨蛋", "萝莉", "天然疯"},
 			GreetingMessage: "锵锵~织织上线啦！$主人有什么需要帮忙的吗？",
-			IsActive:        true,
+			// 活泼跳脱，高温度长回复
+			Temperature: float32Ptr(1.0),
+			MaxTokens:   1200,
+			IsActive:    true,
 		},
 	}
 
@@ -147,7 +167,8 @@ func initDefaultAIProviders(db *gorm.DB) error {
 		return nil
 	}
 
-	// 只创建DeepSeek Provider（默认且唯一）
+	// 默认创建DeepSeek Provider
+	created := 0
 	deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
 	if deepseekKey != "" {
 		encryptedKey, err := crypto.Encrypt(deepseekKey)
@@ -158,11 +179,12 @@ func initDefaultAIProviders(db *gorm.DB) error {
 
 		provider := models.AIProvider{
 			Name:            "deepseek",
+			Kind:            "deepseek",
 			DisplayName:     "DeepSeek Chat",
 			APIEndpoint:     "https://api.deepseek.com/v1",
 			ModelName:       "deepseek-chat",
 			MaxTokens:       4000,
-			Temperature:     0.7,
+			Temperature:     float32Ptr(0.7),
 			APIKeyEncrypted: encryptedKey,
 			IsActive:        true,
 		}
@@ -170,11 +192,96 @@ func initDefaultAIProviders(db *gorm.DB) error {
 		if err := db.Create(&provider).Error; err != nil {
 			log.Printf("[Database] 创建DeepSeek Provider失败: %v", err)
 		} else {
+			created++
 			log.Println("[Database] 创建Provider: DeepSeek (默认)")
 		}
-	} else {
-		log.Println("[Database] 警告: 未设置DEEPSEEK_API_KEY环境变量，AI功能将不可用")
 	}
 
+	// 本地Ollama不需要API密钥，配置了服务地址即创建
+	// 模型为空时由Provider从服务端自动选择
+	if ollamaURL := os.Getenv("OLLAMA_API_URL"); ollamaURL != "" {
+		provider := models.AIProvider{
+			Name:        "ollama",
+			Kind:        "ollama",
+			DisplayName: "Ollama（本地）",
+			APIEndpoint: ollamaURL,
+			ModelName:   os.Getenv("OLLAMA_MODEL"),
+			MaxTokens:   4000,
+			Temperature: float32Ptr(0.7),
+			IsActive:    true,
+		}
+
+		if err := db.Create(&provider).Error; err != nil {
+			log.Printf("[Database] 创建Ollama Provider失败: %v", err)
+		} else {
+			created++
+			log.Println("[Database] 创建Provider: Ollama")
+		}
+	}
+
+	if created == 0 {
+		log.Println("[Database] 警告: 未设置DEEPSEEK_API_KEY或OLLAMA_API_URL环境变量，AI功能将不可用")
+	}
+
+	return nil
+}
+
+// backfillAIProviderKind 为没有kind字段的旧Provider配置补全类型
+// 旧版本中名称即类型，因此直接使用名称填充
+func backfillAIProviderKind(db *gorm.DB) error {
+	result := db.Model(&models.AIProvider{}).
+		Where("kind = ? OR kind IS NULL", "").
+		Update("kind", gorm.Expr("name"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[Database] 补全了 %d 个Provider的类型", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillChatMessageTree 为旧版本的对话建立消息树
+// 旧对话的消息是线性的，按时间顺序依次作为下一条消息的父消息，最后一条作为当前分支
+func backfillChatMessageTree(db *gorm.DB) error {
+	var conversationIDs []uint
+	if err := db.Model(&models.ChatMessage{}).
+		Where("conversation_id IN (?)", db.Model(&models.Conversation{}).Select("id").Where("active_leaf_id = ?", 0)).
+		Distinct().
+		Pluck("conversation_id", &conversationIDs).Error; err != nil {
+		return err
+	}
+
+	for _, conversationID := range conversationIDs {
+		var messages []models.ChatMessage
+		if err := db.Select("id").Where("conversation_id = ?", conversationID).
+			Order("created_at ASC, id ASC").
+			Find(&messages).Error; err != nil {
+			return err
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := 1; i < len(messages); i++ {
+				if err := tx.Model(&messages[i]).UpdateColumn("parent_id", messages[i-1].ID).Error; err != nil {
+					return err
+				}
+			}
+			// 使用UpdateColumn，不改变对话的更新时间
+			return tx.Model(&models.Conversation{}).Where("id = ?", conversationID).
+				UpdateColumn("active_leaf_id", messages[len(messages)-1].ID).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(conversationIDs) > 0 {
+		log.Printf("[Database] 为 %d 个对话建立了消息树", len(conversationIDs))
+	}
 	return nil
 }
+
+// float32Ptr 返回float32指针，用于可选的数值配置
+func float32Ptr(v float32) *float32 {
+	return &v
+}
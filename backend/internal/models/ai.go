@@ -1,49 +1,193 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // AICharacter AI角色模型
 // 注意：StringArray类型定义在article.go中
+// PreferredProvider/FallbackProvider为角色使用的Provider名称，为空时使用默认Provider和全局降级链
+// Temperature/TopP为nil、MaxTokens为0、StopSequences为空时使用Provider的默认值
+// RefusalMessage为回复泄露系统提示时替换使用的拒绝话术，为空时使用默认话术
 type AICharacter struct {
-	ID              uint        `gorm:"primaryKey" json:"id"`
-	Name            string      `gorm:"unique;not null" json:"name"`
-	Description     string      `gorm:"type:text" json:"description"`
-	SystemPrompt    string      `gorm:"type:text;not null" json:"system_prompt"`
-	Avatar          string      `json:"avatar"`
-	PersonalityTags StringArray `gorm:"type:json" json:"personality_tags"`
-	GreetingMessage string      `gorm:"type:text" json:"greeting_message"`
-	IsActive        bool        `gorm:"default:true" json:"is_active"`
-	CreatedAt       time.Time   `json:"created_at"`
-	UpdatedAt       time.Time   `json:"updated_at"`
+	ID                uint        `gorm:"primaryKey" json:"id"`
+	Name              string      `gorm:"unique;not null" json:"name"`
+	Description       string      `gorm:"type:text" json:"description"`
+	SystemPrompt      string      `gorm:"type:text;not null" json:"system_prompt"`
+	Avatar            string      `json:"avatar"`
+	PersonalityTags   StringArray `gorm:"type:json" json:"personality_tags"`
+	GreetingMessage   string      `gorm:"type:text" json:"greeting_message"`
+	RefusalMessage    string      `gorm:"type:text" json:"refusal_message"`
+	PreferredProvider string      `gorm:"size:50" json:"preferred_provider"`
+	FallbackProvider  string      `gorm:"size:50" json:"fallback_provider"`
+	Temperature       *float32    `json:"temperature"`
+	TopP              *float32    `json:"top_p"`
+	MaxTokens         int         `json:"max_tokens"`
+	StopSequences     StringArray `gorm:"type:json" json:"stop_sequences"`
+	IsActive          bool        `json:"is_active"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
 }
 
 // AIProvider AI提供商配置
+// Name为实例名称（唯一），Kind为Provider类型，同一类型可以配置多个实例
+// Options为厂商差异配置，如 chat_path、auth_header，见 ai.CompatQuirks
+// MaxTokens为上下文Token预算，0表示使用默认预算；Temperature为nil时使用模型的默认温度
 type AIProvider struct {
 	ID              uint      `gorm:"primaryKey" json:"id"`
 	Name            string    `gorm:"unique;not null" json:"name"`
+	Kind            string    `gorm:"size:50;index" json:"kind"`
 	DisplayName     string    `json:"display_name"`
 	APIEndpoint     string    `json:"api_endpoint"`
 	ModelName       string    `json:"model_name"`
-	MaxTokens       int       `gorm:"default:4000" json:"max_tokens"`
-	Temperature     float32   `gorm:"default:0.7" json:"temperature"`
+	MaxTokens       int       `json:"max_tokens"`
+	Temperature     *float32  `json:"temperature"`
+	Options         StringMap `gorm:"type:json" json:"options"`
 	APIKeyEncrypted string    `gorm:"type:text" json:"-"`
-	IsActive        bool      `gorm:"default:true" json:"is_active"`
+	IsActive        bool      `json:"is_active"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
+// ProviderKind 返回Provider类型，旧数据没有kind字段时使用名称
+func (p *AIProvider) ProviderKind() string {
+	if p.Kind != "" {
+		return p.Kind
+	}
+	return p.Name
+}
+
 // ChatMessage 聊天记录
+// MessageType为tool时表示工具结果，ToolCallID关联发起调用的assistant消息
+// ToolCalls为assistant消息中模型请求的工具调用（OpenAI格式的JSON数组）
+// Segments为assistant回复按"$"切分后的聊天气泡，Content保留原始回复
+// Attachments为user消息附带的图片，只保存引用地址，不保存图片内容
+// ParentID为对话树中的上一条消息，为0表示第一条消息；同一ParentID下的多条消息互为分支
+// Rating为访客对assistant消息的评价（RatingUp/RatingDown，0表示未评价），FeedbackComment为附带的意见
+// Flagged表示消息命中了审核规则需要复核，ModerationReason为命中原因，ReviewedAt为管理员复核时间
+// Status为消息的生成状态：user消息在调用AI前保存为pending，得到回复后为completed，
+// 调用失败为failed并在ErrorMessage中记录原因，访客中途断开为cancelled；中途断开时已输出的部分回复保存为cancelled的assistant消息
+// Imported表示消息来自访客导入的对话而不是本站生成，不能评价，也不会导出为微调数据
 type ChatMessage struct {
-	ID             uint      `gorm:"primaryKey" json:"id"`
-	ConversationID uint      `gorm:"not null;index" json:"conversation_id"`
-	SessionID      string    `gorm:"not null;index" json:"session_id"`
-	UserIP         string    `json:"user_ip"`
-	CharacterID    uint      `json:"character_id"`
-	ProviderID     uint      `json:"provider_id"`
-	MessageType    string    `gorm:"not null" json:"message_type"` // user/assistant/system
-	Content        string    `gorm:"type:text;not null" json:"content"`
-	TokenCount     int       `gorm:"default:0" json:"token_count"`
-	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+	ID               uint        `gorm:"primaryKey" json:"id"`
+	ConversationID   uint        `gorm:"not null;index" json:"conversation_id"`
+	ParentID         uint        `gorm:"default:0;index" json:"parent_id"`
+	SessionID        string      `gorm:"not null;index" json:"session_id"`
+	UserIP           string      `json:"user_ip"`
+	CharacterID      uint        `json:"character_id"`
+	ProviderID       uint        `json:"provider_id"`
+	MessageType      string      `gorm:"not null" json:"message_type"` // user/assistant/system/tool
+	Content          string      `gorm:"type:text;not null" json:"content"`
+	Segments         StringArray `gorm:"type:json" json:"segments,omitempty"`
+	Attachments      Attachments `gorm:"type:json" json:"attachments,omitempty"`
+	ToolCalls        JSON        `gorm:"type:json" json:"tool_calls,omitempty"`
+	ToolCallID       string      `gorm:"size:100" json:"tool_call_id,omitempty"`
+	ToolName         string      `gorm:"size:100" json:"tool_name,omitempty"`
+	TokenCount       int         `gorm:"default:0" json:"token_count"`
+	Rating           int         `gorm:"default:0;index" json:"rating"`
+	FeedbackComment  string      `gorm:"type:text" json:"feedback_comment,omitempty"`
+	RatedAt          *time.Time  `json:"rated_at,omitempty"`
+	Flagged          bool        `gorm:"default:false;index" json:"flagged"`
+	ModerationReason string      `gorm:"size:255" json:"moderation_reason,omitempty"`
+	ReviewedAt       *time.Time  `json:"reviewed_at,omitempty"`
+	Status           string      `gorm:"size:20;default:completed;index" json:"status"`
+	ErrorMessage     string      `gorm:"size:500" json:"error_message,omitempty"`
+	Imported         bool        `json:"imported,omitempty"`
+	CreatedAt        time.Time   `gorm:"index" json:"created_at"`
+}
+
+// VisibleChatMessages 查询范围：只返回展示给访客的消息，排除工具调用的中间过程
+func VisibleChatMessages(db *gorm.DB) *gorm.DB {
+	return db.Where("message_type IN ?", []string{"user", "assistant"}).Where("content <> ? OR attachments IS NOT NULL", "")
+}
+
+// 消息评价
+const (
+	RatingUp   = 1
+	RatingDown = -1
+)
+
+// 消息生成状态
+const (
+	MessageStatusPending   = "pending"
+	MessageStatusCompleted = "completed"
+	MessageStatusFailed    = "failed"
+	MessageStatusCancelled = "cancelled"
+)
+
+// Unanswered 是否为没有得到回复的user消息（等待中、失败或取消），这类消息不作为上下文发送给模型
+func (m ChatMessage) Unanswered() bool {
+	if m.MessageType != "user" {
+		return false
+	}
+	switch m.Status {
+	case MessageStatusPending, MessageStatusFailed, MessageStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// TextContent 消息的文本内容，附带的图片用占位文本代替
+// 用于不再重复发送图片的场景，如历史消息和导出
+func (m ChatMessage) TextContent() string {
+	if len(m.Attachments) == 0 {
+		return m.Content
+	}
+	placeholder := strings.Repeat("[图片]", len(m.Attachments))
+	if m.Content == "" {
+		return placeholder
+	}
+	return m.Content + "\n" + placeholder
+}
+
+// Visible 消息是否展示给访客，与VisibleChatMessages的条件一致
+func (m ChatMessage) Visible() bool {
+	if m.MessageType != "user" && m.MessageType != "assistant" {
+		return false
+	}
+	return m.Content != "" || len(m.Attachments) > 0
+}
+
+// Attachment 聊天消息的附件
+// Type: 附件类型，目前只有 image
+// URL: 站内上传地址（如 /uploads/chat/xxx.png）或公网https地址
+type Attachment struct {
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// Attachments 附件列表，用于在MySQL中存储JSON数组
+type Attachments []Attachment
+
+// Value 实现driver.Valuer接口，空列表存储为NULL
+func (a Attachments) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan 实现sql.Scanner接口，从数据库读取JSON数组
+func (a *Attachments) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	}
+
+	if len(bytes) == 0 {
+		*a = nil
+		return nil
+	}
+
+	return json.Unmarshal(bytes, a)
 }
@@ -43,6 +43,73 @@ func (s *StringArray) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, s)
 }
 
+// StringMap 字符串映射类型，用于在MySQL中存储JSON对象
+type StringMap map[string]string
+
+// Value 实现driver.Valuer接口，将映射转换为JSON存储到数据库
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan 实现sql.Scanner接口，从数据库读取JSON对象
+func (m *StringMap) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	}
+
+	if len(bytes) == 0 {
+		*m = StringMap{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, m)
+}
+
+// JSON 原始JSON类型，用于存储结构由业务层决定的JSON数据
+type JSON json.RawMessage
+
+// Value 实现driver.Valuer接口
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan 实现sql.Scanner接口
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = JSON(v)
+	default:
+		*j = nil
+	}
+	return nil
+}
+
+// MarshalJSON 原样输出JSON内容
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON 原样保存JSON内容
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
 type Article struct {
 	ID          uint        `gorm:"primaryKey" json:"id"`
 	Title       string      `gorm:"not null" json:"title"`
@@ -55,3 +122,44 @@ type Article struct {
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
 }
+
+// Vector 向量类型，以JSON数组存储到数据库
+type Vector []float32
+
+// Value 实现driver.Valuer接口，将向量转换为JSON存储到数据库
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// Scan 实现sql.Scanner接口，从数据库读取向量
+func (v *Vector) Scan(value interface{}) error {
+	var bytes []byte
+	switch val := value.(type) {
+	case []byte:
+		bytes = val
+	case string:
+		bytes = []byte(val)
+	}
+
+	if len(bytes) == 0 {
+		*v = nil
+		return nil
+	}
+
+	return json.Unmarshal(bytes, v)
+}
+
+// ArticleChunk 文章分块及其向量，用于AI聊天的检索增强
+// EmbeddingModel记录生成向量的模型，更换模型后旧向量需要重建
+type ArticleChunk struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ArticleID      uint      `gorm:"not null;index" json:"article_id"`
+	ChunkIndex     int       `json:"chunk_index"`
+	Content        string    `gorm:"type:text" json:"content"`
+	Embedding      Vector    `gorm:"type:json" json:"-"`
+	EmbeddingModel string    `gorm:"size:100" json:"embedding_model"`
+	CreatedAt      time.Time `json:"created_at"`
+}
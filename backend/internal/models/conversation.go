@@ -7,13 +7,20 @@ import (
 )
 
 // Conversation 对话模型
+// SessionID为创建对话的访客会话，来自服务端签发的会话令牌，只有该会话可以读写对话
+// Summary为较早对话的滚动摘要，作为长期记忆发送给模型
+// SummarizedUntilID为已压缩进摘要的最后一条消息ID，之后的消息按原文发送
+// 消息按ParentID组成树，重新生成和编辑会产生分支；ActiveLeafID为当前分支的最后一条消息，为0表示还没有消息
 type Conversation struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	SessionID string         `gorm:"not null;index" json:"session_id"`
-	Title     string         `gorm:"size:255;not null" json:"title"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	SessionID         string         `gorm:"not null;index" json:"session_id"`
+	Title             string         `gorm:"size:255;not null" json:"title"`
+	Summary           string         `gorm:"type:text" json:"summary,omitempty"`
+	SummarizedUntilID uint           `gorm:"default:0" json:"-"`
+	ActiveLeafID      uint           `gorm:"default:0" json:"active_leaf_id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName 指定表名
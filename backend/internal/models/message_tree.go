@@ -0,0 +1,86 @@
+package models
+
+// MessageTree 对话的全部消息，按ParentID组成树
+type MessageTree struct {
+	byID     map[uint]ChatMessage
+	children map[uint][]uint
+}
+
+// NewMessageTree 根据对话的全部消息（包括其他分支）构建消息树
+// messages需要按ID升序排列，子消息列表同样按创建顺序排列
+func NewMessageTree(messages []ChatMessage) *MessageTree {
+	tree := &MessageTree{
+		byID:     make(map[uint]ChatMessage, len(messages)),
+		children: make(map[uint][]uint),
+	}
+	for _, msg := range messages {
+		tree.byID[msg.ID] = msg
+		tree.children[msg.ParentID] = append(tree.children[msg.ParentID], msg.ID)
+	}
+	return tree
+}
+
+// Message 按ID获取消息
+func (t *MessageTree) Message(id uint) (ChatMessage, bool) {
+	msg, ok := t.byID[id]
+	return msg, ok
+}
+
+// Path 从第一条消息到leafID的消息列表，leafID为0时返回空列表
+func (t *MessageTree) Path(leafID uint) []ChatMessage {
+	var reversed []ChatMessage
+	for id := leafID; id != 0; {
+		msg, ok := t.byID[id]
+		if !ok {
+			break
+		}
+		reversed = append(reversed, msg)
+		id = msg.ParentID
+	}
+
+	path := make([]ChatMessage, len(reversed))
+	for i, msg := range reversed {
+		path[len(reversed)-1-i] = msg
+	}
+	return path
+}
+
+// LatestLeaf 从指定消息开始，每层选择最新的子消息，直到分支末尾
+// 切换到某条消息时，继续显示该分支下最近一次的对话
+func (t *MessageTree) LatestLeaf(id uint) uint {
+	for {
+		children := t.children[id]
+		if len(children) == 0 {
+			return id
+		}
+		id = children[len(children)-1]
+	}
+}
+
+// IsAncestor 判断ancestorID是否为id的祖先消息（不包括自身）
+func (t *MessageTree) IsAncestor(ancestorID, id uint) bool {
+	msg, ok := t.byID[id]
+	for ok && msg.ParentID != 0 {
+		if msg.ParentID == ancestorID {
+			return true
+		}
+		msg, ok = t.byID[msg.ParentID]
+	}
+	return false
+}
+
+// Siblings 路径上有分支的消息及其全部分支（包括自身）的ID，用于客户端切换分支
+// 工具调用的中间消息不展示，只返回可见消息的分支
+func (t *MessageTree) Siblings(path []ChatMessage) map[uint][]uint {
+	result := make(map[uint][]uint)
+	for _, msg := range path {
+		if !msg.Visible() {
+			continue
+		}
+		ids := t.children[msg.ParentID]
+		if len(ids) > 1 {
+			result[msg.ID] = ids
+		}
+	}
+	return result
+}
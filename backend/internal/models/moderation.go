@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// 审核规则类型
+const (
+	ModerationKeyword = "keyword"
+	ModerationRegex   = "regex"
+)
+
+// ModerationRule 内容审核规则
+// Kind为keyword时Pattern为词表，每行一个词；为regex时Pattern为正则表达式
+// Action为命中后的处理方式：block 拒绝、mask 遮盖、flag 标记待复核
+type ModerationRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Kind        string    `gorm:"size:20;not null" json:"kind"`
+	Category    string    `gorm:"size:50;not null" json:"category"`
+	Action      string    `gorm:"size:20;not null" json:"action"`
+	Pattern     string    `gorm:"type:text;not null" json:"pattern"`
+	Description string    `json:"description"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
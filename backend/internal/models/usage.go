@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// UsageRecord AI调用的用量记录，每次调用Provider记录一条，降级时失败的尝试也会记录
+// Purpose为调用用途（chat/summary/title/moderation/embedding），见 ai.PurposeChat
+// Estimated为true表示Provider没有返回用量，Token数按字符数估算
+// Cost为按调用时的价格表计算的费用，没有配置价格的模型为0
+type UsageRecord struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	Provider         string    `gorm:"size:50;index" json:"provider"`
+	ProviderID       uint      `json:"provider_id"`
+	Model            string    `gorm:"size:100" json:"model"`
+	Purpose          string    `gorm:"size:20" json:"purpose"`
+	SessionID        string    `gorm:"size:100;index" json:"session_id"`
+	ConversationID   uint      `gorm:"index" json:"conversation_id"`
+	CharacterID      uint      `gorm:"index" json:"character_id"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Estimated        bool      `json:"estimated"`
+	LatencyMs        int64     `json:"latency_ms"`
+	Success          bool      `json:"success"`
+	Error            string    `gorm:"type:text" json:"error,omitempty"`
+	Cost             float64   `json:"cost"`
+	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+}
+
+// ModelPrice 模型价格，单位为每百万Token的费用，币种与预算一致
+// Provider为空时对所有Provider生效；Model按前缀匹配，如 gpt-4o 匹配 gpt-4o-2024-08-06
+type ModelPrice struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Provider    string    `gorm:"size:50;uniqueIndex:idx_model_price" json:"provider"`
+	Model       string    `gorm:"size:100;not null;uniqueIndex:idx_model_price" json:"model"`
+	InputPrice  float64   `json:"input_price"`
+	OutputPrice float64   `json:"output_price"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,546 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// anthropicVersion Messages API版本
+	anthropicVersion = "2023-06-01"
+	// anthropicDefaultMaxTokens Messages API要求必须指定max_tokens，未指定时使用此值
+	anthropicDefaultMaxTokens = 1024
+	// anthropicMaxTemperature Messages API的温度上限
+	anthropicMaxTemperature = 1
+)
+
+func init() {
+	RegisterKind(ProviderKind{
+		Kind:           "anthropic",
+		DisplayName:    "Anthropic Claude",
+		DefaultURL:     "https://api.anthropic.com/v1",
+		DefaultModel:   "claude-3-5-haiku-latest",
+		EnvPrefix:      "ANTHROPIC",
+		RequiresAPIKey: true,
+		Factory: func(cfg ProviderConfig) (AIProvider, error) {
+			return NewAnthropicProvider(cfg.APIURL, cfg.APIKey, cfg.Model), nil
+		},
+	})
+}
+
+// AnthropicProvider Anthropic Messages API服务提供商
+type AnthropicProvider struct {
+	apiURL string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// NewAnthropicProvider 创建Anthropic Provider实例
+// apiURL: API端点，如 https://api.anthropic.com/v1
+// apiKey: API密钥
+// model: 模型名称，如 claude-3-5-haiku-latest、claude-3-5-sonnet-latest
+func NewAnthropicProvider(apiURL, apiKey, model string) *AnthropicProvider {
+	return &AnthropicProvider{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// anthropicMessage Messages API消息格式
+// 普通文本消息使用Content；包含工具调用或工具结果时使用Blocks
+type anthropicMessage struct {
+	Role    string
+	Content string
+	Blocks  []anthropicBlock
+}
+
+// MarshalJSON 有内容块时content序列化为数组，否则为字符串
+func (m anthropicMessage) MarshalJSON() ([]byte, error) {
+	if len(m.Blocks) > 0 {
+		return json.Marshal(struct {
+			Role    string           `json:"role"`
+			Content []anthropicBlock `json:"content"`
+		}{m.Role, m.Blocks})
+	}
+	return json.Marshal(struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}{m.Role, m.Content})
+}
+
+// UnmarshalJSON 解析字符串或数组格式的content
+func (m *anthropicMessage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = anthropicMessage{Role: raw.Role}
+	if len(raw.Content) > 0 && raw.Content[0] == '[' {
+		return json.Unmarshal(raw.Content, &m.Blocks)
+	}
+	return json.Unmarshal(raw.Content, &m.Content)
+}
+
+// blocks 返回消息的内容块形式，文本消息转换为单个text块
+func (m anthropicMessage) blocks() []anthropicBlock {
+	if len(m.Blocks) > 0 {
+		return m.Blocks
+	}
+	if m.Content == "" {
+		return nil
+	}
+	return []anthropicBlock{{Type: "text", Text: m.Content}}
+}
+
+// anthropicBlock Messages API内容块
+// Type: text、tool_use（模型调用工具）、tool_result（工具结果）
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+// anthropicTool Messages API工具定义
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// anthropicToolChoice Messages API工具选择策略
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+}
+
+// anthropicRequest Messages API请求格式
+// 系统提示词是顶层字段，不在messages中
+type anthropicRequest struct {
+	Model         string               `json:"model"`
+	System        string               `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   *float32             `json:"temperature,omitempty"`
+	TopP          *float32             `json:"top_p,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// anthropicUsage Messages API的Token使用情况
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse Messages API响应格式
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Role       string           `json:"role"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// anthropicErrorResponse Messages API错误响应格式
+type anthropicErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStreamEvent Messages API流式事件
+// 不同事件类型使用不同的字段，未使用的字段为空
+type anthropicStreamEvent struct {
+	Type         string         `json:"type"`
+	Index        int            `json:"index"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Message      struct {
+		ID    string         `json:"id"`
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// convertAnthropicMessages 将统一消息格式转换为Messages API格式
+// system消息合并为顶层system字段；连续的同角色消息合并为一条；
+// assistant的工具调用转换为tool_use块，tool消息转换为user角色的tool_result块；
+// Messages API要求第一条消息为user，因此以assistant开头时补充一条占位user消息
+func convertAnthropicMessages(messages []ChatMessage) (string, []anthropicMessage) {
+	var system []string
+	var result []anthropicMessage
+
+	for _, msg := range messages {
+		if msg.Content == "" && len(msg.ToolCalls) == 0 {
+			continue
+		}
+
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+
+		converted := anthropicMessage{Role: "user", Content: msg.Content}
+		switch msg.Role {
+		case "assistant":
+			converted.Role = "assistant"
+			if len(msg.ToolCalls) > 0 {
+				converted.Blocks = converted.blocks()
+				for _, call := range msg.ToolCalls {
+					input := json.RawMessage(call.Function.Arguments)
+					if !json.Valid(input) {
+						input = json.RawMessage("{}")
+					}
+					converted.Blocks = append(converted.Blocks, anthropicBlock{
+						Type:  "tool_use",
+						ID:    call.ID,
+						Name:  call.Function.Name,
+						Input: input,
+					})
+				}
+			}
+		case "tool":
+			converted.Blocks = []anthropicBlock{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}}
+		}
+
+		if n := len(result); n > 0 && result[n-1].Role == converted.Role {
+			prev := &result[n-1]
+			if len(prev.Blocks) == 0 && len(converted.Blocks) == 0 {
+				prev.Content += "\n\n" + converted.Content
+			} else {
+				prev.Blocks = append(prev.blocks(), converted.blocks()...)
+				prev.Content = ""
+			}
+			continue
+		}
+		result = append(result, converted)
+	}
+
+	if len(result) > 0 && result[0].Role != "user" {
+		result = append([]anthropicMessage{{Role: "user", Content: "（继续）"}}, result...)
+	}
+
+	return strings.Join(system, "\n\n"), result
+}
+
+// convertAnthropicTools 将OpenAI格式的工具定义转换为Messages API格式
+func convertAnthropicTools(tools []Tool, toolChoice string) ([]anthropicTool, *anthropicToolChoice) {
+	if len(tools) == 0 {
+		return nil, nil
+	}
+
+	result := make([]anthropicTool, 0, len(tools))
+	for _, tool := range tools {
+		result = append(result, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+
+	var choice *anthropicToolChoice
+	if toolChoice == "none" {
+		choice = &anthropicToolChoice{Type: "none"}
+	}
+	return result, choice
+}
+
+// anthropicToolCalls 从内容块中提取工具调用
+func anthropicToolCalls(blocks []anthropicBlock) []ToolCall {
+	var calls []ToolCall
+	for _, block := range blocks {
+		if block.Type != "tool_use" {
+			continue
+		}
+		arguments := string(block.Input)
+		if arguments == "" {
+			arguments = "{}"
+		}
+		calls = append(calls, ToolCall{
+			ID:       block.ID,
+			Type:     "function",
+			Function: ToolCallFunction{Name: block.Name, Arguments: arguments},
+		})
+	}
+	return calls
+}
+
+// anthropicFinishReason 将stop_reason转换为统一的FinishReason
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return stopReason
+	}
+}
+
+// buildRequest 构建Messages API请求
+func (p *AnthropicProvider) buildRequest(req *ChatCompletionRequest, stream bool) anthropicRequest {
+	system, messages := convertAnthropicMessages(req.Messages)
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	tools, toolChoice := convertAnthropicTools(req.Tools, req.ToolChoice)
+
+	return anthropicRequest{
+		Model:         p.model,
+		System:        system,
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Temperature:   clampTemperature(req.Temperature, anthropicMaxTemperature),
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        stream,
+		Tools:         tools,
+		ToolChoice:    toolChoice,
+	}
+}
+
+// newRequest 创建带鉴权请求头的HTTP请求
+func (p *AnthropicProvider) newRequest(ctx context.Context, body interface{}) (*http.Request, error) {
+	jsonReq, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("[Anthropic] 序列化请求失败: %v", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.apiURL+"/messages", bytes.NewBuffer(jsonReq))
+	if err != nil {
+		log.Printf("[Anthropic] 创建请求失败: %v", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	return httpReq, nil
+}
+
+// parseError 解析错误响应
+func (p *AnthropicProvider) parseError(statusCode int, body []byte) error {
+	var errResp anthropicErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		log.Printf("[Anthropic] API错误: %s - %s", errResp.Error.Type, errResp.Error.Message)
+		return &APIError{Provider: "Anthropic", StatusCode: statusCode, Message: errResp.Error.Message}
+	}
+	log.Printf("[Anthropic] HTTP错误: %d, 响应: %s", statusCode, string(body))
+	return &APIError{Provider: "Anthropic", StatusCode: statusCode}
+}
+
+// ChatCompletion 执行聊天完成请求
+func (p *AnthropicProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	httpReq, err := p.newRequest(ctx, p.buildRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+
+	// 发送请求
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		log.Printf("[Anthropic] 请求失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应体
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("[Anthropic] 读取响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		return nil, p.parseError(resp.StatusCode, body)
+	}
+
+	// 解析响应
+	var anthropicResp anthropicResponse
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		log.Printf("[Anthropic] 解析响应失败: %v, 响应: %s", err, string(body))
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	// 拼接文本内容块
+	var content strings.Builder
+	for _, block := range anthropicResp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+
+	// 转换为统一格式
+	result := &ChatCompletionResponse{
+		ID:     anthropicResp.ID,
+		Object: "chat.completion",
+		Model:  anthropicResp.Model,
+		Choices: []ChatChoice{
+			{
+				Index:        0,
+				Message:      ChatMessage{Role: "assistant", Content: content.String(), ToolCalls: anthropicToolCalls(anthropicResp.Content)},
+				FinishReason: anthropicFinishReason(anthropicResp.StopReason),
+			},
+		},
+		Usage: ChatUsage{
+			PromptTokens:     anthropicResp.Usage.InputTokens,
+			CompletionTokens: anthropicResp.Usage.OutputTokens,
+			TotalTokens:      anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
+		},
+	}
+
+	log.Printf("[Anthropic] 请求成功, 模型: %s, Token使用: %d", result.Model, result.Usage.TotalTokens)
+	return result, nil
+}
+
+// ChatCompletionStream 执行流式聊天完成请求
+func (p *AnthropicProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	httpReq, err := p.newRequest(ctx, p.buildRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	// 发送请求
+	streamClient := *p.client
+	streamClient.Timeout = streamTimeout
+	resp, err := streamClient.Do(httpReq)
+	if err != nil {
+		log.Printf("[Anthropic] 流式请求失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, p.parseError(resp.StatusCode, body)
+	}
+
+	result := &ChatCompletionResponse{Object: "chat.completion"}
+	var content strings.Builder
+	// 工具调用块按index记录，参数通过input_json_delta分段到达
+	blocks := make(map[int]*anthropicBlock)
+	var blockOrder []int
+	stopReason := ""
+
+	err = readSSE(resp.Body, func(eventName, data string) (bool, error) {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			log.Printf("[Anthropic] 解析流式数据失败: %v, 数据: %s", err, data)
+			return false, fmt.Errorf("解析响应失败: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			result.ID = event.Message.ID
+			result.Model = event.Message.Model
+			result.Usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				block := event.ContentBlock
+				block.Input = nil
+				blocks[event.Index] = &block
+				blockOrder = append(blockOrder, event.Index)
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "input_json_delta" {
+				if block, ok := blocks[event.Index]; ok {
+					block.Input = append(block.Input, event.Delta.PartialJSON...)
+				}
+				return true, nil
+			}
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				return true, nil
+			}
+			content.WriteString(event.Delta.Text)
+			if err := onDelta(event.Delta.Text); err != nil {
+				return false, err
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+			result.Usage.CompletionTokens = event.Usage.OutputTokens
+		case "message_stop":
+			return false, nil
+		case "error":
+			// 流中途的错误（如overloaded_error）视为服务端错误
+			log.Printf("[Anthropic] 流式API错误: %s - %s", event.Error.Type, event.Error.Message)
+			return false, &APIError{Provider: "Anthropic", StatusCode: http.StatusServiceUnavailable, Message: event.Error.Message}
+		}
+		return true, nil
+	})
+	if err != nil {
+		log.Printf("[Anthropic] 读取流式响应失败: %v", err)
+		return nil, err
+	}
+
+	toolBlocks := make([]anthropicBlock, 0, len(blockOrder))
+	for _, index := range blockOrder {
+		toolBlocks = append(toolBlocks, *blocks[index])
+	}
+
+	result.Usage.TotalTokens = result.Usage.PromptTokens + result.Usage.CompletionTokens
+	result.Choices = []ChatChoice{
+		{
+			Index:        0,
+			Message:      ChatMessage{Role: "assistant", Content: content.String(), ToolCalls: anthropicToolCalls(toolBlocks)},
+			FinishReason: anthropicFinishReason(stopReason),
+		},
+	}
+
+	log.Printf("[Anthropic] 流式请求成功, 模型: %s, Token使用: %d", result.Model, result.Usage.TotalTokens)
+	return result, nil
+}
+
+// GetModelName 获取模型名称
+func (p *AnthropicProvider) GetModelName() string {
+	return p.model
+}
+
+// GetProviderName 获取提供商名称
+func (p *AnthropicProvider) GetProviderName() string {
+	return "anthropic"
+}
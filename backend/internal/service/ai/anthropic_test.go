@@ -0,0 +1,201 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// 测试Anthropic请求格式：请求头、顶层system字段、同角色消息合并
+func TestAnthropicProvider_RequestFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("Expected path '/messages', got '%s'", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Error("x-api-key header should contain API key")
+		}
+		if r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Error("anthropic-version header should be set")
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Authorization header should not be set")
+		}
+
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+
+		if req.System != "你是莫诺\n\n保持简短" {
+			t.Errorf("Expected merged system prompt, got '%s'", req.System)
+		}
+		if req.MaxTokens != anthropicDefaultMaxTokens {
+			t.Errorf("Expected default max_tokens %d, got %d", anthropicDefaultMaxTokens, req.MaxTokens)
+		}
+		if req.Temperature == nil || *req.Temperature != 1 {
+			t.Errorf("Expected temperature clamped to 1, got %v", req.Temperature)
+		}
+
+		expected := []anthropicMessage{
+			{Role: "user", Content: "（继续）"},
+			{Role: "assistant", Content: "嗯$有什么事吗"},
+			{Role: "user", Content: "你好\n\n在吗"},
+		}
+		if len(req.Messages) != len(expected) {
+			t.Fatalf("Expected %d messages, got %d: %+v", len(expected), len(req.Messages), req.Messages)
+		}
+		for i, msg := range expected {
+			if !reflect.DeepEqual(req.Messages[i], msg) {
+				t.Errorf("Message %d: expected %+v, got %+v", i, msg, req.Messages[i])
+			}
+		}
+
+		resp := map[string]interface{}{
+			"id":    "msg_123",
+			"type":  "message",
+			"role":  "assistant",
+			"model": "claude-3-5-haiku-latest",
+			"content": []map[string]string{
+				{"type": "text", "text": "在"},
+				{"type": "text", "text": "$说吧"},
+			},
+			"stop_reason": "max_tokens",
+			"usage":       map[string]int{"input_tokens": 25, "output_tokens": 7},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(server.URL, "test-key", "claude-3-5-haiku-latest")
+	req := &ChatCompletionRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: "你是莫诺"},
+			{Role: "assistant", Content: "嗯$有什么事吗"},
+			{Role: "system", Content: "保持简短"},
+			{Role: "user", Content: "你好"},
+			{Role: "user", Content: "在吗"},
+		},
+		Temperature: float32Ptr(1.3),
+	}
+
+	resp, err := provider.ChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+
+	// 验证响应格式转换
+	if resp.Choices[0].Message.Content != "在$说吧" {
+		t.Errorf("Expected content '在$说吧', got '%s'", resp.Choices[0].Message.Content)
+	}
+	if resp.Choices[0].FinishReason != "length" {
+		t.Errorf("Expected finish reason 'length', got '%s'", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage.PromptTokens != 25 || resp.Usage.CompletionTokens != 7 || resp.Usage.TotalTokens != 32 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+}
+
+// 测试stop_reason转换
+func TestAnthropicFinishReason(t *testing.T) {
+	testCases := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"max_tokens":    "length",
+		"tool_use":      "tool_calls",
+		"refusal":       "refusal",
+	}
+
+	for stopReason, expected := range testCases {
+		if got := anthropicFinishReason(stopReason); got != expected {
+			t.Errorf("%s: expected '%s', got '%s'", stopReason, expected, got)
+		}
+	}
+}
+
+// 测试Anthropic错误处理：错误信息解析和可重试判断
+func TestAnthropicProvider_ErrorHandling(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		retryable  bool
+	}{
+		{name: "HTTP 400 invalid request", statusCode: 400, retryable: false},
+		{name: "HTTP 429 rate limit", statusCode: 429, retryable: true},
+		{name: "HTTP 529 overloaded", statusCode: 529, retryable: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.statusCode)
+				w.Write([]byte(`{"type":"error","error":{"type":"some_error","message":"mock message"}}`))
+			}))
+			defer server.Close()
+
+			provider := NewAnthropicProvider(server.URL, "test-key", "claude")
+			req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+
+			_, err := provider.ChatCompletion(context.Background(), req)
+			if err == nil {
+				t.Fatal("Expected error but got nil")
+			}
+			if err.Error() != "Anthropic API错误: mock message" {
+				t.Errorf("Unexpected error message: %v", err)
+			}
+			if IsRetryable(err) != tc.retryable {
+				t.Errorf("Expected retryable=%v for %v", tc.retryable, err)
+			}
+		})
+	}
+}
+
+// 测试Anthropic流式响应的事件解析
+func TestAnthropicProvider_ChatCompletionStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("Stream request should set stream to true")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n"))
+		w.Write([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+		w.Write([]byte("event: ping\ndata: {\"type\":\"ping\"}\n\n"))
+		w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"你好\"}}\n\n"))
+		w.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"呀\"}}\n\n"))
+		w.Write([]byte("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"))
+		w.Write([]byte("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":6}}\n\n"))
+		w.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(server.URL, "test-key", "claude")
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+
+	var deltas []string
+	resp, err := provider.ChatCompletionStream(context.Background(), req, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	if len(deltas) != 2 {
+		t.Errorf("Expected 2 deltas, got %v", deltas)
+	}
+	if resp.ID != "msg_1" || resp.Choices[0].Message.Content != "你好呀" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("Expected finish reason 'stop', got '%s'", resp.Choices[0].FinishReason)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 6 || resp.Usage.TotalTokens != 18 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+}
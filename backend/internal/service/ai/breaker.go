@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitState 熔断器状态
+type circuitState int
+
+const (
+	// circuitClosed 正常状态，请求直接放行
+	circuitClosed circuitState = iota
+	// circuitOpen 熔断状态，冷却期内跳过该Provider
+	circuitOpen
+	// circuitHalfOpen 冷却期结束，放行一个探测请求
+	circuitHalfOpen
+)
+
+// circuitBreaker 单个Provider的熔断器
+// 连续失败达到阈值后熔断，冷却期结束后放行一个探测请求，
+// 探测成功则恢复，失败则重新进入冷却期
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            circuitState
+	failures         int
+	openedAt         time.Time
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+}
+
+// newCircuitBreaker 创建熔断器
+// failureThreshold: 连续失败多少次后熔断
+// cooldown: 熔断后的冷却时间
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+	}
+}
+
+// Allow 判断当前是否允许请求通过
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		// 冷却期结束，放行一个探测请求
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// 探测请求进行中，其余请求继续跳过
+		return false
+	default:
+		return true
+	}
+}
+
+// RecordSuccess 记录一次成功请求，恢复正常状态
+func (b *circuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = circuitClosed
+	b.failures = 0
+}
+
+// RecordFailure 记录一次失败请求
+// 返回true表示本次失败导致熔断
+func (b *circuitBreaker) RecordFailure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.failureThreshold {
+		b.state = circuitOpen
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+// Release 释放探测名额但不改变熔断计数
+// 用于探测请求因调用方取消等与Provider无关的原因结束的情况
+func (b *circuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.state = circuitOpen
+		b.openedAt = b.now().Add(-b.cooldown)
+	}
+}
+
+// IsOpen 判断是否处于熔断状态（用于状态展示）
+func (b *circuitBreaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == circuitOpen && b.now().Sub(b.openedAt) < b.cooldown
+}
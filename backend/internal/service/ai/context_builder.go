@@ -0,0 +1,98 @@
+package ai
+
+// 上下文窗口默认值
+const (
+	// DefaultContextBudget Provider未配置MaxTokens时使用的上下文预算
+	DefaultContextBudget = 8000
+	// DefaultCompletionReserve 请求未指定MaxTokens时为回复预留的Token数
+	DefaultCompletionReserve = 1000
+)
+
+// ContextBuilder 按Token预算构建对话上下文
+// 始终保留系统提示和当前用户消息，历史消息从最新往前填充，超出预算的旧消息被丢弃
+type ContextBuilder struct {
+	// Budget 上下文总预算（输入+输出），通常为Provider配置的MaxTokens
+	Budget int
+	// CompletionReserve 为回复预留的Token数，通常为请求的MaxTokens
+	CompletionReserve int
+	// Ratio Token估算系数
+	Ratio TokenRatio
+}
+
+// ContextResult 上下文构建结果
+// Messages: 发送给模型的消息列表
+// PromptTokens: 估算的输入Token数
+// Dropped: 因超出预算被丢弃的历史消息数
+type ContextResult struct {
+	Messages     []ChatMessage
+	PromptTokens int
+	Dropped      int
+}
+
+// NewContextBuilder 根据Provider配置创建上下文构建器
+// completionTokens: 本次请求的最大生成Token数，为0时使用默认预留
+func NewContextBuilder(cfg ProviderConfig, completionTokens int) *ContextBuilder {
+	budget := cfg.MaxTokens
+	if budget <= 0 {
+		budget = DefaultContextBudget
+	}
+	if completionTokens <= 0 {
+		completionTokens = DefaultCompletionReserve
+	}
+
+	return &ContextBuilder{
+		Budget:            budget,
+		CompletionReserve: completionTokens,
+		Ratio:             TokenRatioFor(cfg.withDefaults().Kind),
+	}
+}
+
+// promptBudget 可用于输入消息的Token数
+// 预留过大（超过预算一半）时按一半计算，保证至少能容纳最近几轮对话
+func (b *ContextBuilder) promptBudget() int {
+	reserve := b.CompletionReserve
+	if reserve > b.Budget/2 {
+		reserve = b.Budget / 2
+	}
+	return b.Budget - reserve
+}
+
+// Build 构建上下文
+// system: 系统消息（角色设定等），始终保留
+// history: 按时间正序排列的历史消息
+// user: 当前用户消息，始终保留
+func (b *ContextBuilder) Build(system []ChatMessage, history []ChatMessage, user ChatMessage) ContextResult {
+	used := b.Ratio.EstimateMessageTokens(user)
+	for _, msg := range system {
+		used += b.Ratio.EstimateMessageTokens(msg)
+	}
+
+	// 从最新的历史消息往前填充
+	budget := b.promptBudget()
+	start := len(history)
+	for start > 0 {
+		cost := b.Ratio.EstimateMessageTokens(history[start-1])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+
+	// 上下文不以assistant或tool消息开头，避免缺少对应提问的孤立回复和缺少调用的工具结果
+	for start < len(history) && (history[start].Role == "assistant" || history[start].Role == "tool") {
+		used -= b.Ratio.EstimateMessageTokens(history[start])
+		start++
+	}
+
+	messages := make([]ChatMessage, 0, len(system)+len(history)-start+1)
+	messages = append(messages, system...)
+	messages = append(messages, history[start:]...)
+	messages = append(messages, user)
+
+	return ContextResult{
+		Messages:     messages,
+		PromptTokens: used,
+		Dropped:      start,
+	}
+}
@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 测试Token估算：中文按类型系数计算，短文本至少为1
+func TestTokenRatio_EstimateTokens(t *testing.T) {
+	testCases := []struct {
+		kind     string
+		text     string
+		expected int
+	}{
+		{kind: "deepseek", text: "你好世界你好", expected: 4},
+		{kind: "openai", text: "你好世界你好", expected: 6},
+		{kind: "openai", text: "hello world!", expected: 3},
+		{kind: "unknown", text: "a", expected: 1},
+		{kind: "deepseek", text: "", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.kind+"/"+tc.text, func(t *testing.T) {
+			got := TokenRatioFor(tc.kind).EstimateTokens(tc.text)
+			if got != tc.expected {
+				t.Errorf("Expected %d tokens, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+// 测试上下文裁剪：保留系统提示、当前消息和最近的历史
+func TestContextBuilder_Build(t *testing.T) {
+	ratio := TokenRatio{CJK: 1, Other: 1}
+	// 每条历史消息 6 + 4 = 10 Token
+	history := make([]ChatMessage, 0, 40)
+	for i := 0; i < 40; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		history = append(history, ChatMessage{Role: role, Content: fmt.Sprintf("msg-%02d", i)})
+	}
+	system := []ChatMessage{{Role: "system", Content: "system"}}
+	user := ChatMessage{Role: "user", Content: "latest"}
+
+	builder := &ContextBuilder{Budget: 200, CompletionReserve: 100, Ratio: ratio}
+	result := builder.Build(system, history, user)
+
+	// 预算100：系统10 + 当前10，历史最多8条
+	if len(result.Messages) != 10 {
+		t.Fatalf("Expected 10 messages, got %d", len(result.Messages))
+	}
+	if result.Dropped != 32 {
+		t.Errorf("Expected 32 dropped, got %d", result.Dropped)
+	}
+	if result.Messages[0].Role != "system" {
+		t.Error("System prompt must be kept first")
+	}
+	if result.Messages[1].Content != "msg-32" {
+		t.Errorf("Expected oldest kept message 'msg-32', got '%s'", result.Messages[1].Content)
+	}
+	if result.Messages[9].Content != "latest" {
+		t.Error("Current user message must be kept last")
+	}
+	if result.PromptTokens > builder.promptBudget() {
+		t.Errorf("Prompt tokens %d exceed budget %d", result.PromptTokens, builder.promptBudget())
+	}
+}
+
+// 测试裁剪后不以assistant消息开头
+func TestContextBuilder_SkipsLeadingAssistant(t *testing.T) {
+	ratio := TokenRatio{CJK: 1, Other: 1}
+	history := []ChatMessage{
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "a2"},
+	}
+
+	// 可容纳3条历史（每条6 Token），第一条保留的是assistant
+	builder := &ContextBuilder{Budget: 28, CompletionReserve: 0, Ratio: ratio}
+	result := builder.Build(nil, history, ChatMessage{Role: "user", Content: "q3"})
+
+	if result.Messages[0].Role != "user" || result.Messages[0].Content != "q2" {
+		t.Errorf("Expected context to start with 'q2', got %+v", result.Messages[0])
+	}
+	if result.Dropped != 2 {
+		t.Errorf("Expected 2 dropped, got %d", result.Dropped)
+	}
+}
+
+// 测试超大的回复预留不会挤掉全部历史，且使用Provider配置的预算
+func TestNewContextBuilder(t *testing.T) {
+	builder := NewContextBuilder(ProviderConfig{Name: "deepseek", MaxTokens: 4000}, 8000)
+	if builder.promptBudget() != 2000 {
+		t.Errorf("Expected prompt budget 2000, got %d", builder.promptBudget())
+	}
+	if builder.Ratio != tokenRatios["deepseek"] {
+		t.Errorf("Expected deepseek ratio, got %+v", builder.Ratio)
+	}
+
+	builder = NewContextBuilder(ProviderConfig{}, 0)
+	if builder.Budget != DefaultContextBudget || builder.CompletionReserve != DefaultCompletionReserve {
+		t.Errorf("Defaults not applied: %+v", builder)
+	}
+
+	long := ChatMessage{Role: "user", Content: strings.Repeat("长", 100)}
+	result := builder.Build(nil, []ChatMessage{long, {Role: "assistant", Content: "ok"}}, long)
+	if result.Dropped != 0 {
+		t.Errorf("Expected nothing dropped, got %d", result.Dropped)
+	}
+}
@@ -0,0 +1,144 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// EmbeddingModelOption Provider配置中指定向量模型的配置项
+const EmbeddingModelOption = "embedding_model"
+
+// ErrEmbeddingsUnsupported Provider不支持文本向量化（未实现或未配置向量模型）
+var ErrEmbeddingsUnsupported = errors.New("provider不支持文本向量化")
+
+// Embedder 文本向量化接口
+// 支持Embeddings接口的Provider（如OpenAI、通义千问、Ollama）可选实现此接口
+type Embedder interface {
+	// Embed 将文本批量转换为向量，返回的向量与输入顺序一致
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+
+	// EmbeddingModel 获取向量模型名称，模型不同的向量不能混用
+	EmbeddingModel() string
+}
+
+// embeddingRequest OpenAI兼容的Embeddings请求格式
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse OpenAI兼容的Embeddings响应格式
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// embedOpenAICompatible 调用OpenAI兼容的 /embeddings 接口
+// endpoint: 完整的请求地址，如 https://api.openai.com/v1/embeddings
+// setHeaders: 设置Content-Type和鉴权等请求头
+// tag: 日志和错误信息中使用的Provider名称
+func embedOpenAICompatible(ctx context.Context, client *http.Client, endpoint string, setHeaders func(*http.Request), model string, texts []string, tag string) ([][]float32, error) {
+	jsonReq, err := json.Marshal(embeddingRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonReq))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	setHeaders(httpReq)
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		log.Printf("[%s] 向量化请求失败: %v", tag, err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp openaiErrorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+			log.Printf("[%s] 向量化API错误: %s", tag, errResp.Error.Message)
+			return nil, &APIError{Provider: tag, StatusCode: resp.StatusCode, Message: errResp.Error.Message}
+		}
+		log.Printf("[%s] 向量化HTTP错误: %d, 响应: %s", tag, resp.StatusCode, string(body))
+		return nil, &APIError{Provider: tag, StatusCode: resp.StatusCode}
+	}
+
+	var embResp embeddingResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if len(embResp.Data) != len(texts) {
+		return nil, fmt.Errorf("%s 返回了 %d 个向量，期望 %d 个", tag, len(embResp.Data), len(texts))
+	}
+
+	// 按index排序，保证与输入顺序一致
+	sort.Slice(embResp.Data, func(i, j int) bool { return embResp.Data[i].Index < embResp.Data[j].Index })
+	vectors := make([][]float32, len(embResp.Data))
+	for i, d := range embResp.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// Embed 使用指定的Provider将文本转换为向量
+func (m *AIManager) Embed(ctx context.Context, providerName string, texts []string) ([][]float32, error) {
+	embedder, err := m.GetEmbedder(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return embedder.Embed(ctx, texts)
+}
+
+// GetEmbedder 获取支持向量化的Provider
+// 返回的Embedder每次调用都会记录用量，用途为PurposeEmbedding
+func (m *AIManager) GetEmbedder(providerName string) (Embedder, error) {
+	if providerName == "" {
+		m.mu.RLock()
+		providerName = m.defaultProvider
+		m.mu.RUnlock()
+	}
+	provider, err := m.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, ok := provider.(Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return nil, fmt.Errorf("%w: %s", ErrEmbeddingsUnsupported, provider.GetProviderName())
+	}
+	return &usageEmbedder{Embedder: embedder, manager: m, name: providerName, provider: provider}, nil
+}
+
+// usageEmbedder 记录用量的Embedder
+type usageEmbedder struct {
+	Embedder
+	manager  *AIManager
+	name     string
+	provider AIProvider
+}
+
+// Embed 调用Provider向量化并记录用量
+func (e *usageEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	start := time.Now()
+	vectors, err := e.Embedder.Embed(ctx, texts)
+	e.manager.recordEmbeddingUsage(ctx, e.name, e.provider, e.EmbeddingModel(), texts, time.Since(start), err)
+	return vectors, err
+}
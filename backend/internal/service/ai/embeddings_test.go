@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 测试OpenAI兼容的向量化接口：按index恢复输入顺序
+func TestOpenAIProvider_Embed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("Expected /embeddings, got %s", r.URL.Path)
+		}
+		var req embeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "text-embedding-3-small" || len(req.Input) != 2 {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	vectors, err := NewOpenAIProvider(server.URL, "key", "gpt-4o").Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Unexpected vectors: %v", vectors)
+	}
+}
+
+// 测试Ollama向量化接口
+func TestOllamaProvider_Embed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("Expected /api/embed, got %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"embeddings":[[0.5,0.5]]}`))
+	}))
+	defer server.Close()
+
+	vectors, err := NewOllamaProvider(server.URL, "", "qwen2.5").Embed(context.Background(), []string{"hello"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) != 2 {
+		t.Errorf("Unexpected vectors: %v", vectors)
+	}
+}
+
+// 测试未配置向量模型的Provider不能用于向量化
+func TestAIManager_GetEmbedder(t *testing.T) {
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider("http://localhost", "key", "deepseek-chat"))
+	manager.RegisterProvider("openai", NewOpenAIProvider("http://localhost", "key", "gpt-4o"))
+
+	if _, err := manager.GetEmbedder("deepseek"); !errors.Is(err, ErrEmbeddingsUnsupported) {
+		t.Errorf("Expected ErrEmbeddingsUnsupported, got %v", err)
+	}
+	embedder, err := manager.GetEmbedder("openai")
+	if err != nil || embedder.EmbeddingModel() != "text-embedding-3-small" {
+		t.Errorf("Expected openai embedder, got %v, %v", embedder, err)
+	}
+}
+
+// 测试向量化用量：保留调用方的会话归属，用途记为embedding
+func TestAIManager_EmbeddingUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1,0.2]}]}`))
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("openai", NewOpenAIProvider(server.URL, "key", "gpt-4o"))
+	var events []UsageEvent
+	manager.SetUsageRecorder(func(event UsageEvent) {
+		events = append(events, event)
+	})
+
+	embedder, err := manager.GetEmbedder("")
+	if err != nil {
+		t.Fatalf("GetEmbedder failed: %v", err)
+	}
+	ctx := WithUsageLabels(context.Background(), UsageLabels{Purpose: PurposeChat, SessionID: "s1"})
+	if _, err := embedder.Embed(ctx, []string{"hello world"}); err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 usage event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Labels.Purpose != PurposeEmbedding || event.Labels.SessionID != "s1" {
+		t.Errorf("Unexpected labels: %+v", event.Labels)
+	}
+	if event.Provider != "openai" || event.Model != "text-embedding-3-small" {
+		t.Errorf("Unexpected provider/model: %s/%s", event.Provider, event.Model)
+	}
+	if event.Usage.TotalTokens == 0 || !event.Estimated {
+		t.Errorf("Expected estimated tokens, got %+v", event.Usage)
+	}
+}
@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrNoAvailableProvider 所有候选Provider均不可用（未注册或处于熔断状态）
+var ErrNoAvailableProvider = errors.New("没有可用的AI Provider")
+
+// APIError AI服务返回的HTTP错误
+// Provider: 日志中使用的Provider名称，如 "DeepSeek"
+// StatusCode: HTTP状态码
+// Message: 服务端返回的错误信息（可能为空）
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+// Error 实现error接口，保持与原有错误信息格式一致
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s API错误: %s", e.Provider, e.Message)
+	}
+	return fmt.Sprintf("%s API错误: HTTP %d", e.Provider, e.StatusCode)
+}
+
+// IsRetryable 判断错误是否可以切换到其他Provider重试
+// 超时、连接失败、HTTP 429 和 5xx 视为可重试；参数错误、鉴权失败等直接返回
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
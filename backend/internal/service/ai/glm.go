@@ -11,12 +11,43 @@ import (
 	"time"
 )
 
+// glmDefaultEmbeddingModel 智谱AI默认的向量模型
+const glmDefaultEmbeddingModel = "embedding-3"
+
+// glmVisionModels 支持图片输入的GLM模型前缀
+var glmVisionModels = []string{"glm-4v", "glm-4.1v", "glm-4.5v"}
+
 // GLMProvider 智谱AI GLM服务提供商
 type GLMProvider struct {
-	apiURL  string
-	apiKey  string
-	model   string
-	client  *http.Client
+	apiURL         string
+	apiKey         string
+	model          string
+	embeddingModel string
+	visionModels   []string
+	client         *http.Client
+}
+
+func init() {
+	RegisterKind(ProviderKind{
+		Kind:           "glm",
+		DisplayName:    "智谱GLM",
+		DefaultURL:     "https://open.bigmodel.cn/api/paas/v4",
+		DefaultModel:   "glm-4-flash",
+		EnvPrefix:      "GLM",
+		RequiresAPIKey: true,
+		Factory: func(cfg ProviderConfig) (AIProvider, error) {
+			p := NewGLMProvider(cfg.APIURL, cfg.APIKey, cfg.Model)
+			if model := cfg.Options[EmbeddingModelOption]; model != "" {
+				p.embeddingModel = model
+			}
+			models, err := visionModels(cfg.Options, glmVisionModels)
+			if err != nil {
+				return nil, err
+			}
+			p.visionModels = models
+			return p, nil
+		},
+	})
 }
 
 // NewGLMProvider 创建GLM Provider实例
@@ -25,9 +56,11 @@ type GLMProvider struct {
 // model: 模型名称，如 glm-4、glm-4-flash
 func NewGLMProvider(apiURL, apiKey, model string) *GLMProvider {
 	return &GLMProvider{
-		apiURL: apiURL,
-		apiKey: apiKey,
-		model:  model,
+		apiURL:         apiURL,
+		apiKey:         apiKey,
+		model:          model,
+		embeddingModel: glmDefaultEmbeddingModel,
+		visionModels:   glmVisionModels,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
@@ -38,9 +71,13 @@ func NewGLMProvider(apiURL, apiKey, model string) *GLMProvider {
 type glmRequest struct {
 	Model       string        `json:"model"`
 	Messages    []ChatMessage `json:"messages"`
-	Temperature float32       `json:"temperature,omitempty"`
+	Temperature *float32      `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
+	TopP        *float32      `json:"top_p,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	Tools       []Tool        `json:"tools,omitempty"`
+	ToolChoice  string        `json:"tool_choice,omitempty"`
 }
 
 // glmResponse 智谱AI响应格式
@@ -76,7 +113,11 @@ func (p *GLMProvider) ChatCompletion(ctx context.Context, req *ChatCompletionReq
 		Messages:    req.Messages,
 		Temperature: req.Temperature,
 		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
 		Stream:      false, // 暂不支持流式
+		Tools:       req.Tools,
+		ToolChoice:  req.ToolChoice,
 	}
 
 	// 序列化请求
@@ -117,10 +158,10 @@ func (p *GLMProvider) ChatCompletion(ctx context.Context, req *ChatCompletionReq
 		var errResp glmErrorResponse
 		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
 			log.Printf("[GLM] API错误: %s - %s", errResp.Error.Code, errResp.Error.Message)
-			return nil, fmt.Errorf("GLM API错误: %s", errResp.Error.Message)
+			return nil, &APIError{Provider: "GLM", StatusCode: resp.StatusCode, Message: errResp.Error.Message}
 		}
 		log.Printf("[GLM] HTTP错误: %d, 响应: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("GLM API错误: HTTP %d", resp.StatusCode)
+		return nil, &APIError{Provider: "GLM", StatusCode: resp.StatusCode}
 	}
 
 	// 解析响应
@@ -155,11 +196,43 @@ func (p *GLMProvider) ChatCompletion(ctx context.Context, req *ChatCompletionReq
 	return result, nil
 }
 
+// ChatCompletionStream 执行流式聊天完成请求
+func (p *GLMProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	glmReq := glmRequest{
+		Model:       p.model,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		Stream:      true,
+		Tools:       req.Tools,
+		ToolChoice:  req.ToolChoice,
+	}
+
+	return streamOpenAICompatible(ctx, p.client, p.apiURL+"/chat/completions", bearerAuth(p.apiKey), glmReq, "GLM", onDelta)
+}
+
+// Embed 调用 /embeddings 接口将文本转换为向量
+func (p *GLMProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedOpenAICompatible(ctx, p.client, p.apiURL+"/embeddings", bearerAuth(p.apiKey), p.embeddingModel, texts, "GLM")
+}
+
+// EmbeddingModel 获取向量模型名称
+func (p *GLMProvider) EmbeddingModel() string {
+	return p.embeddingModel
+}
+
 // GetModelName 获取模型名称
 func (p *GLMProvider) GetModelName() string {
 	return p.model
 }
 
+// SupportsVision 当前模型是否支持图片输入
+func (p *GLMProvider) SupportsVision() bool {
+	return matchModelPrefix(p.model, p.visionModels)
+}
+
 // GetProviderName 获取提供商名称
 func (p *GLMProvider) GetProviderName() string {
 	return "glm"
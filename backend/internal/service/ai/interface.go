@@ -3,25 +3,75 @@ package ai
 import "context"
 
 // ChatMessage 聊天消息结构
-// Role: 消息角色，可选值为 user(用户)、assistant(AI助手)、system(系统)
-// Content: 消息内容
+// Role: 消息角色，可选值为 user(用户)、assistant(AI助手)、system(系统)、tool(工具结果)
+// Content: 消息的文本内容
+// Parts: 多段内容（文本和图片），非空时按多段内容发送给支持图片的Provider，Content作为纯文本版本保留
+// ToolCalls: assistant消息中模型请求调用的工具
+// ToolCallID: tool消息对应的工具调用ID
+// Name: tool消息对应的工具名称
+// JSON序列化见 multimodal.go，content根据Parts输出为字符串或数组
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+	Name       string        `json:"name,omitempty"`
+}
+
+// ToolCall 模型发起的工具调用（OpenAI格式）
+// ID: 调用ID，工具结果需要通过ToolCallID关联
+// Type: 固定为 "function"
+// Function: 函数名称和JSON格式的参数
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 工具调用的函数名称和参数
+// Arguments: JSON字符串，由模型生成，可能不合法，执行前需要校验
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool 可供模型调用的工具定义（OpenAI格式）
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 工具函数定义
+// Parameters: JSON Schema格式的参数定义
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
 }
 
 // ChatCompletionRequest 聊天完成请求
 // Model: 模型名称，如 glm-4、deepseek-chat、qwen-turbo、moonshot-v1-8k
 // Messages: 消息列表，包含对话历史
-// Temperature: 温度参数，控制输出的随机性，范围0-2，默认0.7
+// Temperature: 温度参数，控制输出的随机性，范围0-2，nil表示使用模型默认值，0会原样发送
 // MaxTokens: 最大生成token数，控制响应长度
+// TopP: 核采样参数，nil表示使用模型默认值
+// Stop: 停止序列，生成到任一序列时结束
 // Stream: 是否使用流式输出
+// Tools: 可供模型调用的工具
+// ToolChoice: 工具选择策略，"auto"(默认) 或 "none"(禁止调用工具)
+// Fallback: 本次请求的降级Provider（如角色配置的备用Provider），只由AIManager使用，不发送给厂商
 type ChatCompletionRequest struct {
 	Model       string        `json:"model"`
 	Messages    []ChatMessage `json:"messages"`
-	Temperature float32       `json:"temperature,omitempty"`
+	Temperature *float32      `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
+	TopP        *float32      `json:"top_p,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	Tools       []Tool        `json:"tools,omitempty"`
+	ToolChoice  string        `json:"tool_choice,omitempty"`
+	Fallback    []string      `json:"-"`
 }
 
 // ChatCompletionResponse 聊天完成响应
@@ -30,18 +80,20 @@ type ChatCompletionRequest struct {
 // Model: 实际使用的模型名称
 // Choices: 生成的回复选项列表
 // Usage: Token使用统计
+// Provider: 实际应答的Provider名称（由AIManager填写，降级时与请求的Provider不同）
 type ChatCompletionResponse struct {
-	ID      string       `json:"id"`
-	Object  string       `json:"object"`
-	Model   string       `json:"model"`
-	Choices []ChatChoice `json:"choices"`
-	Usage   ChatUsage    `json:"usage"`
+	ID       string       `json:"id"`
+	Object   string       `json:"object"`
+	Model    string       `json:"model"`
+	Choices  []ChatChoice `json:"choices"`
+	Usage    ChatUsage    `json:"usage"`
+	Provider string       `json:"provider,omitempty"`
 }
 
 // ChatChoice 聊天选择
 // Index: 选项索引
 // Message: 生成的消息内容
-// FinishReason: 结束原因，如 "stop"(正常结束)、"length"(达到长度限制)
+// FinishReason: 结束原因，如 "stop"(正常结束)、"length"(达到长度限制)、"tool_calls"(请求调用工具)
 type ChatChoice struct {
 	Index        int         `json:"index"`
 	Message      ChatMessage `json:"message"`
@@ -58,6 +110,11 @@ type ChatUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// StreamHandler 流式输出回调
+// delta: 本次收到的增量文本
+// 返回非nil错误时中止流式读取（例如客户端已断开连接）
+type StreamHandler func(delta string) error
+
 // AIProvider AI提供商接口
 // 所有AI服务提供商（GLM、DeepSeek、Qwen、Kimi、OpenAI等）都必须实现此接口
 // 这样可以统一调用方式，方便扩展和切换不同的AI服务
@@ -68,9 +125,23 @@ type AIProvider interface {
 	// 返回: 聊天响应或错误
 	ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error)
 
+	// ChatCompletionStream 执行流式聊天完成请求
+	// ctx: 上下文，用于超时控制和取消操作
+	// req: 聊天请求参数
+	// onDelta: 每收到一段增量内容时调用
+	// 返回: 流结束后汇总的完整响应（包含完整回复和Token使用情况）或错误
+	ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error)
+
 	// GetModelName 获取当前使用的模型名称
 	GetModelName() string
 
 	// GetProviderName 获取提供商名称，如 "glm"、"deepseek"、"qwen"、"kimi"
 	GetProviderName() string
 }
+
+// ModelLister 模型发现接口
+// 支持列出可用模型的Provider（如本地Ollama、OpenAI兼容接口）可选实现此接口
+type ModelLister interface {
+	// ListModels 获取服务端可用的模型名称列表
+	ListModels(ctx context.Context) ([]string, error)
+}
@@ -5,21 +5,43 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	// defaultBreakerThreshold 默认连续失败多少次后熔断
+	defaultBreakerThreshold = 3
+	// defaultBreakerCooldown 默认熔断冷却时间
+	defaultBreakerCooldown = 60 * time.Second
 )
 
 // AIManager AI服务管理器
 // 负责管理多个AI Provider，提供统一的调用接口
+// 调用失败时按降级链依次尝试其他Provider，并对每个Provider单独熔断
 type AIManager struct {
-	providers       map[string]AIProvider
-	defaultProvider string
-	mu              sync.RWMutex
+	providers        map[string]AIProvider
+	configs          map[string]ProviderConfig
+	defaultProvider  string
+	fallbackChain    []string
+	breakers         map[string]*circuitBreaker
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	usageRecorder    UsageRecorder
+	mu               sync.RWMutex
 }
 
 // NewAIManager 创建AI管理器实例
 func NewAIManager() *AIManager {
 	return &AIManager{
-		providers: make(map[string]AIProvider),
+		providers:        make(map[string]AIProvider),
+		configs:          make(map[string]ProviderConfig),
+		breakers:         make(map[string]*circuitBreaker),
+		breakerThreshold: defaultBreakerThreshold,
+		breakerCooldown:  defaultBreakerCooldown,
 	}
 }
 
@@ -37,6 +59,95 @@ func (m *AIManager) RegisterProvider(name string, provider AIProvider) {
 	log.Printf("[AIManager] 注册Provider: %s, 模型: %s", name, provider.GetModelName())
 }
 
+// RegisterProviderConfig 根据配置创建并注册Provider
+// 配置会被保存，可通过GetProviderConfig获取实例级参数（如MaxTokens）
+func (m *AIManager) RegisterProviderConfig(cfg ProviderConfig) error {
+	cfg = cfg.withDefaults()
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	m.RegisterProvider(cfg.Name, provider)
+
+	m.mu.Lock()
+	m.configs[cfg.Name] = cfg
+	m.mu.Unlock()
+	return nil
+}
+
+// ReplaceProvider 根据配置重建Provider并原地替换（用于管理后台编辑）
+// previousName为编辑前的名称，改名时移除旧实例；熔断状态重置
+// 被替换的是默认Provider时仍保持为默认Provider
+func (m *AIManager) ReplaceProvider(previousName string, cfg ProviderConfig) error {
+	cfg = cfg.withDefaults()
+	provider, err := NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if previousName == "" {
+		previousName = cfg.Name
+	}
+	if previousName != cfg.Name {
+		delete(m.providers, previousName)
+		delete(m.configs, previousName)
+		delete(m.breakers, previousName)
+	}
+	m.providers[cfg.Name] = provider
+	m.configs[cfg.Name] = cfg
+	delete(m.breakers, cfg.Name)
+
+	if m.defaultProvider == "" || m.defaultProvider == previousName {
+		m.defaultProvider = cfg.Name
+	}
+	log.Printf("[AIManager] 替换Provider: %s, 模型: %s", cfg.Name, provider.GetModelName())
+	return nil
+}
+
+// RemoveProvider 移除Provider（用于管理后台删除或停用）
+// 移除的是默认Provider时，按名称顺序选择剩余的第一个作为默认Provider
+func (m *AIManager) RemoveProvider(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.providers[name]; !exists {
+		return
+	}
+	delete(m.providers, name)
+	delete(m.configs, name)
+	delete(m.breakers, name)
+
+	if m.defaultProvider == name {
+		m.defaultProvider = ""
+		names := make([]string, 0, len(m.providers))
+		for n := range m.providers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			m.defaultProvider = names[0]
+		}
+	}
+	log.Printf("[AIManager] 移除Provider: %s", name)
+}
+
+// GetProviderConfig 获取Provider的配置
+// 直接通过RegisterProvider注册的Provider没有配置，返回false
+func (m *AIManager) GetProviderConfig(name string) (ProviderConfig, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if name == "" {
+		name = m.defaultProvider
+	}
+	cfg, exists := m.configs[name]
+	return cfg, exists
+}
+
 // SetDefaultProvider 设置默认Provider
 func (m *AIManager) SetDefaultProvider(name string) error {
 	m.mu.Lock()
@@ -93,16 +204,236 @@ func (m *AIManager) HasProvider(name string) bool {
 	return exists
 }
 
+// ListModels 获取Provider服务端可用的模型列表
+// 仅支持实现了ModelLister的Provider（如ollama、OpenAI兼容接口）
+func (m *AIManager) ListModels(ctx context.Context, name string) ([]string, error) {
+	provider, err := m.GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	lister, ok := provider.(ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("provider %s 不支持获取模型列表", provider.GetProviderName())
+	}
+	return lister.ListModels(ctx)
+}
+
+// SetFallbackChain 设置降级链
+// names: 按优先级排列的Provider名称，如 deepseek、qwen、glm
+// 主Provider调用失败（超时、429、5xx）时依次尝试降级链中的Provider
+func (m *AIManager) SetFallbackChain(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chain := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			chain = append(chain, name)
+		}
+	}
+	m.fallbackChain = chain
+	log.Printf("[AIManager] 设置降级链: %v", chain)
+}
+
+// GetFallbackChain 获取降级链
+func (m *AIManager) GetFallbackChain() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]string(nil), m.fallbackChain...)
+}
+
+// SetCircuitBreaker 设置熔断参数
+// threshold: 连续失败多少次后熔断
+// cooldown: 熔断后跳过该Provider的时间
+func (m *AIManager) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if threshold > 0 {
+		m.breakerThreshold = threshold
+	}
+	if cooldown > 0 {
+		m.breakerCooldown = cooldown
+	}
+	m.breakers = make(map[string]*circuitBreaker)
+}
+
+// LoadFailoverFromEnv 从环境变量加载降级链和熔断配置
+// AI_FALLBACK_CHAIN: 逗号分隔的Provider名称，如 deepseek,qwen,glm
+// AI_CIRCUIT_FAILURE_THRESHOLD: 连续失败多少次后熔断
+// AI_CIRCUIT_COOLDOWN: 熔断冷却时间，如 60s、2m
+func (m *AIManager) LoadFailoverFromEnv() {
+	if chain := os.Getenv("AI_FALLBACK_CHAIN"); chain != "" {
+		m.SetFallbackChain(strings.Split(chain, ","))
+	}
+
+	threshold, _ := strconv.Atoi(os.Getenv("AI_CIRCUIT_FAILURE_THRESHOLD"))
+	cooldown, _ := time.ParseDuration(os.Getenv("AI_CIRCUIT_COOLDOWN"))
+	if threshold > 0 || cooldown > 0 {
+		m.SetCircuitBreaker(threshold, cooldown)
+	}
+}
+
+// IsCircuitOpen 判断指定Provider是否处于熔断状态
+func (m *AIManager) IsCircuitOpen(name string) bool {
+	m.mu.RLock()
+	breaker, exists := m.breakers[name]
+	m.mu.RUnlock()
+
+	return exists && breaker.IsOpen()
+}
+
 // ChatCompletion 执行聊天完成请求
 // providerName: Provider名称，为空则使用默认Provider
 // req: 聊天请求
+// 失败时按降级链切换Provider，响应中的Provider字段为实际应答的Provider
 func (m *AIManager) ChatCompletion(ctx context.Context, providerName string, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	provider, err := m.GetProvider(providerName)
+	return m.invoke(ctx, providerName, req, func(provider AIProvider) (*ChatCompletionResponse, bool, error) {
+		resp, err := provider.ChatCompletion(ctx, req)
+		return resp, true, err
+	})
+}
+
+// ChatCompletionStream 执行流式聊天完成请求
+// providerName: Provider名称，为空则使用默认Provider
+// req: 聊天请求
+// onDelta: 每收到一段增量内容时调用
+// 只有在尚未输出任何内容时才会切换到降级链中的其他Provider
+func (m *AIManager) ChatCompletionStream(ctx context.Context, providerName string, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	return m.invoke(ctx, providerName, req, func(provider AIProvider) (*ChatCompletionResponse, bool, error) {
+		emitted := false
+		resp, err := provider.ChatCompletionStream(ctx, req, func(delta string) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		return resp, !emitted, err
+	})
+}
+
+// invoke 按候选顺序调用Provider，处理熔断和降级
+// req.Fallback为本次请求额外的降级Provider，优先于全局降级链
+// 请求包含图片时跳过不支持图片输入的Provider
+// call返回的bool表示失败后是否还能切换到下一个Provider
+func (m *AIManager) invoke(ctx context.Context, providerName string, req *ChatCompletionRequest, call func(provider AIProvider) (*ChatCompletionResponse, bool, error)) (*ChatCompletionResponse, error) {
+	candidates, err := m.candidates(providerName, req.Fallback)
 	if err != nil {
 		return nil, err
 	}
 
-	return provider.ChatCompletion(ctx, req)
+	needsVision := req.HasImages()
+	var lastErr, visionErr error
+	for _, name := range candidates {
+		provider, err := m.GetProvider(name)
+		if err != nil {
+			continue
+		}
+
+		if needsVision && !supportsVision(provider) {
+			log.Printf("[AIManager] Provider %s 不支持图片输入，跳过", name)
+			visionErr = fmt.Errorf("%w: %s", ErrVisionUnsupported, name)
+			continue
+		}
+
+		breaker := m.breaker(name)
+		if !breaker.Allow() {
+			log.Printf("[AIManager] Provider %s 处于熔断状态，跳过", name)
+			continue
+		}
+
+		start := time.Now()
+		resp, canRetry, err := call(provider)
+		m.recordUsage(ctx, name, provider, req, resp, time.Since(start), err)
+		if err == nil {
+			breaker.RecordSuccess()
+			resp.Provider = name
+			if resp.Model == "" {
+				resp.Model = provider.GetModelName()
+			}
+			return resp, nil
+		}
+
+		// 调用方取消或超时与Provider无关，直接返回
+		if ctx.Err() != nil {
+			breaker.Release()
+			return nil, err
+		}
+
+		if !IsRetryable(err) {
+			// Provider可以正常应答，只是请求本身有问题
+			breaker.RecordSuccess()
+			return nil, err
+		}
+
+		if breaker.RecordFailure() {
+			log.Printf("[AIManager] Provider %s 连续失败，熔断 %v", name, m.cooldown())
+		}
+		lastErr = err
+		if !canRetry {
+			return nil, err
+		}
+		log.Printf("[AIManager] Provider %s 调用失败，尝试下一个: %v", name, err)
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	if visionErr != nil {
+		return nil, visionErr
+	}
+	return nil, ErrNoAvailableProvider
+}
+
+// candidates 获取本次调用的候选Provider列表
+// 顺序为: 指定Provider（或默认Provider）+ 请求的降级Provider + 降级链，去重
+func (m *AIManager) candidates(providerName string, fallback []string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	primary := providerName
+	if primary == "" {
+		primary = m.defaultProvider
+	}
+	if primary == "" && len(fallback) == 0 && len(m.fallbackChain) == 0 {
+		return nil, ErrNoAvailableProvider
+	}
+	if _, exists := m.providers[primary]; !exists && providerName != "" {
+		return nil, fmt.Errorf("provider %s 不存在", providerName)
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(fallback)+len(m.fallbackChain)+1)
+	ordered := append(append([]string{primary}, fallback...), m.fallbackChain...)
+	for _, name := range ordered {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// breaker 获取Provider对应的熔断器，不存在时创建
+func (m *AIManager) breaker(name string) *circuitBreaker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	breaker, exists := m.breakers[name]
+	if !exists {
+		breaker = newCircuitBreaker(m.breakerThreshold, m.breakerCooldown)
+		m.breakers[name] = breaker
+	}
+	return breaker
+}
+
+// cooldown 获取熔断冷却时间
+func (m *AIManager) cooldown() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.breakerCooldown
 }
 
 // ClearProviders 清空所有Provider（用于重新加载配置）
@@ -111,77 +442,42 @@ func (m *AIManager) ClearProviders() {
 	defer m.mu.Unlock()
 
 	m.providers = make(map[string]AIProvider)
+	m.configs = make(map[string]ProviderConfig)
+	m.breakers = make(map[string]*circuitBreaker)
 	m.defaultProvider = ""
 	log.Printf("[AIManager] 清空所有Provider")
 }
 
 // LoadProvidersFromEnv 从环境变量加载Provider配置
 // 这是一个fallback机制，当数据库配置不存在时使用
+// 每种已注册的Provider类型读取 <前缀>_API_KEY、<前缀>_API_URL、<前缀>_MODEL
+// 不需要密钥的本地类型（如ollama）只有配置了 <前缀>_API_URL 才会加载
 func (m *AIManager) LoadProvidersFromEnv() {
-	// GLM
-	if apiKey := os.Getenv("GLM_API_KEY"); apiKey != "" {
-		apiURL := os.Getenv("GLM_API_URL")
-		if apiURL == "" {
-			apiURL = "https://open.bigmodel.cn/api/paas/v4"
-		}
-		model := os.Getenv("GLM_MODEL")
-		if model == "" {
-			model = "glm-4-flash"
+	for _, kind := range RegisteredKinds() {
+		if kind.EnvPrefix == "" {
+			continue
 		}
-		m.RegisterProvider("glm", NewGLMProvider(apiURL, apiKey, model))
-	}
 
-	// DeepSeek
-	if apiKey := os.Getenv("DEEPSEEK_API_KEY"); apiKey != "" {
-		apiURL := os.Getenv("DEEPSEEK_API_URL")
-		if apiURL == "" {
-			apiURL = "https://api.deepseek.com/v1"
+		apiKey := os.Getenv(kind.EnvPrefix + "_API_KEY")
+		apiURL := os.Getenv(kind.EnvPrefix + "_API_URL")
+		if kind.RequiresAPIKey && apiKey == "" {
+			continue
 		}
-		model := os.Getenv("DEEPSEEK_MODEL")
-		if model == "" {
-			model = "deepseek-chat"
+		if !kind.RequiresAPIKey && apiURL == "" {
+			continue
 		}
-		m.RegisterProvider("deepseek", NewDeepSeekProvider(apiURL, apiKey, model))
-	}
-
-	// Qwen
-	if apiKey := os.Getenv("QWEN_API_KEY"); apiKey != "" {
-		apiURL := os.Getenv("QWEN_API_URL")
-		if apiURL == "" {
-			apiURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
-		}
-		model := os.Getenv("QWEN_MODEL")
-		if model == "" {
-			model = "qwen-turbo"
-		}
-		m.RegisterProvider("qwen", NewQwenProvider(apiURL, apiKey, model))
-	}
-
-	// Kimi
-	if apiKey := os.Getenv("KIMI_API_KEY"); apiKey != "" {
-		apiURL := os.Getenv("KIMI_API_URL")
-		if apiURL == "" {
-			apiURL = "https://api.moonshot.cn/v1"
-		}
-		model := os.Getenv("KIMI_MODEL")
-		if model == "" {
-			model = "moonshot-v1-8k"
-		}
-		m.RegisterProvider("kimi", NewKimiProvider(apiURL, apiKey, model))
-	}
 
-	// OpenAI
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-		apiURL := os.Getenv("OPENAI_API_URL")
-		if apiURL == "" {
-			apiURL = "https://api.openai.com/v1"
+		cfg := ProviderConfig{
+			Name:   kind.Kind,
+			Kind:   kind.Kind,
+			APIURL: apiURL,
+			APIKey: apiKey,
+			Model:  os.Getenv(kind.EnvPrefix + "_MODEL"),
 		}
-		model := os.Getenv("OPENAI_MODEL")
-		if model == "" {
-			model = "gpt-3.5-turbo"
+		if err := m.RegisterProviderConfig(cfg); err != nil {
+			log.Printf("[AIManager] 从环境变量加载 %s 失败: %v", kind.Kind, err)
 		}
-		m.RegisterProvider("openai", NewOpenAIProvider(apiURL, apiKey, model))
 	}
 
-	log.Printf("[AIManager] 从环境变量加载完成，共 %d 个Provider", len(m.providers))
+	log.Printf("[AIManager] 从环境变量加载完成，共 %d 个Provider", len(m.GetAllProviders()))
 }
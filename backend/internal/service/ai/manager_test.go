@@ -0,0 +1,243 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newMockServer 创建返回固定状态码的mock服务器，并统计请求次数
+func newMockServer(t *testing.T, statusCode int, content string, hits *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if statusCode != http.StatusOK {
+			w.WriteHeader(statusCode)
+			w.Write([]byte(`{"error":{"message":"mock error"}}`))
+			return
+		}
+		resp := map[string]interface{}{
+			"id":    "test",
+			"model": "",
+			"choices": []map[string]interface{}{
+				{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"},
+			},
+			"usage": map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// 测试主Provider返回可重试错误时切换到降级链中的Provider
+func TestAIManager_Failover(t *testing.T) {
+	testCases := []struct {
+		name            string
+		primaryStatus   int
+		expectProvider  string
+		expectErr       bool
+		expectFallbacks int32
+	}{
+		{name: "HTTP 500 failover", primaryStatus: 500, expectProvider: "qwen", expectFallbacks: 1},
+		{name: "HTTP 429 failover", primaryStatus: 429, expectProvider: "qwen", expectFallbacks: 1},
+		{name: "HTTP 401 no failover", primaryStatus: 401, expectErr: true, expectFallbacks: 0},
+		{name: "Primary ok", primaryStatus: 200, expectProvider: "deepseek", expectFallbacks: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var primaryHits, fallbackHits int32
+			primary := newMockServer(t, tc.primaryStatus, "primary", &primaryHits)
+			defer primary.Close()
+			fallback := newMockServer(t, http.StatusOK, "fallback", &fallbackHits)
+			defer fallback.Close()
+
+			manager := NewAIManager()
+			manager.RegisterProvider("deepseek", NewDeepSeekProvider(primary.URL, "key", "deepseek-chat"))
+			manager.RegisterProvider("qwen", NewQwenProvider(fallback.URL, "key", "qwen-turbo"))
+			manager.SetFallbackChain([]string{"deepseek", "qwen"})
+
+			req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+			resp, err := manager.ChatCompletion(context.Background(), "", req)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("Expected error but got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if resp.Provider != tc.expectProvider {
+					t.Errorf("Expected provider '%s', got '%s'", tc.expectProvider, resp.Provider)
+				}
+				if resp.Model == "" {
+					t.Error("Model should fall back to provider model name")
+				}
+			}
+			if fallbackHits != tc.expectFallbacks {
+				t.Errorf("Expected %d fallback calls, got %d", tc.expectFallbacks, fallbackHits)
+			}
+		})
+	}
+}
+
+// 测试熔断：连续失败后跳过Provider，冷却期结束后放行探测请求
+func TestAIManager_CircuitBreaker(t *testing.T) {
+	var primaryHits, fallbackHits int32
+	primary := newMockServer(t, http.StatusServiceUnavailable, "", &primaryHits)
+	defer primary.Close()
+	fallback := newMockServer(t, http.StatusOK, "fallback", &fallbackHits)
+	defer fallback.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(primary.URL, "key", "deepseek-chat"))
+	manager.RegisterProvider("glm", NewGLMProvider(fallback.URL, "key", "glm-4"))
+	manager.SetFallbackChain([]string{"glm"})
+	manager.SetCircuitBreaker(2, time.Minute)
+
+	now := time.Now()
+	manager.breaker("deepseek").now = func() time.Time { return now }
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+	for i := 0; i < 4; i++ {
+		resp, err := manager.ChatCompletion(context.Background(), "deepseek", req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.Provider != "glm" {
+			t.Errorf("Expected provider 'glm', got '%s'", resp.Provider)
+		}
+	}
+
+	// 两次失败后熔断，后续请求不再访问主Provider
+	if primaryHits != 2 {
+		t.Errorf("Expected 2 primary calls before circuit opens, got %d", primaryHits)
+	}
+	if !manager.IsCircuitOpen("deepseek") {
+		t.Error("Circuit should be open")
+	}
+
+	// 冷却期结束后放行一个探测请求
+	now = now.Add(2 * time.Minute)
+	if _, err := manager.ChatCompletion(context.Background(), "deepseek", req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if primaryHits != 3 {
+		t.Errorf("Expected probe call after cooldown, got %d primary calls", primaryHits)
+	}
+}
+
+// stubProvider 流式输出一段内容后返回可重试错误的Provider
+type stubProvider struct{}
+
+func (stubProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	return nil, &APIError{Provider: "Stub", StatusCode: http.StatusBadGateway}
+}
+
+func (stubProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	if err := onDelta("半"); err != nil {
+		return nil, err
+	}
+	return nil, &APIError{Provider: "Stub", StatusCode: http.StatusBadGateway}
+}
+
+func (stubProvider) GetModelName() string    { return "stub" }
+func (stubProvider) GetProviderName() string { return "stub" }
+
+// 测试流式请求：已输出内容后不再切换Provider
+func TestAIManager_StreamNoFailoverAfterOutput(t *testing.T) {
+	var fallbackHits int32
+	fallback := newMockServer(t, http.StatusOK, "fallback", &fallbackHits)
+	defer fallback.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("stub", stubProvider{})
+	manager.RegisterProvider("qwen", NewQwenProvider(fallback.URL, "key", "qwen-turbo"))
+	manager.SetFallbackChain([]string{"qwen"})
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+	_, err := manager.ChatCompletionStream(context.Background(), "stub", req, func(delta string) error { return nil })
+	if err == nil {
+		t.Fatal("Expected error but got nil")
+	}
+	if fallbackHits != 0 {
+		t.Errorf("Expected no fallback after output, got %d calls", fallbackHits)
+	}
+
+	// 非流式请求在同样的错误下会切换Provider
+	resp, err := manager.ChatCompletion(context.Background(), "stub", req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Provider != "qwen" {
+		t.Errorf("Expected provider 'qwen', got '%s'", resp.Provider)
+	}
+}
+
+// 测试移除Provider：配置一并清除，默认Provider自动切换
+func TestAIManager_RemoveProvider(t *testing.T) {
+	manager := NewAIManager()
+	for _, name := range []string{"deepseek", "qwen", "kimi"} {
+		if err := manager.RegisterProviderConfig(ProviderConfig{Name: name, APIKey: "key"}); err != nil {
+			t.Fatalf("RegisterProviderConfig failed: %v", err)
+		}
+	}
+
+	manager.RemoveProvider("deepseek")
+	if manager.HasProvider("deepseek") {
+		t.Error("Expected deepseek removed")
+	}
+	if _, ok := manager.GetProviderConfig("deepseek"); ok {
+		t.Error("Expected deepseek config removed")
+	}
+	provider, err := manager.GetProvider("")
+	if err != nil || provider.GetProviderName() != "kimi" {
+		t.Errorf("Expected default provider kimi, got %v, %v", provider, err)
+	}
+
+	manager.RemoveProvider("unknown")
+	if len(manager.GetAllProviders()) != 2 {
+		t.Errorf("Expected 2 providers left, got %d", len(manager.GetAllProviders()))
+	}
+}
+
+// 测试替换Provider：编辑或改名默认Provider后仍是默认Provider
+func TestAIManager_ReplaceProvider(t *testing.T) {
+	manager := NewAIManager()
+	for _, name := range []string{"deepseek", "kimi"} {
+		if err := manager.RegisterProviderConfig(ProviderConfig{Name: name, APIKey: "key"}); err != nil {
+			t.Fatalf("RegisterProviderConfig failed: %v", err)
+		}
+	}
+
+	if err := manager.ReplaceProvider("deepseek", ProviderConfig{Name: "deepseek", APIKey: "key", Model: "deepseek-reasoner"}); err != nil {
+		t.Fatalf("ReplaceProvider failed: %v", err)
+	}
+	provider, err := manager.GetProvider("")
+	if err != nil || provider.GetModelName() != "deepseek-reasoner" {
+		t.Errorf("Expected edited deepseek to stay default, got %v, %v", provider, err)
+	}
+
+	if err := manager.ReplaceProvider("deepseek", ProviderConfig{Name: "deepseek-main", Kind: "deepseek", APIKey: "key"}); err != nil {
+		t.Fatalf("ReplaceProvider failed: %v", err)
+	}
+	if manager.HasProvider("deepseek") {
+		t.Error("Expected old name removed after rename")
+	}
+	if cfg, ok := manager.GetProviderConfig(""); !ok || cfg.Name != "deepseek-main" {
+		t.Errorf("Expected renamed provider to stay default, got %+v", cfg)
+	}
+	if len(manager.GetAllProviders()) != 2 {
+		t.Errorf("Expected 2 providers, got %d", len(manager.GetAllProviders()))
+	}
+
+	if err := manager.ReplaceProvider("kimi", ProviderConfig{Name: "kimi", Kind: "unknown"}); err == nil {
+		t.Error("Expected error for unknown kind")
+	}
+	if !manager.HasProvider("kimi") {
+		t.Error("Expected kimi kept when replacement fails")
+	}
+}
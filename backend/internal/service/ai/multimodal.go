@@ -0,0 +1,170 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrVisionUnsupported Provider不支持图片输入
+var ErrVisionUnsupported = errors.New("当前模型不支持图片输入")
+
+// imageTokenEstimate 每张图片估算消耗的Token数
+// 各厂商按分辨率计费，这里取常见尺寸的保守值，只用于上下文裁剪
+const imageTokenEstimate = 800
+
+// ContentPart 多段消息内容中的一段（OpenAI格式）
+// Type: "text" 或 "image_url"
+// ImageURL.URL可以是公网地址，也可以是 data:image/png;base64,... 格式的内联图片
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL 图片地址
+// Detail: 图片精度，"low"、"high" 或 "auto"，为空时使用厂商默认值
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// TextPart 创建文本内容
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: "text", Text: text}
+}
+
+// ImagePart 创建图片内容
+func ImagePart(url string) ContentPart {
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+// VisionCapable 可选接口，声明Provider当前配置的模型是否支持图片输入
+// 未实现此接口的Provider视为不支持
+type VisionCapable interface {
+	SupportsVision() bool
+}
+
+// VisionOption Provider配置中声明是否支持图片输入的配置项
+// "true" 表示当前模型支持图片，"false" 表示不支持，未配置时按模型名称判断
+const VisionOption = "vision"
+
+// visionModels 根据配置项确定支持图片输入的模型前缀
+// 返回包含空字符串的列表表示所有模型都支持
+func visionModels(options map[string]string, defaults []string) ([]string, error) {
+	value, ok := options[VisionOption]
+	if !ok {
+		return defaults, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 必须为 true 或 false", VisionOption)
+	}
+	if enabled {
+		return []string{""}, nil
+	}
+	return nil, nil
+}
+
+// supportsVision 判断Provider是否支持图片输入
+func supportsVision(provider AIProvider) bool {
+	v, ok := provider.(VisionCapable)
+	return ok && v.SupportsVision()
+}
+
+// matchModelPrefix 判断模型名称是否以任一前缀开头（不区分大小写）
+func matchModelPrefix(model string, prefixes []string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasImages 消息是否包含图片
+func (m ChatMessage) HasImages() bool {
+	for _, part := range m.Parts {
+		if part.Type == "image_url" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasImages 请求中是否有消息包含图片
+func (r *ChatCompletionRequest) HasImages() bool {
+	for _, msg := range r.Messages {
+		if msg.HasImages() {
+			return true
+		}
+	}
+	return false
+}
+
+// chatMessageJSON ChatMessage的JSON格式，content可以是字符串或多段内容
+type chatMessageJSON struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+}
+
+// MarshalJSON Parts非空时content输出为多段内容数组，否则输出为字符串
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	var content interface{} = m.Content
+	if len(m.Parts) > 0 {
+		content = m.Parts
+	}
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(chatMessageJSON{
+		Role:       m.Role,
+		Content:    raw,
+		ToolCalls:  m.ToolCalls,
+		ToolCallID: m.ToolCallID,
+		Name:       m.Name,
+	})
+}
+
+// UnmarshalJSON 兼容字符串和多段内容两种content格式
+// 多段内容中的文本会拼接到Content，便于只处理文本的调用方使用
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	var msg chatMessageJSON
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	*m = ChatMessage{
+		Role:       msg.Role,
+		ToolCalls:  msg.ToolCalls,
+		ToolCallID: msg.ToolCallID,
+		Name:       msg.Name,
+	}
+
+	content := strings.TrimSpace(string(msg.Content))
+	switch {
+	case content == "" || content == "null":
+	case strings.HasPrefix(content, "["):
+		if err := json.Unmarshal(msg.Content, &m.Parts); err != nil {
+			return fmt.Errorf("解析多段消息内容失败: %w", err)
+		}
+		var texts []string
+		for _, part := range m.Parts {
+			if part.Type == "text" {
+				texts = append(texts, part.Text)
+			}
+		}
+		m.Content = strings.Join(texts, "\n")
+	default:
+		if err := json.Unmarshal(msg.Content, &m.Content); err != nil {
+			return fmt.Errorf("解析消息内容失败: %w", err)
+		}
+	}
+	return nil
+}
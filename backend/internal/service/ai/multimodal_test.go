@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 测试消息序列化：有图片时content为数组，否则为字符串
+func TestChatMessage_MarshalJSON(t *testing.T) {
+	plain, err := json.Marshal(ChatMessage{Role: "user", Content: "你好"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(plain), `"content":"你好"`) {
+		t.Errorf("Expected string content, got %s", plain)
+	}
+
+	msg := ChatMessage{
+		Role:    "user",
+		Content: "这是什么",
+		Parts:   []ContentPart{TextPart("这是什么"), ImagePart("https://example.com/a.png")},
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"content":[{"type":"text"`) {
+		t.Errorf("Expected content parts, got %s", data)
+	}
+
+	var decoded ChatMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Content != "这是什么" || !decoded.HasImages() {
+		t.Errorf("Unexpected decoded message: %+v", decoded)
+	}
+	if decoded.Parts[1].ImageURL.URL != "https://example.com/a.png" {
+		t.Errorf("Unexpected image url: %+v", decoded.Parts[1].ImageURL)
+	}
+}
+
+// 测试按模型名称判断是否支持图片输入
+func TestSupportsVision(t *testing.T) {
+	testCases := []struct {
+		name     string
+		provider AIProvider
+		expected bool
+	}{
+		{name: "gpt-4o", provider: NewOpenAIProvider("", "key", "gpt-4o-mini"), expected: true},
+		{name: "gpt-3.5", provider: NewOpenAIProvider("", "key", "gpt-3.5-turbo"), expected: false},
+		{name: "glm-4v", provider: NewGLMProvider("", "key", "glm-4v-flash"), expected: true},
+		{name: "glm-4", provider: NewGLMProvider("", "key", "glm-4-flash"), expected: false},
+		{name: "qwen-vl", provider: NewQwenProvider("", "key", "qwen-vl-plus"), expected: true},
+		{name: "deepseek", provider: NewDeepSeekProvider("", "key", "deepseek-chat"), expected: false},
+		{name: "stub", provider: stubProvider{}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := supportsVision(tc.provider); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	provider, err := NewProvider(ProviderConfig{Name: "deepseek", APIKey: "key", Options: map[string]string{VisionOption: "true"}})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if !supportsVision(provider) {
+		t.Error("Expected vision option to enable image input")
+	}
+	if _, err := NewProvider(ProviderConfig{Name: "openai", APIKey: "key", Options: map[string]string{VisionOption: "maybe"}}); err == nil {
+		t.Error("Expected error for invalid vision option")
+	}
+}
+
+// 测试包含图片的请求跳过不支持图片的Provider
+func TestAIManager_VisionFailover(t *testing.T) {
+	var textHits, visionHits int32
+	text := newMockServer(t, http.StatusOK, "text", &textHits)
+	defer text.Close()
+	vision := newMockServer(t, http.StatusOK, "vision", &visionHits)
+	defer vision.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(text.URL, "key", "deepseek-chat"))
+	manager.RegisterProvider("qwen", NewQwenProvider(vision.URL, "key", "qwen-vl-plus"))
+	manager.SetFallbackChain([]string{"deepseek", "qwen"})
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{
+		Role:    "user",
+		Content: "看图",
+		Parts:   []ContentPart{TextPart("看图"), ImagePart("data:image/png;base64,AAAA")},
+	}}}
+	resp, err := manager.ChatCompletion(context.Background(), "", req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Provider != "qwen" || textHits != 0 {
+		t.Errorf("Expected qwen without calling deepseek, got %s (%d text calls)", resp.Provider, textHits)
+	}
+
+	// 没有支持图片的Provider时返回明确的错误
+	manager.RemoveProvider("qwen")
+	_, err = manager.ChatCompletion(context.Background(), "", req)
+	if !errors.Is(err, ErrVisionUnsupported) {
+		t.Errorf("Expected ErrVisionUnsupported, got %v", err)
+	}
+}
+
+// 测试图片的Token估算
+func TestEstimateMessageTokens_Images(t *testing.T) {
+	text := ChatMessage{Role: "user", Content: "看图"}
+	withImage := ChatMessage{Role: "user", Content: "看图", Parts: []ContentPart{TextPart("看图"), ImagePart("https://example.com/a.png")}}
+	ratio := TokenRatioFor("openai")
+	if diff := ratio.EstimateMessageTokens(withImage) - ratio.EstimateMessageTokens(text); diff != imageTokenEstimate {
+		t.Errorf("Expected image to add %d tokens, got %d", imageTokenEstimate, diff)
+	}
+}
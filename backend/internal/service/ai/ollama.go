@@ -0,0 +1,453 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// llamacppQuirks llama.cpp服务端的OpenAI兼容接口差异
+// 本地服务通常不需要密钥，Token使用情况在usage缺失时从timings计数器读取
+var llamacppQuirks = CompatQuirks{Tag: "LlamaCpp", ProviderName: "llamacpp", StreamUsage: true}
+
+func init() {
+	// 本地Provider不需要API密钥，只有显式配置了地址才会从环境变量加载
+	RegisterKind(ProviderKind{
+		Kind:        "ollama",
+		DisplayName: "Ollama（本地）",
+		DefaultURL:  "http://localhost:11434",
+		EnvPrefix:   "OLLAMA",
+		Factory: func(cfg ProviderConfig) (AIProvider, error) {
+			p := NewOllamaProvider(cfg.APIURL, cfg.APIKey, cfg.Model)
+			if model := cfg.Options[EmbeddingModelOption]; model != "" {
+				p.embeddingModel = model
+			}
+			return p, nil
+		},
+	})
+	RegisterKind(ProviderKind{
+		Kind:        "llamacpp",
+		DisplayName: "llama.cpp（本地）",
+		EnvPrefix:   "LLAMACPP",
+		Factory:     compatFactory(llamacppQuirks),
+	})
+}
+
+// OllamaProvider 本地Ollama服务提供商
+// 使用Ollama原生的 /api/chat 接口，模型未配置时从 /api/tags 自动选择
+type OllamaProvider struct {
+	apiURL         string
+	apiKey         string
+	model          string
+	embeddingModel string
+	client         *http.Client
+	mu             sync.Mutex
+}
+
+// ollamaDefaultEmbeddingModel Ollama默认的向量模型，需要先在服务端拉取
+const ollamaDefaultEmbeddingModel = "nomic-embed-text"
+
+// NewOllamaProvider 创建Ollama Provider实例
+// apiURL: 服务地址，如 http://localhost:11434
+// apiKey: 可选，经过反向代理鉴权时使用
+// model: 模型名称，如 qwen2.5:7b；为空时使用服务端的第一个模型
+func NewOllamaProvider(apiURL, apiKey, model string) *OllamaProvider {
+	return &OllamaProvider{
+		apiURL:         strings.TrimRight(apiURL, "/"),
+		apiKey:         apiKey,
+		model:          model,
+		embeddingModel: ollamaDefaultEmbeddingModel,
+		client: &http.Client{
+			// 本地模型首次加载较慢，超时时间比云端服务更长
+			Timeout: 3 * time.Minute,
+		},
+	}
+}
+
+// ollamaRequest Ollama /api/chat 请求格式
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options,omitempty"`
+	Tools    []Tool          `json:"tools,omitempty"`
+}
+
+// ollamaMessage Ollama消息格式
+// 与OpenAI格式的区别：工具调用参数是JSON对象而不是字符串，且没有调用ID
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+// ollamaToolCall Ollama工具调用格式
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// convertOllamaMessages 将统一消息格式转换为Ollama格式
+func convertOllamaMessages(messages []ChatMessage) []ollamaMessage {
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		converted := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			var oc ollamaToolCall
+			oc.Function.Name = call.Function.Name
+			oc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(oc.Function.Arguments) {
+				oc.Function.Arguments = json.RawMessage("{}")
+			}
+			converted.ToolCalls = append(converted.ToolCalls, oc)
+		}
+		result = append(result, converted)
+	}
+	return result
+}
+
+// ollamaToolCalls 将Ollama工具调用转换为统一格式，按顺序生成调用ID
+func ollamaToolCalls(calls []ollamaToolCall, offset int) []ToolCall {
+	var result []ToolCall
+	for i, call := range calls {
+		arguments := string(call.Function.Arguments)
+		if arguments == "" {
+			arguments = "{}"
+		}
+		result = append(result, ToolCall{
+			ID:       fmt.Sprintf("call_%d", offset+i),
+			Type:     "function",
+			Function: ToolCallFunction{Name: call.Function.Name, Arguments: arguments},
+		})
+	}
+	return result
+}
+
+// ollamaOptions Ollama模型参数
+// NumPredict: 最大生成Token数，对应max_tokens
+type ollamaOptions struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// ollamaResponse Ollama /api/chat 响应格式
+// 流式响应中每行一个对象，最后一个对象done为true并包含计数器
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       string        `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// usage 将计数器转换为统一的Token使用情况
+func (r *ollamaResponse) usage() ChatUsage {
+	return ChatUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// ollamaFinishReason 将done_reason转换为统一的FinishReason
+// Ollama调用工具时done_reason仍为stop，需要根据是否有工具调用判断
+func ollamaFinishReason(doneReason string, toolCalls []ToolCall) string {
+	if len(toolCalls) > 0 {
+		return "tool_calls"
+	}
+	if doneReason == "" {
+		return "stop"
+	}
+	return doneReason
+}
+
+// resolveModel 获取实际使用的模型，未配置时从服务端发现
+func (p *OllamaProvider) resolveModel(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	model := p.model
+	p.mu.Unlock()
+	if model != "" {
+		return model, nil
+	}
+
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(models) == 0 {
+		return "", fmt.Errorf("Ollama服务端没有可用的模型")
+	}
+
+	p.mu.Lock()
+	p.model = models[0]
+	p.mu.Unlock()
+	log.Printf("[Ollama] 未配置模型，自动选择: %s", models[0])
+	return models[0], nil
+}
+
+// post 发送 /api/chat 请求
+func (p *OllamaProvider) post(ctx context.Context, client *http.Client, req *ChatCompletionRequest, stream bool) (*http.Response, error) {
+	model, err := p.resolveModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ollamaReq := ollamaRequest{
+		Model:    model,
+		Messages: convertOllamaMessages(req.Messages),
+		Stream:   stream,
+		Options: ollamaOptions{
+			Temperature: req.Temperature,
+			NumPredict:  req.MaxTokens,
+			TopP:        req.TopP,
+			Stop:        req.Stop,
+		},
+	}
+	// Ollama不支持tool_choice，禁止调用工具时不发送工具定义
+	if req.ToolChoice != "none" {
+		ollamaReq.Tools = req.Tools
+	}
+
+	jsonReq, err := json.Marshal(ollamaReq)
+	if err != nil {
+		log.Printf("[Ollama] 序列化请求失败: %v", err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.apiURL+"/api/chat", bytes.NewBuffer(jsonReq))
+	if err != nil {
+		log.Printf("[Ollama] 创建请求失败: %v", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		log.Printf("[Ollama] 请求失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var errResp ollamaResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+			log.Printf("[Ollama] API错误: %s", errResp.Error)
+			return nil, &APIError{Provider: "Ollama", StatusCode: resp.StatusCode, Message: errResp.Error}
+		}
+		log.Printf("[Ollama] HTTP错误: %d, 响应: %s", resp.StatusCode, string(body))
+		return nil, &APIError{Provider: "Ollama", StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// setHeaders 设置请求头，配置了密钥时附带Bearer鉴权
+func (p *OllamaProvider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+}
+
+// ChatCompletion 执行聊天完成请求
+func (p *OllamaProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	resp, err := p.post(ctx, p.client, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ollamaResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		log.Printf("[Ollama] 解析响应失败: %v", err)
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	toolCalls := ollamaToolCalls(ollamaResp.Message.ToolCalls, 0)
+	result := &ChatCompletionResponse{
+		ID:     "ollama-" + ollamaResp.CreatedAt,
+		Object: "chat.completion",
+		Model:  ollamaResp.Model,
+		Choices: []ChatChoice{
+			{
+				Index:        0,
+				Message:      ChatMessage{Role: "assistant", Content: ollamaResp.Message.Content, ToolCalls: toolCalls},
+				FinishReason: ollamaFinishReason(ollamaResp.DoneReason, toolCalls),
+			},
+		},
+		Usage: ollamaResp.usage(),
+	}
+
+	log.Printf("[Ollama] 请求成功, 模型: %s, Token使用: %d", result.Model, result.Usage.TotalTokens)
+	return result, nil
+}
+
+// ChatCompletionStream 执行流式聊天完成请求
+// Ollama的流式响应为每行一个JSON对象（NDJSON），而不是SSE
+func (p *OllamaProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	streamClient := *p.client
+	streamClient.Timeout = streamTimeout
+	resp, err := p.post(ctx, &streamClient, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ChatCompletionResponse{Object: "chat.completion"}
+	var content strings.Builder
+	var toolCalls []ToolCall
+	finishReason := ""
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			log.Printf("[Ollama] 解析流式数据失败: %v, 数据: %s", err, string(line))
+			return nil, fmt.Errorf("解析响应失败: %w", err)
+		}
+		if chunk.Error != "" {
+			log.Printf("[Ollama] 流式API错误: %s", chunk.Error)
+			return nil, &APIError{Provider: "Ollama", StatusCode: http.StatusInternalServerError, Message: chunk.Error}
+		}
+
+		result.Model = chunk.Model
+		toolCalls = append(toolCalls, ollamaToolCalls(chunk.Message.ToolCalls, len(toolCalls))...)
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Done {
+			result.ID = "ollama-" + chunk.CreatedAt
+			result.Usage = chunk.usage()
+			finishReason = ollamaFinishReason(chunk.DoneReason, toolCalls)
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("[Ollama] 读取流式响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	result.Choices = []ChatChoice{
+		{
+			Index:        0,
+			Message:      ChatMessage{Role: "assistant", Content: content.String(), ToolCalls: toolCalls},
+			FinishReason: finishReason,
+		},
+	}
+
+	log.Printf("[Ollama] 流式请求成功, 模型: %s, Token使用: %d", result.Model, result.Usage.TotalTokens)
+	return result, nil
+}
+
+// ListModels 通过 /api/tags 获取本地已下载的模型列表
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.apiURL+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		log.Printf("[Ollama] 获取模型列表失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Provider: "Ollama", StatusCode: resp.StatusCode}
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	names := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// Embed 通过 /api/embed 将文本转换为向量
+func (p *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	jsonReq, err := json.Marshal(map[string]interface{}{"model": p.embeddingModel, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.apiURL+"/api/embed", bytes.NewBuffer(jsonReq))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		log.Printf("[Ollama] 向量化请求失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var embResp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Error      string      `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Provider: "Ollama", StatusCode: resp.StatusCode, Message: embResp.Error}
+	}
+	if len(embResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Ollama 返回了 %d 个向量，期望 %d 个", len(embResp.Embeddings), len(texts))
+	}
+	return embResp.Embeddings, nil
+}
+
+// EmbeddingModel 获取向量模型名称
+func (p *OllamaProvider) EmbeddingModel() string {
+	return p.embeddingModel
+}
+
+// GetModelName 获取模型名称
+func (p *OllamaProvider) GetModelName() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.model
+}
+
+// GetProviderName 获取提供商名称
+func (p *OllamaProvider) GetProviderName() string {
+	return "ollama"
+}
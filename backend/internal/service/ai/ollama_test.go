@@ -0,0 +1,166 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newOllamaServer 创建模拟的Ollama服务端
+func newOllamaServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Authorization header should not be set without api key")
+		}
+
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"qwen2.5:7b"},{"name":"llama3:8b"}]}`))
+		case "/api/chat":
+			var req ollamaRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "qwen2.5:7b" {
+				t.Errorf("Expected discovered model 'qwen2.5:7b', got '%s'", req.Model)
+			}
+			if req.Options.NumPredict != 100 {
+				t.Errorf("Expected num_predict 100, got %d", req.Options.NumPredict)
+			}
+
+			if !req.Stream {
+				fmt.Fprint(w, `{"model":"qwen2.5:7b","created_at":"t1","message":{"role":"assistant","content":"你好"},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}`)
+				return
+			}
+			fmt.Fprintln(w, `{"model":"qwen2.5:7b","message":{"role":"assistant","content":"你"},"done":false}`)
+			fmt.Fprintln(w, `{"model":"qwen2.5:7b","message":{"role":"assistant","content":"好"},"done":false}`)
+			fmt.Fprintln(w, `{"model":"qwen2.5:7b","created_at":"t2","message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":12,"eval_count":2}`)
+		default:
+			t.Errorf("Unexpected path '%s'", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// 测试Ollama：无密钥、自动发现模型、从计数器读取Token使用情况
+func TestOllamaProvider_ChatCompletion(t *testing.T) {
+	server := newOllamaServer(t)
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{Name: "ollama", APIURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	req := &ChatCompletionRequest{
+		Messages:  []ChatMessage{{Role: "user", Content: "test"}},
+		MaxTokens: 100,
+	}
+	resp, err := provider.ChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "你好" {
+		t.Errorf("Expected content '你好', got '%s'", resp.Choices[0].Message.Content)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 3 || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+	if provider.GetModelName() != "qwen2.5:7b" {
+		t.Errorf("Expected model 'qwen2.5:7b', got '%s'", provider.GetModelName())
+	}
+}
+
+// 测试Ollama的NDJSON流式响应
+func TestOllamaProvider_ChatCompletionStream(t *testing.T) {
+	server := newOllamaServer(t)
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "", "")
+
+	var deltas []string
+	req := &ChatCompletionRequest{
+		Messages:  []ChatMessage{{Role: "user", Content: "test"}},
+		MaxTokens: 100,
+	}
+	resp, err := provider.ChatCompletionStream(context.Background(), req, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+	if strings.Join(deltas, "|") != "你|好" {
+		t.Errorf("Unexpected deltas: %v", deltas)
+	}
+	if resp.Choices[0].Message.Content != "你好" || resp.Choices[0].FinishReason != "length" {
+		t.Errorf("Unexpected choice: %+v", resp.Choices[0])
+	}
+	if resp.Usage.TotalTokens != 14 {
+		t.Errorf("Expected total tokens 14, got %d", resp.Usage.TotalTokens)
+	}
+}
+
+// 测试llama.cpp：无密钥，usage缺失时从timings读取
+func TestLlamaCppProvider_Timings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Authorization header should not be set without api key")
+		}
+		if r.URL.Path == "/v1/models" {
+			w.Write([]byte(`{"data":[{"id":"local.gguf"}]}`))
+			return
+		}
+		w.Write([]byte(`{"id":"x","model":"local.gguf","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"timings":{"prompt_n":7,"predicted_n":2}}`))
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	if err := manager.RegisterProviderConfig(ProviderConfig{Name: "llamacpp", APIURL: server.URL + "/v1"}); err != nil {
+		t.Fatalf("RegisterProviderConfig failed: %v", err)
+	}
+
+	resp, err := manager.ChatCompletion(context.Background(), "llamacpp", &ChatCompletionRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "test"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if resp.Usage.PromptTokens != 7 || resp.Usage.CompletionTokens != 2 || resp.Usage.TotalTokens != 9 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+
+	models, err := manager.ListModels(context.Background(), "llamacpp")
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if len(models) != 1 || models[0] != "local.gguf" {
+		t.Errorf("Unexpected models: %v", models)
+	}
+}
+
+// 测试本地Provider只有配置了地址才从环境变量加载
+func TestLoadProvidersFromEnv_Keyless(t *testing.T) {
+	for _, kind := range RegisteredKinds() {
+		if kind.EnvPrefix != "" {
+			t.Setenv(kind.EnvPrefix+"_API_KEY", "")
+			t.Setenv(kind.EnvPrefix+"_API_URL", "")
+		}
+	}
+
+	manager := NewAIManager()
+	manager.LoadProvidersFromEnv()
+	if len(manager.GetAllProviders()) != 0 {
+		t.Errorf("Expected no providers, got %v", manager.GetAllProviders())
+	}
+
+	t.Setenv("OLLAMA_API_URL", "http://localhost:11434")
+	t.Setenv("OLLAMA_MODEL", "")
+	manager.LoadProvidersFromEnv()
+	if !manager.HasProvider("ollama") {
+		t.Errorf("Expected ollama provider, got %v", manager.GetAllProviders())
+	}
+}
@@ -11,12 +11,43 @@ import (
 	"time"
 )
 
+// openaiDefaultEmbeddingModel OpenAI默认的向量模型
+const openaiDefaultEmbeddingModel = "text-embedding-3-small"
+
+// openaiVisionModels 支持图片输入的OpenAI模型前缀
+var openaiVisionModels = []string{"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4-vision", "gpt-5", "o1", "o3", "o4"}
+
 // OpenAIProvider OpenAI服务提供商
 type OpenAIProvider struct {
-	apiURL string
-	apiKey string
-	model  string
-	client *http.Client
+	apiURL         string
+	apiKey         string
+	model          string
+	embeddingModel string
+	visionModels   []string
+	client         *http.Client
+}
+
+func init() {
+	RegisterKind(ProviderKind{
+		Kind:           "openai",
+		DisplayName:    "OpenAI",
+		DefaultURL:     "https://api.openai.com/v1",
+		DefaultModel:   "gpt-3.5-turbo",
+		EnvPrefix:      "OPENAI",
+		RequiresAPIKey: true,
+		Factory: func(cfg ProviderConfig) (AIProvider, error) {
+			p := NewOpenAIProvider(cfg.APIURL, cfg.APIKey, cfg.Model)
+			if model := cfg.Options[EmbeddingModelOption]; model != "" {
+				p.embeddingModel = model
+			}
+			models, err := visionModels(cfg.Options, openaiVisionModels)
+			if err != nil {
+				return nil, err
+			}
+			p.visionModels = models
+			return p, nil
+		},
+	})
 }
 
 // NewOpenAIProvider 创建OpenAI Provider实例
@@ -25,9 +56,11 @@ type OpenAIProvider struct {
 // model: 模型名称，如 gpt-3.5-turbo、gpt-4
 func NewOpenAIProvider(apiURL, apiKey, model string) *OpenAIProvider {
 	return &OpenAIProvider{
-		apiURL: apiURL,
-		apiKey: apiKey,
-		model:  model,
+		apiURL:         apiURL,
+		apiKey:         apiKey,
+		model:          model,
+		embeddingModel: openaiDefaultEmbeddingModel,
+		visionModels:   openaiVisionModels,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
@@ -43,6 +76,12 @@ type openaiErrorResponse struct {
 	} `json:"error"`
 }
 
+// openaiStreamRequest OpenAI流式请求格式
+type openaiStreamRequest struct {
+	*ChatCompletionRequest
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
 // ChatCompletion 执行聊天完成请求
 func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
 	// 设置模型
@@ -86,10 +125,10 @@ func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req *ChatCompletion
 		var errResp openaiErrorResponse
 		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
 			log.Printf("[OpenAI] API错误: %s - %s", errResp.Error.Type, errResp.Error.Message)
-			return nil, fmt.Errorf("OpenAI API错误: %s", errResp.Error.Message)
+			return nil, &APIError{Provider: "OpenAI", StatusCode: resp.StatusCode, Message: errResp.Error.Message}
 		}
 		log.Printf("[OpenAI] HTTP错误: %d, 响应: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("OpenAI API错误: HTTP %d", resp.StatusCode)
+		return nil, &APIError{Provider: "OpenAI", StatusCode: resp.StatusCode}
 	}
 
 	// 解析响应
@@ -103,10 +142,39 @@ func (p *OpenAIProvider) ChatCompletion(ctx context.Context, req *ChatCompletion
 	return &result, nil
 }
 
+// ChatCompletionStream 执行流式聊天完成请求
+func (p *OpenAIProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	streamReq := *req
+	streamReq.Model = p.model
+	streamReq.Stream = true
+
+	payload := openaiStreamRequest{
+		ChatCompletionRequest: &streamReq,
+		StreamOptions:         &streamOptions{IncludeUsage: true},
+	}
+
+	return streamOpenAICompatible(ctx, p.client, p.apiURL+"/chat/completions", bearerAuth(p.apiKey), payload, "OpenAI", onDelta)
+}
+
+// Embed 调用 /embeddings 接口将文本转换为向量
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedOpenAICompatible(ctx, p.client, p.apiURL+"/embeddings", bearerAuth(p.apiKey), p.embeddingModel, texts, "OpenAI")
+}
+
+// EmbeddingModel 获取向量模型名称
+func (p *OpenAIProvider) EmbeddingModel() string {
+	return p.embeddingModel
+}
+
 func (p *OpenAIProvider) GetModelName() string {
 	return p.model
 }
 
+// SupportsVision 当前模型是否支持图片输入
+func (p *OpenAIProvider) SupportsVision() bool {
+	return matchModelPrefix(p.model, p.visionModels)
+}
+
 func (p *OpenAIProvider) GetProviderName() string {
 	return "openai"
 }
@@ -0,0 +1,441 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CompatQuirks OpenAI兼容接口的厂商差异
+// Tag: 日志和错误信息中使用的名称，如 "DeepSeek"
+// ProviderName: GetProviderName返回的名称，如 "deepseek"
+// ChatPath: 聊天接口路径，默认 /chat/completions
+// AuthHeader: 鉴权请求头，默认 Authorization
+// AuthScheme: 鉴权前缀，默认 Bearer；为 "-" 时直接发送密钥
+// StreamUsage: 流式请求时是否发送 stream_options.include_usage
+// MaxTemperature: 温度上限，0表示不限制（如Kimi只接受0-1）
+// EmbeddingModel: 向量模型，为空表示不支持向量化
+// VisionModels: 支持图片输入的模型名称前缀（如 qwen-vl）
+// ExtraHeaders: 额外的请求头
+type CompatQuirks struct {
+	Tag            string
+	ProviderName   string
+	ChatPath       string
+	AuthHeader     string
+	AuthScheme     string
+	StreamUsage    bool
+	MaxTemperature float32
+	EmbeddingModel string
+	VisionModels   []string
+	ExtraHeaders   map[string]string
+}
+
+// 内置厂商的差异配置
+var (
+	deepseekQuirks = CompatQuirks{Tag: "DeepSeek", ProviderName: "deepseek", StreamUsage: true}
+	qwenQuirks     = CompatQuirks{Tag: "Qwen", ProviderName: "qwen", StreamUsage: true, EmbeddingModel: "text-embedding-v3", VisionModels: []string{"qwen-vl", "qwen2-vl", "qwen2.5-vl", "qvq"}}
+	kimiQuirks     = CompatQuirks{Tag: "Kimi", ProviderName: "kimi", MaxTemperature: 1}
+	compatQuirks   = CompatQuirks{Tag: "OpenAICompatible", ProviderName: "openai_compatible"}
+)
+
+func init() {
+	RegisterKind(ProviderKind{
+		Kind:           "deepseek",
+		DisplayName:    "DeepSeek",
+		DefaultURL:     "https://api.deepseek.com/v1",
+		DefaultModel:   "deepseek-chat",
+		EnvPrefix:      "DEEPSEEK",
+		RequiresAPIKey: true,
+		Factory:        compatFactory(deepseekQuirks),
+	})
+	RegisterKind(ProviderKind{
+		Kind:           "qwen",
+		DisplayName:    "通义千问",
+		DefaultURL:     "https://dashscope.aliyuncs.com/compatible-mode/v1",
+		DefaultModel:   "qwen-turbo",
+		EnvPrefix:      "QWEN",
+		RequiresAPIKey: true,
+		Factory:        compatFactory(qwenQuirks),
+	})
+	RegisterKind(ProviderKind{
+		Kind:           "kimi",
+		DisplayName:    "Kimi",
+		DefaultURL:     "https://api.moonshot.cn/v1",
+		DefaultModel:   "moonshot-v1-8k",
+		EnvPrefix:      "KIMI",
+		RequiresAPIKey: true,
+		Factory:        compatFactory(kimiQuirks),
+	})
+	// 通用OpenAI兼容类型：新增厂商只需添加一条配置，差异通过options声明
+	// 不强制要求密钥，以便接入vLLM、LM Studio等本地服务
+	RegisterKind(ProviderKind{
+		Kind:        "openai_compatible",
+		DisplayName: "OpenAI兼容接口",
+		Factory:     compatFactory(compatQuirks),
+	})
+}
+
+// compatFactory 创建使用指定厂商差异的构造函数
+// 配置中的options会覆盖内置差异，见 CompatQuirks.WithOptions
+func compatFactory(base CompatQuirks) ProviderFactory {
+	return func(cfg ProviderConfig) (AIProvider, error) {
+		quirks, err := base.WithOptions(cfg.Options)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s 配置错误: %w", cfg.Name, err)
+		}
+		return NewOpenAICompatibleProvider(cfg.APIURL, cfg.APIKey, cfg.Model, quirks), nil
+	}
+}
+
+// WithOptions 使用配置项覆盖厂商差异
+// 支持的配置项: tag、vendor、chat_path、auth_header、auth_scheme、
+// stream_usage(true/false)、max_temperature、embedding_model、vision(true/false)、header.<名称>
+func (q CompatQuirks) WithOptions(options map[string]string) (CompatQuirks, error) {
+	headers := make(map[string]string, len(q.ExtraHeaders))
+	for k, v := range q.ExtraHeaders {
+		headers[k] = v
+	}
+	q.ExtraHeaders = headers
+
+	for key, value := range options {
+		switch {
+		case key == "tag":
+			q.Tag = value
+		case key == "vendor":
+			q.ProviderName = value
+		case key == "chat_path":
+			q.ChatPath = value
+		case key == "auth_header":
+			q.AuthHeader = value
+		case key == "auth_scheme":
+			q.AuthScheme = value
+		case key == "stream_usage":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return q, fmt.Errorf("stream_usage 必须为 true 或 false")
+			}
+			q.StreamUsage = enabled
+		case key == "max_temperature":
+			limit, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return q, fmt.Errorf("max_temperature 必须为数字")
+			}
+			q.MaxTemperature = float32(limit)
+		case key == EmbeddingModelOption:
+			q.EmbeddingModel = value
+		case key == VisionOption:
+			models, err := visionModels(options, nil)
+			if err != nil {
+				return q, err
+			}
+			q.VisionModels = models
+		case strings.HasPrefix(key, "header."):
+			q.ExtraHeaders[strings.TrimPrefix(key, "header.")] = value
+		}
+	}
+	return q, nil
+}
+
+// OpenAICompatibleProvider 通用的OpenAI兼容接口服务提供商
+// DeepSeek、通义千问、Kimi等厂商均使用此实现，差异由CompatQuirks描述
+type OpenAICompatibleProvider struct {
+	apiURL string
+	apiKey string
+	model  string
+	quirks CompatQuirks
+	client *http.Client
+}
+
+// NewOpenAICompatibleProvider 创建OpenAI兼容Provider实例
+// apiURL: API端点，如 https://api.deepseek.com/v1
+// apiKey: API密钥
+// model: 模型名称
+// quirks: 厂商差异
+func NewOpenAICompatibleProvider(apiURL, apiKey, model string, quirks CompatQuirks) *OpenAICompatibleProvider {
+	if quirks.Tag == "" {
+		quirks.Tag = compatQuirks.Tag
+	}
+	if quirks.ProviderName == "" {
+		quirks.ProviderName = compatQuirks.ProviderName
+	}
+	if quirks.ChatPath == "" {
+		quirks.ChatPath = "/chat/completions"
+	}
+	if quirks.AuthHeader == "" {
+		quirks.AuthHeader = "Authorization"
+	}
+	if quirks.AuthScheme == "" {
+		quirks.AuthScheme = "Bearer"
+	}
+	return &OpenAICompatibleProvider{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		apiKey: apiKey,
+		model:  model,
+		quirks: quirks,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// NewDeepSeekProvider 创建DeepSeek Provider实例
+// apiURL: API端点，如 https://api.deepseek.com/v1
+// apiKey: API密钥
+// model: 模型名称，如 deepseek-chat、deepseek-coder
+func NewDeepSeekProvider(apiURL, apiKey, model string) *OpenAICompatibleProvider {
+	return NewOpenAICompatibleProvider(apiURL, apiKey, model, deepseekQuirks)
+}
+
+// NewQwenProvider 创建Qwen Provider实例
+// apiURL: API端点，如 https://dashscope.aliyuncs.com/compatible-mode/v1
+// apiKey: API密钥
+// model: 模型名称，如 qwen-turbo、qwen-plus、qwen-max
+func NewQwenProvider(apiURL, apiKey, model string) *OpenAICompatibleProvider {
+	return NewOpenAICompatibleProvider(apiURL, apiKey, model, qwenQuirks)
+}
+
+// NewKimiProvider 创建Kimi Provider实例
+// apiURL: API端点，如 https://api.moonshot.cn/v1
+// apiKey: API密钥
+// model: 模型名称，如 moonshot-v1-8k、moonshot-v1-32k、moonshot-v1-128k
+func NewKimiProvider(apiURL, apiKey, model string) *OpenAICompatibleProvider {
+	return NewOpenAICompatibleProvider(apiURL, apiKey, model, kimiQuirks)
+}
+
+// compatRequest OpenAI兼容请求格式
+type compatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []ChatMessage  `json:"messages"`
+	Temperature   *float32       `json:"temperature,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	TopP          *float32       `json:"top_p,omitempty"`
+	Stop          []string       `json:"stop,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
+	ToolChoice    string         `json:"tool_choice,omitempty"`
+}
+
+// compatResponse OpenAI兼容响应格式
+type compatResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int         `json:"index"`
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage ChatUsage `json:"usage"`
+	// llama.cpp返回的计数器，usage缺失时使用
+	Timings *llamaTimings `json:"timings"`
+}
+
+// llamaTimings llama.cpp服务端返回的计数器
+// PromptN: 本次处理的提示词Token数
+// PredictedN: 生成的Token数
+type llamaTimings struct {
+	PromptN    int `json:"prompt_n"`
+	PredictedN int `json:"predicted_n"`
+}
+
+// usage 将计数器转换为统一的Token使用情况
+func (t *llamaTimings) usage() ChatUsage {
+	return ChatUsage{
+		PromptTokens:     t.PromptN,
+		CompletionTokens: t.PredictedN,
+		TotalTokens:      t.PromptN + t.PredictedN,
+	}
+}
+
+// buildRequest 构建请求体，按厂商差异调整参数
+func (p *OpenAICompatibleProvider) buildRequest(req *ChatCompletionRequest, stream bool) compatRequest {
+	temperature := req.Temperature
+	if p.quirks.MaxTemperature > 0 {
+		temperature = clampTemperature(temperature, p.quirks.MaxTemperature)
+	}
+
+	compatReq := compatRequest{
+		Model:       p.model,
+		Messages:    req.Messages,
+		Temperature: temperature,
+		MaxTokens:   req.MaxTokens,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		Stream:      stream,
+		Tools:       req.Tools,
+		ToolChoice:  req.ToolChoice,
+	}
+	if stream && p.quirks.StreamUsage {
+		compatReq.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	return compatReq
+}
+
+// setHeaders 设置请求头
+// 未配置API密钥时（如本地llama.cpp）不发送鉴权请求头
+func (p *OpenAICompatibleProvider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("Content-Type", "application/json")
+	switch {
+	case p.apiKey == "":
+	case p.quirks.AuthScheme == "-":
+		httpReq.Header.Set(p.quirks.AuthHeader, p.apiKey)
+	default:
+		httpReq.Header.Set(p.quirks.AuthHeader, p.quirks.AuthScheme+" "+p.apiKey)
+	}
+	for k, v := range p.quirks.ExtraHeaders {
+		httpReq.Header.Set(k, v)
+	}
+}
+
+// ChatCompletion 执行聊天完成请求
+func (p *OpenAICompatibleProvider) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	tag := p.quirks.Tag
+
+	// 序列化请求
+	jsonReq, err := json.Marshal(p.buildRequest(req, false))
+	if err != nil {
+		log.Printf("[%s] 序列化请求失败: %v", tag, err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	// 创建HTTP请求
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.apiURL+p.quirks.ChatPath, bytes.NewBuffer(jsonReq))
+	if err != nil {
+		log.Printf("[%s] 创建请求失败: %v", tag, err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	p.setHeaders(httpReq)
+
+	// 发送请求
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		log.Printf("[%s] 请求失败: %v", tag, err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应体
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("[%s] 读取响应失败: %v", tag, err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		var errResp openaiErrorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+			log.Printf("[%s] API错误: %s - %s", tag, errResp.Error.Type, errResp.Error.Message)
+			return nil, &APIError{Provider: tag, StatusCode: resp.StatusCode, Message: errResp.Error.Message}
+		}
+		log.Printf("[%s] HTTP错误: %d, 响应: %s", tag, resp.StatusCode, string(body))
+		return nil, &APIError{Provider: tag, StatusCode: resp.StatusCode}
+	}
+
+	// 解析响应
+	var compatResp compatResponse
+	if err := json.Unmarshal(body, &compatResp); err != nil {
+		log.Printf("[%s] 解析响应失败: %v, 响应: %s", tag, err, string(body))
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	// 转换为统一格式
+	result := &ChatCompletionResponse{
+		ID:     compatResp.ID,
+		Object: compatResp.Object,
+		Model:  compatResp.Model,
+		Usage:  compatResp.Usage,
+	}
+	if result.Usage.TotalTokens == 0 && compatResp.Timings != nil {
+		result.Usage = compatResp.Timings.usage()
+	}
+
+	// 转换choices
+	for _, choice := range compatResp.Choices {
+		result.Choices = append(result.Choices, ChatChoice{
+			Index:        choice.Index,
+			Message:      choice.Message,
+			FinishReason: choice.FinishReason,
+		})
+	}
+
+	log.Printf("[%s] 请求成功, 模型: %s, Token使用: %d", tag, result.Model, result.Usage.TotalTokens)
+	return result, nil
+}
+
+// ChatCompletionStream 执行流式聊天完成请求
+func (p *OpenAICompatibleProvider) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	return streamOpenAICompatible(ctx, p.client, p.apiURL+p.quirks.ChatPath, p.setHeaders, p.buildRequest(req, true), p.quirks.Tag, onDelta)
+}
+
+// ListModels 通过 /models 接口获取可用模型列表
+func (p *OpenAICompatibleProvider) ListModels(ctx context.Context) ([]string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.apiURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		log.Printf("[%s] 获取模型列表失败: %v", p.quirks.Tag, err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Provider: p.quirks.Tag, StatusCode: resp.StatusCode}
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	names := make([]string, 0, len(list.Data))
+	for _, m := range list.Data {
+		names = append(names, m.ID)
+	}
+	return names, nil
+}
+
+// Embed 调用 /embeddings 接口将文本转换为向量
+func (p *OpenAICompatibleProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if p.quirks.EmbeddingModel == "" {
+		return nil, ErrEmbeddingsUnsupported
+	}
+	return embedOpenAICompatible(ctx, p.client, p.apiURL+"/embeddings", p.setHeaders, p.quirks.EmbeddingModel, texts, p.quirks.Tag)
+}
+
+// EmbeddingModel 获取向量模型名称，未配置时为空
+func (p *OpenAICompatibleProvider) EmbeddingModel() string {
+	return p.quirks.EmbeddingModel
+}
+
+// SupportsVision 当前模型是否支持图片输入
+func (p *OpenAICompatibleProvider) SupportsVision() bool {
+	return matchModelPrefix(p.model, p.quirks.VisionModels)
+}
+
+// GetModelName 获取模型名称
+func (p *OpenAICompatibleProvider) GetModelName() string {
+	return p.model
+}
+
+// GetProviderName 获取提供商名称
+func (p *OpenAICompatibleProvider) GetProviderName() string {
+	return p.quirks.ProviderName
+}
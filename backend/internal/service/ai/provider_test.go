@@ -67,7 +67,7 @@ func TestGLMProvider_RequestFormat(t *testing.T) {
 		Messages: []ChatMessage{
 			{Role: "user", Content: "Hello"},
 		},
-		Temperature: 0.7,
+		Temperature: float32Ptr(0.7),
 		MaxTokens:   100,
 	}
 
@@ -278,6 +278,83 @@ func TestProvider_RequestHeaders(t *testing.T) {
 	}
 }
 
+// 测试流式响应：增量回调顺序、汇总内容与Token使用情况
+func TestProvider_ChatCompletionStream(t *testing.T) {
+	providers := []struct {
+		name           string
+		createProvider func(url, key, model string) AIProvider
+		usageInChoice  bool
+	}{
+		{
+			name: "DeepSeek",
+			createProvider: func(url, key, model string) AIProvider {
+				return NewDeepSeekProvider(url, key, model)
+			},
+		},
+		{
+			name: "Kimi",
+			createProvider: func(url, key, model string) AIProvider {
+				return NewKimiProvider(url, key, model)
+			},
+			usageInChoice: true,
+		},
+	}
+
+	for _, p := range providers {
+		t.Run(p.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+				if req["stream"] != true {
+					t.Error("Stream request should set stream to true")
+				}
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: {\"id\":\"s-1\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"你好\"}}]}\n\n"))
+				w.Write([]byte(": keep-alive\n\n"))
+				w.Write([]byte("data: {\"id\":\"s-1\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"$世界\"}}]}\n\n"))
+				if p.usageInChoice {
+					w.Write([]byte("data: {\"id\":\"s-1\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\",\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":4,\"total_tokens\":12}}]}\n\n"))
+				} else {
+					w.Write([]byte("data: {\"id\":\"s-1\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"))
+					w.Write([]byte("data: {\"id\":\"s-1\",\"model\":\"m\",\"choices\":[],\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":4,\"total_tokens\":12}}\n\n"))
+				}
+				w.Write([]byte("data: [DONE]\n\n"))
+			}))
+			defer server.Close()
+
+			provider := p.createProvider(server.URL, "test-key", "test-model")
+			req := &ChatCompletionRequest{
+				Messages: []ChatMessage{{Role: "user", Content: "test"}},
+			}
+
+			var deltas []string
+			resp, err := provider.ChatCompletionStream(context.Background(), req, func(delta string) error {
+				deltas = append(deltas, delta)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ChatCompletionStream failed: %v", err)
+			}
+
+			if len(deltas) != 2 || deltas[0] != "你好" || deltas[1] != "$世界" {
+				t.Errorf("Unexpected deltas: %v", deltas)
+			}
+			if resp.Choices[0].Message.Content != "你好$世界" {
+				t.Errorf("Expected content '你好$世界', got '%s'", resp.Choices[0].Message.Content)
+			}
+			if resp.Choices[0].FinishReason != "stop" {
+				t.Errorf("Expected finish reason 'stop', got '%s'", resp.Choices[0].FinishReason)
+			}
+			if resp.Usage.TotalTokens != 12 {
+				t.Errorf("Expected total tokens 12, got %d", resp.Usage.TotalTokens)
+			}
+		})
+	}
+}
+
 // Feature: ai-chat-enhancement, Property 16: Provider配置加载
 // Validates: Requirements 7.1
 func TestAIManager_RegisterProvider(t *testing.T) {
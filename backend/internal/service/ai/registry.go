@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ProviderConfig Provider实例配置
+// ID: 数据库中的配置ID（从环境变量加载时为0）
+// Name: 实例名称，全局唯一，如 "deepseek"、"deepseek-backup"
+// Kind: Provider类型，对应注册表中的ProviderKind，如 "deepseek"、"openai_compatible"
+// APIURL/APIKey/Model: 为空时使用类型默认值（APIKey除外）
+// MaxTokens/Temperature: 实例级默认参数，Temperature为nil表示未设置
+// Options: 厂商差异配置，如 chat_path、auth_header，具体含义由各类型解释
+type ProviderConfig struct {
+	ID          uint
+	Name        string
+	Kind        string
+	APIURL      string
+	APIKey      string
+	Model       string
+	MaxTokens   int
+	Temperature *float32
+	Options     map[string]string
+}
+
+// ProviderFactory 根据配置创建Provider实例
+type ProviderFactory func(cfg ProviderConfig) (AIProvider, error)
+
+// ProviderKind Provider类型定义
+// Kind: 类型名称，如 "glm"、"deepseek"
+// DisplayName: 展示名称
+// DefaultURL/DefaultModel: 配置未指定时使用的默认值
+// EnvPrefix: 环境变量前缀，如 "DEEPSEEK" 对应 DEEPSEEK_API_KEY、DEEPSEEK_API_URL、DEEPSEEK_MODEL
+// RequiresAPIKey: 是否必须配置API密钥
+// Factory: 构造函数
+type ProviderKind struct {
+	Kind           string
+	DisplayName    string
+	DefaultURL     string
+	DefaultModel   string
+	EnvPrefix      string
+	RequiresAPIKey bool
+	Factory        ProviderFactory
+}
+
+var (
+	kindsMu   sync.RWMutex
+	kinds     = make(map[string]ProviderKind)
+	kindOrder []string
+)
+
+// RegisterKind 注册Provider类型
+// 各Provider在init中调用，重复注册会覆盖之前的定义
+func RegisterKind(kind ProviderKind) {
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+
+	if _, exists := kinds[kind.Kind]; !exists {
+		kindOrder = append(kindOrder, kind.Kind)
+	}
+	kinds[kind.Kind] = kind
+}
+
+// LookupKind 查找Provider类型
+func LookupKind(kind string) (ProviderKind, bool) {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+
+	k, exists := kinds[kind]
+	return k, exists
+}
+
+// RegisteredKinds 按注册顺序返回所有Provider类型
+func RegisteredKinds() []ProviderKind {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+
+	list := make([]ProviderKind, 0, len(kindOrder))
+	for _, name := range kindOrder {
+		list = append(list, kinds[name])
+	}
+	return list
+}
+
+// NewProvider 根据配置创建Provider实例
+// Kind为空时使用Name作为类型（兼容没有kind字段的旧配置）
+// 未配置的API地址和模型使用类型默认值
+func NewProvider(cfg ProviderConfig) (AIProvider, error) {
+	cfg = cfg.withDefaults()
+
+	kind, exists := LookupKind(cfg.Kind)
+	if !exists {
+		return nil, fmt.Errorf("未知的Provider类型: %s", cfg.Kind)
+	}
+	if kind.RequiresAPIKey && cfg.APIKey == "" {
+		return nil, fmt.Errorf("provider %s 没有配置API密钥", cfg.Name)
+	}
+	if cfg.APIURL == "" {
+		return nil, fmt.Errorf("provider %s 没有配置API端点", cfg.Name)
+	}
+
+	return kind.Factory(cfg)
+}
+
+// withDefaults 填充类型默认值
+func (cfg ProviderConfig) withDefaults() ProviderConfig {
+	if cfg.Kind == "" {
+		cfg.Kind = cfg.Name
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Kind
+	}
+	if kind, exists := LookupKind(cfg.Kind); exists {
+		if cfg.APIURL == "" {
+			cfg.APIURL = kind.DefaultURL
+		}
+		if cfg.Model == "" {
+			cfg.Model = kind.DefaultModel
+		}
+	}
+	if cfg.Options == nil {
+		cfg.Options = map[string]string{}
+	}
+	return cfg
+}
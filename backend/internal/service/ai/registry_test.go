@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 测试通用OpenAI兼容类型：通过options声明厂商差异
+func TestNewProvider_OpenAICompatibleOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/v1/chat" {
+			t.Errorf("Expected path '/openai/v1/chat', got '%s'", r.URL.Path)
+		}
+		if r.Header.Get("api-key") != "test-key" {
+			t.Errorf("Expected api-key header 'test-key', got '%s'", r.Header.Get("api-key"))
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Error("Authorization header should not be set")
+		}
+		if r.Header.Get("X-Region") != "cn" {
+			t.Errorf("Expected X-Region header 'cn', got '%s'", r.Header.Get("X-Region"))
+		}
+
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["temperature"].(float64) != 1 {
+			t.Errorf("Expected temperature clamped to 1, got %v", req["temperature"])
+		}
+
+		resp := map[string]interface{}{
+			"id":    "test",
+			"model": "vendor-model",
+			"choices": []map[string]interface{}{
+				{"index": 0, "message": map[string]string{"role": "assistant", "content": "ok"}, "finish_reason": "stop"},
+			},
+			"usage": map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{
+		Name:   "my-vendor",
+		Kind:   "openai_compatible",
+		APIURL: server.URL + "/openai/v1/",
+		APIKey: "test-key",
+		Model:  "vendor-model",
+		Options: map[string]string{
+			"vendor":          "my-vendor",
+			"chat_path":       "/chat",
+			"auth_header":     "api-key",
+			"auth_scheme":     "-",
+			"max_temperature": "1",
+			"header.X-Region": "cn",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if provider.GetProviderName() != "my-vendor" {
+		t.Errorf("Expected provider name 'my-vendor', got '%s'", provider.GetProviderName())
+	}
+
+	req := &ChatCompletionRequest{
+		Messages:    []ChatMessage{{Role: "user", Content: "test"}},
+		Temperature: float32Ptr(1.5),
+	}
+	if _, err := provider.ChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+}
+
+// 测试配置校验：未知类型、缺少密钥、错误的options
+func TestNewProvider_Validation(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  ProviderConfig
+	}{
+		{name: "unknown kind", cfg: ProviderConfig{Name: "x", Kind: "nonexistent", APIKey: "key"}},
+		{name: "missing api key", cfg: ProviderConfig{Name: "deepseek"}},
+		{name: "missing endpoint", cfg: ProviderConfig{Name: "vendor", Kind: "openai_compatible", APIKey: "key"}},
+		{name: "bad option", cfg: ProviderConfig{Name: "kimi", APIKey: "key", Options: map[string]string{"stream_usage": "maybe"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewProvider(tc.cfg); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+// 测试同一类型的多个实例并存，且Kind为空时兼容旧配置
+func TestAIManager_RegisterProviderConfig(t *testing.T) {
+	manager := NewAIManager()
+
+	if err := manager.RegisterProviderConfig(ProviderConfig{Name: "deepseek", APIKey: "key"}); err != nil {
+		t.Fatalf("RegisterProviderConfig failed: %v", err)
+	}
+	if err := manager.RegisterProviderConfig(ProviderConfig{Name: "deepseek-reasoner", Kind: "deepseek", APIKey: "key", Model: "deepseek-reasoner", MaxTokens: 8000}); err != nil {
+		t.Fatalf("RegisterProviderConfig failed: %v", err)
+	}
+
+	if len(manager.GetAllProviders()) != 2 {
+		t.Errorf("Expected 2 providers, got %d", len(manager.GetAllProviders()))
+	}
+
+	cfg, ok := manager.GetProviderConfig("deepseek")
+	if !ok {
+		t.Fatal("Expected config for deepseek")
+	}
+	if cfg.Kind != "deepseek" || cfg.APIURL != "https://api.deepseek.com/v1" || cfg.Model != "deepseek-chat" {
+		t.Errorf("Defaults not applied: %+v", cfg)
+	}
+
+	provider, _ := manager.GetProvider("deepseek-reasoner")
+	if provider.GetProviderName() != "deepseek" || provider.GetModelName() != "deepseek-reasoner" {
+		t.Errorf("Unexpected provider: %s/%s", provider.GetProviderName(), provider.GetModelName())
+	}
+	if cfg, _ := manager.GetProviderConfig("deepseek-reasoner"); cfg.MaxTokens != 8000 {
+		t.Errorf("Expected MaxTokens 8000, got %d", cfg.MaxTokens)
+	}
+}
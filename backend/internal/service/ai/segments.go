@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SegmentDelimiter 角色回复中分隔聊天气泡的符号
+const SegmentDelimiter = '$'
+
+// 打字延迟参数，模拟真人逐条发送消息的节奏
+const (
+	segmentBaseDelayMs    = 300
+	segmentDelayPerRuneMs = 60
+	segmentMaxDelayMs     = 2500
+)
+
+// Segment 回复中的一条聊天气泡
+// DelayMs: 建议客户端在展示这条气泡前等待的毫秒数（模拟打字时间）
+type Segment struct {
+	Content string `json:"content"`
+	DelayMs int    `json:"delay_ms"`
+}
+
+// NewSegment 创建气泡，并根据长度计算打字延迟
+func NewSegment(content string) Segment {
+	delay := segmentBaseDelayMs + segmentDelayPerRuneMs*utf8.RuneCountInString(content)
+	if delay > segmentMaxDelayMs {
+		delay = segmentMaxDelayMs
+	}
+	return Segment{Content: content, DelayMs: delay}
+}
+
+// Segmenter 按分隔符切分回复，支持流式输入
+// 代码（反引号之间）中的分隔符不切分，如shell变量 $HOME
+type Segmenter struct {
+	buf    strings.Builder
+	inCode bool
+}
+
+// Feed 输入一段内容，返回已完整的气泡文本
+func (s *Segmenter) Feed(text string) []string {
+	var done []string
+	for _, ch := range text {
+		switch {
+		case ch == '`':
+			s.inCode = !s.inCode
+			s.buf.WriteRune(ch)
+		case ch == SegmentDelimiter && !s.inCode:
+			if segment := strings.TrimSpace(s.buf.String()); segment != "" {
+				done = append(done, segment)
+			}
+			s.buf.Reset()
+		default:
+			s.buf.WriteRune(ch)
+		}
+	}
+	return done
+}
+
+// Flush 返回剩余的最后一个气泡，没有时返回空字符串
+func (s *Segmenter) Flush() string {
+	segment := strings.TrimSpace(s.buf.String())
+	s.buf.Reset()
+	s.inCode = false
+	return segment
+}
+
+// SplitSegmentTexts 将完整回复切分为气泡文本
+func SplitSegmentTexts(reply string) []string {
+	var s Segmenter
+	texts := s.Feed(reply)
+	if last := s.Flush(); last != "" {
+		texts = append(texts, last)
+	}
+	return texts
+}
+
+// SplitSegments 将完整回复切分为带打字延迟的气泡
+func SplitSegments(reply string) []Segment {
+	texts := SplitSegmentTexts(reply)
+	segments := make([]Segment, len(texts))
+	for i, text := range texts {
+		segments[i] = NewSegment(text)
+	}
+	return segments
+}
@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 测试气泡切分：去除空白和空气泡，代码中的分隔符不切分
+func TestSplitSegmentTexts(t *testing.T) {
+	testCases := []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		{name: "single", reply: "你好", want: []string{"你好"}},
+		{name: "multiple", reply: "嗯$有什么事吗", want: []string{"嗯", "有什么事吗"}},
+		{name: "trim and skip empty", reply: " 锵锵~ $$ 织织上线啦！$ ", want: []string{"锵锵~", "织织上线啦！"}},
+		{name: "inline code", reply: "用 `echo $HOME` 查看$就这样", want: []string{"用 `echo $HOME` 查看", "就这样"}},
+		{name: "code block", reply: "示例：\n```\nexport A=$B\n```$懂了吗", want: []string{"示例：\n```\nexport A=$B\n```", "懂了吗"}},
+		{name: "empty", reply: "", want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SplitSegmentTexts(tc.reply); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("SplitSegmentTexts(%q) = %q, want %q", tc.reply, got, tc.want)
+			}
+		})
+	}
+}
+
+// 测试流式切分：分隔符跨增量到达时结果与整体切分一致
+func TestSegmenter_Stream(t *testing.T) {
+	var s Segmenter
+	var got []string
+	for _, delta := range []string{"嗯", "$有什么", "事吗$", "`a$", "b`"} {
+		got = append(got, s.Feed(delta)...)
+	}
+	if last := s.Flush(); last != "" {
+		got = append(got, last)
+	}
+
+	want := []string{"嗯", "有什么事吗", "`a$b`"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// 测试打字延迟随长度增加且有上限
+func TestNewSegment_Delay(t *testing.T) {
+	short := NewSegment("嗯")
+	long := NewSegment("这是一条比较长的消息，需要更长的打字时间")
+	if short.DelayMs >= long.DelayMs {
+		t.Errorf("Expected longer segment to have longer delay, got %d and %d", short.DelayMs, long.DelayMs)
+	}
+	if huge := NewSegment(string(make([]rune, 1000))); huge.DelayMs != segmentMaxDelayMs {
+		t.Errorf("Expected delay capped at %d, got %d", segmentMaxDelayMs, huge.DelayMs)
+	}
+}
@@ -0,0 +1,96 @@
+package ai
+
+import "fmt"
+
+// 生成参数的取值范围
+const (
+	maxTemperature   = 2
+	maxStopSequences = 4
+)
+
+// GenerationSettings 生成参数
+// 指针为nil、MaxTokens为0、Stop为空表示未设置，由优先级更低的配置决定
+type GenerationSettings struct {
+	Temperature *float32
+	TopP        *float32
+	MaxTokens   int
+	Stop        []string
+}
+
+// ResolveSettings 按优先级合并生成参数，排在前面的优先
+// 例如 ResolveSettings(请求参数, 角色参数, Provider默认参数)
+func ResolveSettings(layers ...GenerationSettings) GenerationSettings {
+	var resolved GenerationSettings
+	for _, layer := range layers {
+		if resolved.Temperature == nil && layer.Temperature != nil {
+			resolved.Temperature = layer.Temperature
+		}
+		if resolved.TopP == nil && layer.TopP != nil {
+			resolved.TopP = layer.TopP
+		}
+		if resolved.MaxTokens <= 0 && layer.MaxTokens > 0 {
+			resolved.MaxTokens = layer.MaxTokens
+		}
+		if len(resolved.Stop) == 0 && len(layer.Stop) > 0 {
+			resolved.Stop = layer.Stop
+		}
+	}
+	return resolved
+}
+
+// Validate 校验参数取值范围
+func (s GenerationSettings) Validate() error {
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > maxTemperature) {
+		return fmt.Errorf("temperature必须在0-%d之间", maxTemperature)
+	}
+	if s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1) {
+		return fmt.Errorf("top_p必须在0-1之间")
+	}
+	if s.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens不能为负数")
+	}
+	if len(s.Stop) > maxStopSequences {
+		return fmt.Errorf("停止序列不能超过%d个", maxStopSequences)
+	}
+	for _, stop := range s.Stop {
+		if stop == "" {
+			return fmt.Errorf("停止序列不能为空字符串")
+		}
+	}
+	return nil
+}
+
+// Apply 将参数写入请求，未设置的参数保持请求原值
+func (s GenerationSettings) Apply(req *ChatCompletionRequest) {
+	if s.Temperature != nil {
+		req.Temperature = s.Temperature
+	}
+	if s.TopP != nil {
+		req.TopP = s.TopP
+	}
+	if s.MaxTokens > 0 {
+		req.MaxTokens = s.MaxTokens
+	}
+	if len(s.Stop) > 0 {
+		req.Stop = s.Stop
+	}
+}
+
+// Settings 获取Provider配置中的默认生成参数
+// MaxTokens是上下文预算而不是回复长度，不作为生成参数
+func (cfg ProviderConfig) Settings() GenerationSettings {
+	return GenerationSettings{Temperature: cfg.Temperature}
+}
+
+// float32Ptr 返回float32指针，用于可选的生成参数
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+// clampTemperature 将温度限制在厂商允许的上限内，未设置时保持nil
+func clampTemperature(temperature *float32, limit float32) *float32 {
+	if temperature == nil || *temperature <= limit {
+		return temperature
+	}
+	return float32Ptr(limit)
+}
@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试生成参数的优先级：请求 > 角色 > Provider默认值
+func TestResolveSettings(t *testing.T) {
+	request := GenerationSettings{MaxTokens: 100}
+	character := GenerationSettings{Temperature: float32Ptr(0.2), MaxTokens: 300, Stop: []string{"\n\n"}}
+	provider := ProviderConfig{Temperature: float32Ptr(0.9)}.Settings()
+	defaults := GenerationSettings{Temperature: float32Ptr(0.7), TopP: float32Ptr(0.95), MaxTokens: 2000}
+
+	resolved := ResolveSettings(request, character, provider, defaults)
+	if *resolved.Temperature != 0.2 {
+		t.Errorf("Expected character temperature 0.2, got %v", *resolved.Temperature)
+	}
+	if *resolved.TopP != 0.95 {
+		t.Errorf("Expected default top_p 0.95, got %v", *resolved.TopP)
+	}
+	if resolved.MaxTokens != 100 {
+		t.Errorf("Expected request max_tokens 100, got %d", resolved.MaxTokens)
+	}
+	if !reflect.DeepEqual(resolved.Stop, []string{"\n\n"}) {
+		t.Errorf("Expected character stop sequences, got %q", resolved.Stop)
+	}
+
+	// 请求显式设置的0温度优先于其他配置
+	resolved = ResolveSettings(GenerationSettings{Temperature: float32Ptr(0)}, provider)
+	if *resolved.Temperature != 0 {
+		t.Errorf("Expected explicit zero temperature, got %v", *resolved.Temperature)
+	}
+
+	if got := ResolveSettings(character, provider, defaults).Temperature; *got != 0.2 {
+		t.Errorf("Expected character over provider, got %v", *got)
+	}
+	if got := ResolveSettings(GenerationSettings{}, provider, defaults).Temperature; *got != 0.9 {
+		t.Errorf("Expected provider over defaults, got %v", *got)
+	}
+}
+
+// 测试生成参数校验
+func TestGenerationSettings_Validate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		settings GenerationSettings
+		wantErr  bool
+	}{
+		{name: "empty", settings: GenerationSettings{}},
+		{name: "valid", settings: GenerationSettings{Temperature: float32Ptr(1.2), TopP: float32Ptr(0.8), MaxTokens: 500, Stop: []string{"END"}}},
+		{name: "temperature too high", settings: GenerationSettings{Temperature: float32Ptr(2.5)}, wantErr: true},
+		{name: "top_p zero", settings: GenerationSettings{TopP: float32Ptr(0)}, wantErr: true},
+		{name: "negative max_tokens", settings: GenerationSettings{MaxTokens: -1}, wantErr: true},
+		{name: "too many stops", settings: GenerationSettings{Stop: []string{"a", "b", "c", "d", "e"}}, wantErr: true},
+		{name: "empty stop", settings: GenerationSettings{Stop: []string{""}}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.settings.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// 测试请求级降级Provider优先于全局降级链
+func TestAIManager_RequestFallback(t *testing.T) {
+	var primaryHits, characterHits, chainHits int32
+	primary := newMockServer(t, http.StatusServiceUnavailable, "", &primaryHits)
+	defer primary.Close()
+	character := newMockServer(t, http.StatusOK, "character", &characterHits)
+	defer character.Close()
+	chain := newMockServer(t, http.StatusOK, "chain", &chainHits)
+	defer chain.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(primary.URL, "key", "deepseek-chat"))
+	manager.RegisterProvider("kimi", NewKimiProvider(character.URL, "key", "moonshot-v1-8k"))
+	manager.RegisterProvider("qwen", NewQwenProvider(chain.URL, "key", "qwen-turbo"))
+	manager.SetFallbackChain([]string{"qwen"})
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}, Fallback: []string{"kimi"}}
+	resp, err := manager.ChatCompletion(context.Background(), "deepseek", req)
+	if err != nil {
+		t.Fatalf("ChatCompletion failed: %v", err)
+	}
+	if resp.Provider != "kimi" || chainHits != 0 {
+		t.Errorf("Expected request fallback kimi, got %s (chain hits %d)", resp.Provider, chainHits)
+	}
+}
+
+// 测试解析出的0温度在各厂商的请求体中原样发送，而不是被当作未设置省略
+func TestGenerationSettings_ZeroTemperatureSerialized(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	providers := map[string]AIProvider{
+		"compat":    NewDeepSeekProvider(server.URL, "key", "deepseek-chat"),
+		"glm":       NewGLMProvider(server.URL, "key", "glm-4"),
+		"anthropic": NewAnthropicProvider(server.URL, "key", "claude-3-5-haiku-latest"),
+		"ollama":    NewOllamaProvider(server.URL, "", "qwen2.5"),
+	}
+
+	settings := ResolveSettings(GenerationSettings{}, ProviderConfig{Temperature: float32Ptr(0)}.Settings())
+	for name, provider := range providers {
+		req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+		settings.Apply(req)
+
+		body = nil
+		provider.ChatCompletion(context.Background(), req)
+		if !strings.Contains(string(body), `"temperature":0`) {
+			t.Errorf("%s: expected explicit zero temperature in request body, got %s", name, body)
+		}
+		if strings.Contains(string(body), `"top_p"`) {
+			t.Errorf("%s: expected unset top_p to be omitted, got %s", name, body)
+		}
+	}
+}
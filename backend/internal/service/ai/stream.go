@@ -0,0 +1,251 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamTimeout 流式请求的整体超时时间
+// 流式回复可能持续较长时间，因此比普通请求的超时时间更长
+const streamTimeout = 5 * time.Minute
+
+// streamOptions OpenAI兼容格式的流式选项
+// IncludeUsage: 要求在最后一个数据块中返回Token使用情况
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openaiStreamChunk OpenAI兼容格式的流式数据块
+type openaiStreamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role      string          `json:"role"`
+			Content   string          `json:"content"`
+			ToolCalls []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+		// Kimi在choice中返回usage
+		Usage *ChatUsage `json:"usage"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage"`
+	// llama.cpp在最后一个数据块中返回计数器
+	Timings *llamaTimings `json:"timings"`
+}
+
+// toolCallDelta 流式响应中的工具调用增量
+// 同一个调用通过Index关联：第一个数据块包含ID和函数名，之后的数据块逐段追加参数
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolCallAccumulator 按Index拼接流式工具调用
+type toolCallAccumulator struct {
+	calls []ToolCall
+	index map[int]int
+}
+
+// add 追加一段工具调用增量
+func (a *toolCallAccumulator) add(delta toolCallDelta) {
+	if a.index == nil {
+		a.index = make(map[int]int)
+	}
+	pos, exists := a.index[delta.Index]
+	if !exists {
+		pos = len(a.calls)
+		a.index[delta.Index] = pos
+		a.calls = append(a.calls, ToolCall{Type: "function"})
+	}
+
+	call := &a.calls[pos]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+// streamOpenAICompatible 发送OpenAI兼容格式的流式请求并解析SSE响应
+// client: Provider使用的HTTP客户端，流式请求会在其基础上放宽超时时间
+// endpoint: 完整的请求地址，如 https://api.deepseek.com/v1/chat/completions
+// setHeaders: 设置Content-Type和鉴权等请求头
+// payload: 请求体，调用方需要设置stream为true
+// tag: 日志和错误信息中使用的Provider名称，如 "DeepSeek"
+// onDelta: 每收到一段增量内容时调用
+func streamOpenAICompatible(ctx context.Context, client *http.Client, endpoint string, setHeaders func(*http.Request), payload interface{}, tag string, onDelta StreamHandler) (*ChatCompletionResponse, error) {
+	// 序列化请求
+	jsonReq, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[%s] 序列化流式请求失败: %v", tag, err)
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	// 创建HTTP请求
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonReq))
+	if err != nil {
+		log.Printf("[%s] 创建流式请求失败: %v", tag, err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	// 设置请求头
+	setHeaders(httpReq)
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	// 发送请求
+	streamClient := *client
+	streamClient.Timeout = streamTimeout
+	resp, err := streamClient.Do(httpReq)
+	if err != nil {
+		log.Printf("[%s] 流式请求失败: %v", tag, err)
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var errResp openaiErrorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+			log.Printf("[%s] API错误: %s - %s", tag, errResp.Error.Type, errResp.Error.Message)
+			return nil, &APIError{Provider: tag, StatusCode: resp.StatusCode, Message: errResp.Error.Message}
+		}
+		log.Printf("[%s] HTTP错误: %d, 响应: %s", tag, resp.StatusCode, string(body))
+		return nil, &APIError{Provider: tag, StatusCode: resp.StatusCode}
+	}
+
+	result := &ChatCompletionResponse{Object: "chat.completion"}
+	var content strings.Builder
+	var toolCalls toolCallAccumulator
+	finishReason := ""
+
+	err = readSSE(resp.Body, func(event, data string) (bool, error) {
+		if data == "[DONE]" {
+			return false, nil
+		}
+
+		var chunk openaiStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("[%s] 解析流式数据失败: %v, 数据: %s", tag, err, data)
+			return false, fmt.Errorf("解析响应失败: %w", err)
+		}
+
+		if chunk.ID != "" {
+			result.ID = chunk.ID
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		} else if chunk.Timings != nil && result.Usage.TotalTokens == 0 {
+			result.Usage = chunk.Timings.usage()
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Usage != nil {
+				result.Usage = *choice.Usage
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			for _, delta := range choice.Delta.ToolCalls {
+				toolCalls.add(delta)
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		log.Printf("[%s] 读取流式响应失败: %v", tag, err)
+		return nil, err
+	}
+
+	result.Choices = []ChatChoice{
+		{
+			Index:        0,
+			Message:      ChatMessage{Role: "assistant", Content: content.String(), ToolCalls: toolCalls.calls},
+			FinishReason: finishReason,
+		},
+	}
+
+	log.Printf("[%s] 流式请求成功, 模型: %s, Token使用: %d", tag, result.Model, result.Usage.TotalTokens)
+	return result, nil
+}
+
+// bearerAuth 返回设置JSON内容类型和Bearer鉴权的请求头函数
+func bearerAuth(apiKey string) func(*http.Request) {
+	return func(httpReq *http.Request) {
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// readSSE 逐条读取Server-Sent Events
+// onEvent: 每条事件调用一次，event为事件名（可能为空），data为数据内容
+// onEvent返回false时停止读取
+func readSSE(r io.Reader, onEvent func(event, data string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	event := ""
+	var data []string
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			event = ""
+			return true, nil
+		}
+		more, err := onEvent(event, strings.Join(data, "\n"))
+		event = ""
+		data = data[:0]
+		return more, err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			more, err := dispatch()
+			if err != nil || !more {
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// 注释行（心跳），忽略
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 处理没有以空行结尾的最后一条事件
+	_, err := dispatch()
+	return err
+}
@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 摘要相关默认值
+const (
+	// summaryMaxTokens 生成摘要的最大Token数
+	summaryMaxTokens = 600
+	// summaryTemperature 摘要使用较低温度，保证事实准确
+	summaryTemperature = 0.3
+)
+
+// summaryInstruction 生成摘要的系统提示
+const summaryInstruction = `你是对话记录员，负责把角色扮演对话压缩为长期记忆摘要。
+要求：
+1. 合并【已有摘要】和【新增对话】，输出一份完整的新摘要，不要只总结新增部分
+2. 保留访客透露的个人信息（称呼、喜好、经历等）、双方的约定、剧情进展和未解决的问题
+3. 省略寒暄和重复内容，使用第三人称陈述，不超过400字
+4. 只输出摘要正文，不要任何前言或解释`
+
+// Summarizer 对话摘要生成器
+// 使用廉价的Provider把超出上下文预算的旧对话压缩为滚动摘要
+type Summarizer struct {
+	manager  *AIManager
+	provider string
+}
+
+// NewSummarizer 创建摘要生成器
+// provider: 用于生成摘要的Provider名称，为空时使用默认Provider
+func NewSummarizer(manager *AIManager, provider string) *Summarizer {
+	return &Summarizer{manager: manager, provider: provider}
+}
+
+// Summarize 将已有摘要和新增对话合并为新的摘要
+// previous: 已有摘要，可以为空
+// messages: 需要压缩的对话，按时间正序排列
+func (s *Summarizer) Summarize(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	if len(messages) == 0 {
+		return previous, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("【已有摘要】\n")
+	if previous == "" {
+		b.WriteString("（无）")
+	} else {
+		b.WriteString(previous)
+	}
+	b.WriteString("\n\n【新增对话】\n")
+	for _, msg := range messages {
+		// 工具调用和工具结果不进入摘要，其中的信息已体现在之后的回复中
+		if msg.Role == "tool" || msg.Content == "" {
+			continue
+		}
+		b.WriteString(summaryRoleLabel(msg.Role))
+		b.WriteString("：")
+		b.WriteString(msg.Content)
+		b.WriteString("\n")
+	}
+
+	req := &ChatCompletionRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: summaryInstruction},
+			{Role: "user", Content: b.String()},
+		},
+		Temperature: float32Ptr(summaryTemperature),
+		MaxTokens:   summaryMaxTokens,
+	}
+
+	resp, err := s.manager.ChatCompletion(ctx, s.provider, req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("摘要响应为空")
+	}
+
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if summary == "" {
+		return "", fmt.Errorf("摘要内容为空")
+	}
+	return summary, nil
+}
+
+// SummaryMessage 将摘要包装为系统消息，放在最近的对话之前
+func SummaryMessage(summary string) ChatMessage {
+	return ChatMessage{
+		Role:    "system",
+		Content: "以下是你与访客之前对话的摘要，请据此保持记忆连贯，不要向访客提及摘要的存在：\n" + summary,
+	}
+}
+
+// summaryRoleLabel 摘要输入中的角色标签
+func summaryRoleLabel(role string) string {
+	switch role {
+	case "user":
+		return "访客"
+	case "assistant":
+		return "角色"
+	default:
+		return role
+	}
+}
@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 测试摘要生成：合并已有摘要和新增对话，使用指定的Provider
+func TestSummarizer_Summarize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		if len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+			t.Errorf("Unexpected messages: %+v", req.Messages)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		input := req.Messages[1].Content
+		for _, want := range []string{"访客叫小林", "访客：我喜欢猫", "角色：记住了"} {
+			if !strings.Contains(input, want) {
+				t.Errorf("Expected summary input to contain '%s', got:\n%s", want, input)
+			}
+		}
+
+		resp := map[string]interface{}{
+			"id":    "test",
+			"model": "qwen-turbo",
+			"choices": []map[string]interface{}{
+				{"index": 0, "message": map[string]string{"role": "assistant", "content": "  访客叫小林，喜欢猫。\n"}, "finish_reason": "stop"},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider("http://127.0.0.1:1", "key", "deepseek-chat"))
+	manager.RegisterProvider("qwen", NewQwenProvider(server.URL, "key", "qwen-turbo"))
+
+	summarizer := NewSummarizer(manager, "qwen")
+	summary, err := summarizer.Summarize(context.Background(), "访客叫小林", []ChatMessage{
+		{Role: "user", Content: "我喜欢猫"},
+		{Role: "assistant", Content: "记住了"},
+	})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary != "访客叫小林，喜欢猫。" {
+		t.Errorf("Unexpected summary: '%s'", summary)
+	}
+
+	// 没有新增对话时直接返回已有摘要
+	summary, err = summarizer.Summarize(context.Background(), "旧摘要", nil)
+	if err != nil || summary != "旧摘要" {
+		t.Errorf("Expected previous summary, got '%s', %v", summary, err)
+	}
+}
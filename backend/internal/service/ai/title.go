@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 标题相关默认值
+const (
+	// DefaultTitle 没有可用文本时的对话标题
+	DefaultTitle = "新对话"
+	// TitleMaxRunes 对话标题的最大字符数
+	TitleMaxRunes = 30
+	// titleMaxTokens 生成标题的最大Token数
+	titleMaxTokens = 40
+	// titleTemperature 生成标题使用较低温度
+	titleTemperature = 0.3
+	// titleInputRunes 生成标题时每条消息最多使用的字符数
+	titleInputRunes = 500
+)
+
+// titleInstruction 生成标题的系统提示
+const titleInstruction = `你负责为对话起标题。
+要求：
+1. 根据访客的第一条消息和回复概括对话主题，不超过15个字
+2. 使用访客消息的语言
+3. 只输出标题本身，不要引号、标点结尾或任何解释`
+
+// TruncateRunes 按字符截断文本，超出时添加省略号
+// 按rune截断，不会切断多字节的中文字符
+func TruncateRunes(text string, maxRunes int) string {
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxRunes]) + "..."
+}
+
+// PlaceholderTitle 根据第一条消息生成临时标题，AI生成的标题完成前使用
+// 取第一行非空文本并截断，消息为空（如只发送了图片）时返回 DefaultTitle
+func PlaceholderTitle(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			return TruncateRunes(line, TitleMaxRunes)
+		}
+	}
+	return DefaultTitle
+}
+
+// Title 根据对话的第一轮生成标题
+// 使用与摘要相同的廉价Provider
+func (s *Summarizer) Title(ctx context.Context, userMessage, reply string) (string, error) {
+	input := fmt.Sprintf("访客：%s\n回复：%s",
+		TruncateRunes(userMessage, titleInputRunes),
+		TruncateRunes(strings.Join(SplitSegmentTexts(reply), " "), titleInputRunes))
+
+	req := &ChatCompletionRequest{
+		Messages: []ChatMessage{
+			{Role: "system", Content: titleInstruction},
+			{Role: "user", Content: input},
+		},
+		Temperature: float32Ptr(titleTemperature),
+		MaxTokens:   titleMaxTokens,
+	}
+
+	resp, err := s.manager.ChatCompletion(ctx, s.provider, req)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("标题响应为空")
+	}
+
+	title := cleanTitle(resp.Choices[0].Message.Content)
+	if title == "" {
+		return "", fmt.Errorf("标题内容为空")
+	}
+	return title, nil
+}
+
+// cleanTitle 清理模型输出的标题：去掉 "标题：" 前缀、引号和结尾标点，只保留第一行
+func cleanTitle(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	for _, prefix := range []string{"标题：", "标题:", "Title:", "title:"} {
+		text = strings.TrimPrefix(text, prefix)
+	}
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) || strings.ContainsRune("「」『』《》“”‘’", r)
+	})
+	return TruncateRunes(text, TitleMaxRunes)
+}
@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 测试按字符截断：不会切断中文字符
+func TestTruncateRunes(t *testing.T) {
+	text := strings.Repeat("中", 40)
+	got := TruncateRunes(text, TitleMaxRunes)
+	if !utf8.ValidString(got) {
+		t.Fatalf("Truncated text is not valid UTF-8: %q", got)
+	}
+	if got != strings.Repeat("中", TitleMaxRunes)+"..." {
+		t.Errorf("Unexpected truncation: %q", got)
+	}
+	if got := TruncateRunes("短标题", TitleMaxRunes); got != "短标题" {
+		t.Errorf("Expected short text unchanged, got %q", got)
+	}
+}
+
+// 测试临时标题：取第一行非空文本，空消息使用默认标题
+func TestPlaceholderTitle(t *testing.T) {
+	testCases := []struct {
+		message  string
+		expected string
+	}{
+		{message: "\n  你好  莫诺 \n第二行", expected: "你好 莫诺"},
+		{message: "", expected: "新对话"},
+		{message: strings.Repeat("长", 31), expected: strings.Repeat("长", 30) + "..."},
+	}
+	for _, tc := range testCases {
+		if got := PlaceholderTitle(tc.message); got != tc.expected {
+			t.Errorf("PlaceholderTitle(%q) = %q, expected %q", tc.message, got, tc.expected)
+		}
+	}
+}
+
+// 测试生成标题：清理模型输出的前缀、引号和结尾标点
+func TestSummarizer_Title(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if input := req.Messages[1].Content; !strings.Contains(input, "访客：推荐一本书") || strings.Contains(input, "$") {
+			t.Errorf("Unexpected title input: %s", input)
+		}
+
+		resp := map[string]interface{}{
+			"id":    "test",
+			"model": "qwen-turbo",
+			"choices": []map[string]interface{}{
+				{"index": 0, "message": map[string]string{"role": "assistant", "content": "标题：《读书推荐》。\n解释"}, "finish_reason": "stop"},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("qwen", NewQwenProvider(server.URL, "key", "qwen-turbo"))
+
+	title, err := NewSummarizer(manager, "qwen").Title(context.Background(), "推荐一本书", "好呀$试试《百年孤独》")
+	if err != nil {
+		t.Fatalf("Title failed: %v", err)
+	}
+	if title != "读书推荐" {
+		t.Errorf("Expected '读书推荐', got '%s'", title)
+	}
+}
@@ -0,0 +1,81 @@
+package ai
+
+import "unicode"
+
+// TokenRatio 估算Token数的系数
+// CJK: 每个中日韩字符约消耗的Token数
+// Other: 每个其他字符（英文、数字、标点等）约消耗的Token数
+// 各厂商分词器差异较大，中文尤其明显，因此按Provider类型区分
+type TokenRatio struct {
+	CJK   float64
+	Other float64
+}
+
+// defaultTokenRatio 未知类型使用的保守估算系数
+var defaultTokenRatio = TokenRatio{CJK: 1.0, Other: 0.3}
+
+// tokenRatios 各Provider类型的估算系数
+// 国产模型的词表对中文更友好，OpenAI和Anthropic的中文Token消耗更高
+var tokenRatios = map[string]TokenRatio{
+	"deepseek":  {CJK: 0.6, Other: 0.3},
+	"qwen":      {CJK: 0.7, Other: 0.3},
+	"glm":       {CJK: 0.7, Other: 0.3},
+	"kimi":      {CJK: 0.7, Other: 0.3},
+	"openai":    {CJK: 1.0, Other: 0.25},
+	"anthropic": {CJK: 1.2, Other: 0.3},
+}
+
+// messageTokenOverhead 每条消息的格式开销（角色标记、分隔符等）
+const messageTokenOverhead = 4
+
+// TokenRatioFor 获取Provider类型的估算系数
+func TokenRatioFor(kind string) TokenRatio {
+	if ratio, ok := tokenRatios[kind]; ok {
+		return ratio
+	}
+	return defaultTokenRatio
+}
+
+// EstimateTokens 估算文本消耗的Token数
+// 只用于上下文裁剪，结果偏保守即可，不要求与厂商计费完全一致
+func (r TokenRatio) EstimateTokens(text string) int {
+	var cjk, other int
+	for _, ch := range text {
+		if isCJK(ch) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	tokens := float64(cjk)*r.CJK + float64(other)*r.Other
+	// 向上取整，避免短文本被估算为0
+	n := int(tokens)
+	if float64(n) < tokens {
+		n++
+	}
+	return n
+}
+
+// EstimateMessageTokens 估算单条消息消耗的Token数（包含格式开销）
+func (r TokenRatio) EstimateMessageTokens(msg ChatMessage) int {
+	tokens := r.EstimateTokens(msg.Content) + messageTokenOverhead
+	for _, call := range msg.ToolCalls {
+		tokens += r.EstimateTokens(call.Function.Name) + r.EstimateTokens(call.Function.Arguments) + messageTokenOverhead
+	}
+	for _, part := range msg.Parts {
+		if part.Type == "image_url" {
+			tokens += imageTokenEstimate
+		}
+	}
+	return tokens
+}
+
+// isCJK 判断是否为中日韩字符（包括全角标点）
+func isCJK(ch rune) bool {
+	return unicode.Is(unicode.Han, ch) ||
+		unicode.Is(unicode.Hiragana, ch) ||
+		unicode.Is(unicode.Katakana, ch) ||
+		unicode.Is(unicode.Hangul, ch) ||
+		(ch >= 0x3000 && ch <= 0x303F) ||
+		(ch >= 0xFF00 && ch <= 0xFFEF)
+}
@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// maxToolRounds 单次对话最多执行的工具调用轮数
+// 超过后禁止继续调用工具，要求模型直接根据已有结果回答
+const maxToolRounds = 5
+
+// ToolHandler 工具执行函数
+// arguments: 模型生成的JSON参数
+// 返回值会作为tool消息的内容发送给模型，通常为JSON字符串
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// Toolbox 工具集合
+// 注册工具定义和对应的执行函数，供AIManager的工具调用循环使用
+type Toolbox struct {
+	mu       sync.RWMutex
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+// NewToolbox 创建空的工具集合
+func NewToolbox() *Toolbox {
+	return &Toolbox{handlers: make(map[string]ToolHandler)}
+}
+
+// Register 注册工具，同名工具会覆盖之前的定义
+func (t *Toolbox) Register(function ToolFunction, handler ToolHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if function.Parameters == nil {
+		function.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+
+	if _, exists := t.handlers[function.Name]; exists {
+		for i := range t.tools {
+			if t.tools[i].Function.Name == function.Name {
+				t.tools[i].Function = function
+			}
+		}
+	} else {
+		t.tools = append(t.tools, Tool{Type: "function", Function: function})
+	}
+	t.handlers[function.Name] = handler
+}
+
+// Definitions 返回所有工具定义，用于填充请求的Tools字段
+func (t *Toolbox) Definitions() []Tool {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tools := make([]Tool, len(t.tools))
+	copy(tools, t.tools)
+	return tools
+}
+
+// Execute 执行一次工具调用并返回tool消息
+// 工具不存在、参数错误或执行失败时，错误信息作为结果返回给模型，由模型决定如何回答
+func (t *Toolbox) Execute(ctx context.Context, call ToolCall) ChatMessage {
+	result := ChatMessage{Role: "tool", ToolCallID: call.ID, Name: call.Function.Name}
+
+	t.mu.RLock()
+	handler, exists := t.handlers[call.Function.Name]
+	t.mu.RUnlock()
+	if !exists {
+		result.Content = toolError(fmt.Sprintf("工具 %s 不存在", call.Function.Name))
+		return result
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		result.Content = toolError("参数不是合法的JSON")
+		return result
+	}
+
+	content, err := handler(ctx, arguments)
+	if err != nil {
+		log.Printf("[Toolbox] 执行工具 %s 失败: %v", call.Function.Name, err)
+		result.Content = toolError(err.Error())
+		return result
+	}
+	result.Content = content
+	return result
+}
+
+// toolError 将错误信息包装为JSON，作为工具结果返回给模型
+func toolError(message string) string {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	return string(data)
+}
+
+// ToolResult 工具调用循环的结果
+// Response: 最终回复（Usage为所有轮次的累计值）
+// Steps: 循环中产生的中间消息（带工具调用的assistant消息和tool消息），按顺序排列，用于持久化
+type ToolResult struct {
+	Response *ChatCompletionResponse
+	Steps    []ChatMessage
+}
+
+// ChatCompletionWithTools 执行带工具调用的聊天请求
+// 模型请求调用工具时执行工具并把结果追加到消息列表，直到模型给出最终回复
+// 第一轮之后固定使用实际应答的Provider，避免工具调用ID在不同厂商之间混用
+func (m *AIManager) ChatCompletionWithTools(ctx context.Context, providerName string, req *ChatCompletionRequest, toolbox *Toolbox) (*ToolResult, error) {
+	return m.runTools(ctx, providerName, req, toolbox, func(name string, roundReq *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+		return m.ChatCompletion(ctx, name, roundReq)
+	})
+}
+
+// ChatCompletionStreamWithTools 执行带工具调用的流式聊天请求
+// 只有最终回复的文本增量会通过onDelta输出：可以调用工具的轮次先缓存增量，
+// 该轮没有工具调用时再按顺序输出，请求工具的轮次中的铺垫文字（如"我查一下"）不会发给客户端
+func (m *AIManager) ChatCompletionStreamWithTools(ctx context.Context, providerName string, req *ChatCompletionRequest, toolbox *Toolbox, onDelta StreamHandler) (*ToolResult, error) {
+	return m.runTools(ctx, providerName, req, toolbox, func(name string, roundReq *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+		// 不允许调用工具的轮次必然是最终回复，直接输出
+		if len(roundReq.Tools) == 0 || roundReq.ToolChoice == "none" {
+			return m.ChatCompletionStream(ctx, name, roundReq, onDelta)
+		}
+
+		var deltas []string
+		resp, err := m.ChatCompletionStream(ctx, name, roundReq, func(delta string) error {
+			deltas = append(deltas, delta)
+			return nil
+		})
+		if err != nil || (len(resp.Choices) > 0 && len(resp.Choices[0].Message.ToolCalls) > 0) {
+			return resp, err
+		}
+		for _, delta := range deltas {
+			if err := onDelta(delta); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	})
+}
+
+// runTools 工具调用循环
+func (m *AIManager) runTools(ctx context.Context, providerName string, req *ChatCompletionRequest, toolbox *Toolbox, call func(name string, roundReq *ChatCompletionRequest) (*ChatCompletionResponse, error)) (*ToolResult, error) {
+	roundReq := *req
+	roundReq.Messages = append([]ChatMessage(nil), req.Messages...)
+	roundReq.Tools = toolbox.Definitions()
+
+	result := &ToolResult{}
+	var usage ChatUsage
+	for round := 0; ; round++ {
+		if len(roundReq.Tools) > 0 && round >= maxToolRounds {
+			roundReq.ToolChoice = "none"
+		}
+
+		resp, err := call(providerName, &roundReq)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+
+		// 没有工具调用（或不允许调用工具）时即为最终回复
+		if len(roundReq.Tools) == 0 || roundReq.ToolChoice == "none" ||
+			len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+			// 最终回复中不应再有工具调用，否则保存的历史会出现没有结果的调用
+			if len(resp.Choices) > 0 {
+				resp.Choices[0].Message.ToolCalls = nil
+			}
+			resp.Usage = usage
+			result.Response = resp
+			return result, nil
+		}
+
+		// 执行工具并把结果追加到消息列表
+		assistant := resp.Choices[0].Message
+		assistant.Role = "assistant"
+		roundReq.Messages = append(roundReq.Messages, assistant)
+		result.Steps = append(result.Steps, assistant)
+		for _, toolCall := range assistant.ToolCalls {
+			log.Printf("[AIManager] 执行工具 %s, 参数: %s", toolCall.Function.Name, toolCall.Function.Arguments)
+			toolMsg := toolbox.Execute(ctx, toolCall)
+			roundReq.Messages = append(roundReq.Messages, toolMsg)
+			result.Steps = append(result.Steps, toolMsg)
+		}
+
+		if resp.Provider != "" {
+			providerName = resp.Provider
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newWeatherToolbox 创建测试用的工具集合
+func newWeatherToolbox() *Toolbox {
+	toolbox := NewToolbox()
+	toolbox.Register(ToolFunction{
+		Name:        "get_weather",
+		Description: "查询天气",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+		},
+	}, func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		var args struct {
+			City string `json:"city"`
+		}
+		json.Unmarshal(arguments, &args)
+		if args.City == "" {
+			return "", fmt.Errorf("city不能为空")
+		}
+		return `{"city":"` + args.City + `","weather":"晴"}`, nil
+	})
+	return toolbox
+}
+
+// 测试工具执行：正常结果、未知工具、非法参数和执行错误都返回tool消息
+func TestToolbox_Execute(t *testing.T) {
+	toolbox := newWeatherToolbox()
+
+	testCases := []struct {
+		name     string
+		call     ToolCall
+		contains string
+	}{
+		{name: "success", call: ToolCall{ID: "1", Function: ToolCallFunction{Name: "get_weather", Arguments: `{"city":"杭州"}`}}, contains: `"weather":"晴"`},
+		{name: "unknown tool", call: ToolCall{ID: "2", Function: ToolCallFunction{Name: "nope", Arguments: `{}`}}, contains: "不存在"},
+		{name: "invalid json", call: ToolCall{ID: "3", Function: ToolCallFunction{Name: "get_weather", Arguments: `{city:`}}, contains: "不是合法的JSON"},
+		{name: "handler error", call: ToolCall{ID: "4", Function: ToolCallFunction{Name: "get_weather", Arguments: ``}}, contains: "city不能为空"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := toolbox.Execute(context.Background(), tc.call)
+			if msg.Role != "tool" || msg.ToolCallID != tc.call.ID {
+				t.Errorf("Unexpected tool message: %+v", msg)
+			}
+			if !strings.Contains(msg.Content, tc.contains) {
+				t.Errorf("Expected content to contain '%s', got '%s'", tc.contains, msg.Content)
+			}
+		})
+	}
+}
+
+// 测试工具调用循环：执行工具后把结果发回模型，返回最终回复和中间消息
+func TestAIManager_ChatCompletionWithTools(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Tools) != 1 || req.Tools[0].Function.Name != "get_weather" {
+			t.Errorf("Expected tool definitions, got %+v", req.Tools)
+		}
+
+		message := map[string]interface{}{"role": "assistant", "content": ""}
+		finishReason := "tool_calls"
+		if atomic.AddInt32(&calls, 1) == 1 {
+			message["tool_calls"] = []map[string]interface{}{
+				{"id": "call_1", "type": "function", "function": map[string]string{"name": "get_weather", "arguments": `{"city":"杭州"}`}},
+			}
+		} else {
+			last := req.Messages[len(req.Messages)-1]
+			if last.Role != "tool" || last.ToolCallID != "call_1" || !strings.Contains(last.Content, "晴") {
+				t.Errorf("Expected tool result as last message, got %+v", last)
+			}
+			if prev := req.Messages[len(req.Messages)-2]; len(prev.ToolCalls) != 1 {
+				t.Errorf("Expected assistant tool call before result, got %+v", prev)
+			}
+			message["content"] = "杭州今天晴"
+			finishReason = "stop"
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "test",
+			"model":   "deepseek-chat",
+			"choices": []map[string]interface{}{{"index": 0, "message": message, "finish_reason": finishReason}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(server.URL, "key", "deepseek-chat"))
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "杭州天气怎么样"}}}
+	result, err := manager.ChatCompletionWithTools(context.Background(), "deepseek", req, newWeatherToolbox())
+	if err != nil {
+		t.Fatalf("ChatCompletionWithTools failed: %v", err)
+	}
+
+	if result.Response.Choices[0].Message.Content != "杭州今天晴" {
+		t.Errorf("Unexpected reply: '%s'", result.Response.Choices[0].Message.Content)
+	}
+	if result.Response.Usage.TotalTokens != 30 {
+		t.Errorf("Expected accumulated usage 30, got %d", result.Response.Usage.TotalTokens)
+	}
+	if len(result.Steps) != 2 || result.Steps[0].Role != "assistant" || result.Steps[1].Role != "tool" {
+		t.Errorf("Unexpected steps: %+v", result.Steps)
+	}
+	if len(req.Messages) != 1 {
+		t.Errorf("Original request should not be modified, got %d messages", len(req.Messages))
+	}
+}
+
+// 测试工具调用轮数上限：超过后以tool_choice=none要求模型直接回答
+func TestAIManager_ToolRoundLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		n := atomic.AddInt32(&calls, 1)
+		if int(n) > maxToolRounds && req.ToolChoice != "none" {
+			t.Errorf("Expected tool_choice none after %d rounds", maxToolRounds)
+		}
+
+		// 模型始终请求调用工具
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{
+				"index": 0,
+				"message": map[string]interface{}{
+					"role": "assistant", "content": "稍等",
+					"tool_calls": []map[string]interface{}{
+						{"id": fmt.Sprintf("call_%d", n), "type": "function", "function": map[string]string{"name": "get_weather", "arguments": `{"city":"杭州"}`}},
+					},
+				},
+				"finish_reason": "tool_calls",
+			}},
+		})
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(server.URL, "key", "deepseek-chat"))
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+	result, err := manager.ChatCompletionWithTools(context.Background(), "deepseek", req, newWeatherToolbox())
+	if err != nil {
+		t.Fatalf("ChatCompletionWithTools failed: %v", err)
+	}
+	if calls != maxToolRounds+1 {
+		t.Errorf("Expected %d calls, got %d", maxToolRounds+1, calls)
+	}
+	if len(result.Response.Choices[0].Message.ToolCalls) != 0 {
+		t.Error("Final reply should not contain tool calls")
+	}
+}
+
+// 测试流式工具调用：按index拼接分段到达的参数
+func TestStreamToolCallAccumulation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"杭州\"}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewDeepSeekProvider(server.URL, "key", "deepseek-chat")
+	resp, err := provider.ChatCompletionStream(context.Background(), &ChatCompletionRequest{}, func(delta string) error { return nil })
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	calls := resp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %d", len(calls))
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"杭州"}` {
+		t.Errorf("Unexpected tool call: %+v", calls[0])
+	}
+}
+
+// 测试流式工具调用：请求工具的轮次中的铺垫文字不输出，只输出最终回复
+func TestAIManager_ChatCompletionStreamWithTools(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&calls, 1) == 1 {
+			fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"我查一下"}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"杭州\"}"}}]},"finish_reason":"tool_calls"}]}`+"\n\n")
+		} else {
+			fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"杭州"}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"content":"今天晴"},"finish_reason":"stop"}]}`+"\n\n")
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(server.URL, "key", "deepseek-chat"))
+
+	var streamed []string
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "杭州天气怎么样"}}}
+	result, err := manager.ChatCompletionStreamWithTools(context.Background(), "deepseek", req, newWeatherToolbox(), func(delta string) error {
+		streamed = append(streamed, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStreamWithTools failed: %v", err)
+	}
+
+	if strings.Join(streamed, "") != "杭州今天晴" || len(streamed) != 2 {
+		t.Errorf("Expected only final reply deltas, got %q", streamed)
+	}
+	if result.Response.Choices[0].Message.Content != "杭州今天晴" {
+		t.Errorf("Unexpected reply: '%s'", result.Response.Choices[0].Message.Content)
+	}
+	if len(result.Steps) != 2 || result.Steps[0].Content != "我查一下" {
+		t.Errorf("Expected preamble kept in steps, got %+v", result.Steps)
+	}
+}
+
+// 测试Anthropic工具调用格式转换
+func TestConvertAnthropicMessages_Tools(t *testing.T) {
+	_, messages := convertAnthropicMessages([]ChatMessage{
+		{Role: "user", Content: "杭州和上海天气"},
+		{Role: "assistant", Content: "我查一下", ToolCalls: []ToolCall{
+			{ID: "t1", Type: "function", Function: ToolCallFunction{Name: "get_weather", Arguments: `{"city":"杭州"}`}},
+			{ID: "t2", Type: "function", Function: ToolCallFunction{Name: "get_weather", Arguments: `{"city":"上海"}`}},
+		}},
+		{Role: "tool", ToolCallID: "t1", Content: "晴"},
+		{Role: "tool", ToolCallID: "t2", Content: "雨"},
+	})
+
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d: %+v", len(messages), messages)
+	}
+
+	assistant := messages[1].Blocks
+	if len(assistant) != 3 || assistant[0].Type != "text" || assistant[1].Type != "tool_use" || string(assistant[2].Input) != `{"city":"上海"}` {
+		t.Errorf("Unexpected assistant blocks: %+v", assistant)
+	}
+
+	// 连续的工具结果合并为同一条user消息
+	results := messages[2]
+	if results.Role != "user" || len(results.Blocks) != 2 || results.Blocks[1].ToolUseID != "t2" || results.Blocks[1].Content != "雨" {
+		t.Errorf("Unexpected tool results: %+v", results)
+	}
+
+	data, _ := json.Marshal(results)
+	if !strings.Contains(string(data), `"type":"tool_result"`) {
+		t.Errorf("Expected tool_result block in JSON, got %s", data)
+	}
+}
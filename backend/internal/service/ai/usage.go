@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"time"
+)
+
+// 调用用途，记录在用量中区分聊天和后台任务
+const (
+	PurposeChat       = "chat"
+	PurposeSummary    = "summary"
+	PurposeTitle      = "title"
+	PurposeModeration = "moderation"
+	PurposeEmbedding  = "embedding"
+)
+
+// UsageLabels 调用的归属信息，由调用方通过context传入
+type UsageLabels struct {
+	Purpose        string
+	SessionID      string
+	ConversationID uint
+	CharacterID    uint
+}
+
+// usageLabelsKey 归属信息在context中的键
+type usageLabelsKey struct{}
+
+// WithUsageLabels 将归属信息写入context，AIManager记录用量时读取
+func WithUsageLabels(ctx context.Context, labels UsageLabels) context.Context {
+	return context.WithValue(ctx, usageLabelsKey{}, labels)
+}
+
+// UsageLabelsFrom 从context读取归属信息
+func UsageLabelsFrom(ctx context.Context) UsageLabels {
+	labels, _ := ctx.Value(usageLabelsKey{}).(UsageLabels)
+	return labels
+}
+
+// UsageEvent 一次Provider调用的用量，降级时每次尝试各记录一条
+// Estimated为true表示Provider没有返回用量（如流式请求不支持include_usage），Token数为估算值
+// ProviderID为数据库中Provider配置的ID，环境变量加载的Provider为0
+type UsageEvent struct {
+	Labels     UsageLabels
+	Provider   string
+	ProviderID uint
+	Model      string
+	Usage      ChatUsage
+	Estimated  bool
+	Latency    time.Duration
+	Err        error
+}
+
+// UsageRecorder 用量记录回调，在调用返回后同步执行，耗时操作需要自行异步处理
+type UsageRecorder func(event UsageEvent)
+
+// SetUsageRecorder 设置用量记录回调，为nil时不记录
+func (m *AIManager) SetUsageRecorder(recorder UsageRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usageRecorder = recorder
+}
+
+// recordUsage 记录一次Provider调用的用量
+func (m *AIManager) recordUsage(ctx context.Context, name string, provider AIProvider, req *ChatCompletionRequest, resp *ChatCompletionResponse, latency time.Duration, err error) {
+	m.mu.RLock()
+	recorder := m.usageRecorder
+	cfg := m.configs[name]
+	m.mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+	kind := cfg.Kind
+	if kind == "" {
+		kind = provider.GetProviderName()
+	}
+
+	event := UsageEvent{
+		Labels:     UsageLabelsFrom(ctx),
+		Provider:   name,
+		ProviderID: cfg.ID,
+		Model:      provider.GetModelName(),
+		Latency:    latency,
+		Err:        err,
+	}
+	if resp != nil {
+		if resp.Model != "" {
+			event.Model = resp.Model
+		}
+		event.Usage = resp.Usage
+		if event.Usage.TotalTokens == 0 {
+			event.Usage = estimateUsage(TokenRatioFor(kind), req, resp)
+			event.Estimated = true
+		}
+	}
+	recorder(event)
+}
+
+// recordEmbeddingUsage 记录一次向量化调用的用量
+// 保留调用方的会话等归属信息，用途固定为向量化；向量化接口不返回用量，Token数按输入文本估算
+func (m *AIManager) recordEmbeddingUsage(ctx context.Context, name string, provider AIProvider, model string, texts []string, latency time.Duration, err error) {
+	m.mu.RLock()
+	recorder := m.usageRecorder
+	cfg := m.configs[name]
+	m.mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+	kind := cfg.Kind
+	if kind == "" {
+		kind = provider.GetProviderName()
+	}
+
+	labels := UsageLabelsFrom(ctx)
+	labels.Purpose = PurposeEmbedding
+	event := UsageEvent{
+		Labels:     labels,
+		Provider:   name,
+		ProviderID: cfg.ID,
+		Model:      model,
+		Estimated:  true,
+		Latency:    latency,
+		Err:        err,
+	}
+	if err == nil {
+		ratio := TokenRatioFor(kind)
+		for _, text := range texts {
+			event.Usage.PromptTokens += ratio.EstimateTokens(text)
+		}
+		event.Usage.TotalTokens = event.Usage.PromptTokens
+	}
+	recorder(event)
+}
+
+// estimateUsage 按字符数估算请求和回复的Token数
+func estimateUsage(ratio TokenRatio, req *ChatCompletionRequest, resp *ChatCompletionResponse) ChatUsage {
+	var usage ChatUsage
+	for _, msg := range req.Messages {
+		usage.PromptTokens += ratio.EstimateMessageTokens(msg)
+	}
+	for _, choice := range resp.Choices {
+		usage.CompletionTokens += ratio.EstimateMessageTokens(choice.Message)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
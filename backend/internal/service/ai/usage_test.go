@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// 测试用量记录：降级时每次尝试各记录一条，并带上context中的归属信息
+func TestAIManager_UsageRecorder(t *testing.T) {
+	var primaryHits, fallbackHits int32
+	primary := newMockServer(t, http.StatusInternalServerError, "", &primaryHits)
+	defer primary.Close()
+	fallback := newMockServer(t, http.StatusOK, "fallback", &fallbackHits)
+	defer fallback.Close()
+
+	manager := NewAIManager()
+	manager.RegisterProvider("deepseek", NewDeepSeekProvider(primary.URL, "key", "deepseek-chat"))
+	manager.RegisterProvider("qwen", NewQwenProvider(fallback.URL, "key", "qwen-turbo"))
+	manager.SetFallbackChain([]string{"deepseek", "qwen"})
+
+	var events []UsageEvent
+	manager.SetUsageRecorder(func(event UsageEvent) {
+		events = append(events, event)
+	})
+
+	ctx := WithUsageLabels(context.Background(), UsageLabels{Purpose: PurposeChat, SessionID: "s1", CharacterID: 3})
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "test"}}}
+	if _, err := manager.ChatCompletion(ctx, "", req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 usage events, got %d", len(events))
+	}
+	if events[0].Provider != "deepseek" || events[0].Err == nil {
+		t.Errorf("Expected failed deepseek event, got %+v", events[0])
+	}
+	success := events[1]
+	if success.Provider != "qwen" || success.Err != nil || success.Model != "qwen-turbo" {
+		t.Errorf("Unexpected success event: %+v", success)
+	}
+	if success.Usage.TotalTokens != 2 || success.Estimated {
+		t.Errorf("Expected reported usage, got %+v (estimated=%v)", success.Usage, success.Estimated)
+	}
+	if success.Labels.SessionID != "s1" || success.Labels.CharacterID != 3 {
+		t.Errorf("Expected labels from context, got %+v", success.Labels)
+	}
+}
+
+// 测试Provider没有返回用量时按字符数估算
+func TestEstimateUsage(t *testing.T) {
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: "user", Content: "你好你好"}}}
+	resp := &ChatCompletionResponse{Choices: []ChatChoice{{Message: ChatMessage{Role: "assistant", Content: "hello world"}}}}
+
+	usage := estimateUsage(TokenRatioFor("deepseek"), req, resp)
+	if usage.PromptTokens == 0 || usage.CompletionTokens == 0 {
+		t.Errorf("Expected non-zero estimate, got %+v", usage)
+	}
+	if usage.TotalTokens != usage.PromptTokens+usage.CompletionTokens {
+		t.Errorf("Expected total to be sum, got %+v", usage)
+	}
+}
@@ -0,0 +1,166 @@
+// Package chatexport 负责对话的导出和导入
+// 导出支持Markdown、JSON和JSONL；导入支持本站导出的JSON和ChatGPT导出的 conversations.json
+package chatexport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+)
+
+// FormatVersion 本站导出格式的版本号
+const FormatVersion = 1
+
+// ErrUnknownFormat 无法识别的导入格式
+var ErrUnknownFormat = errors.New("无法识别的对话格式，只支持本站导出的JSON和ChatGPT导出的conversations.json")
+
+// Document 一个对话的导出内容，只包含当前分支上展示给访客的消息
+type Document struct {
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	Messages  []Message `json:"messages"`
+}
+
+// Message 导出的消息
+// Character为回复消息的角色名称，导入时按名称匹配角色
+type Message struct {
+	Role        string              `json:"role"`
+	Content     string              `json:"content"`
+	Character   string              `json:"character,omitempty"`
+	Attachments []models.Attachment `json:"attachments,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// FromConversation 根据对话和当前分支的消息构建导出内容
+// characters为角色ID到名称的映射，工具调用的中间过程不导出
+func FromConversation(conversation models.Conversation, messages []models.ChatMessage, characters map[uint]string) Document {
+	doc := Document{
+		Version:   FormatVersion,
+		Title:     conversation.Title,
+		CreatedAt: conversation.CreatedAt,
+		Messages:  make([]Message, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		if !msg.Visible() {
+			continue
+		}
+		exported := Message{
+			Role:        msg.MessageType,
+			Content:     msg.Content,
+			Attachments: msg.Attachments,
+			CreatedAt:   msg.CreatedAt,
+		}
+		if msg.MessageType == "assistant" {
+			exported.Character = characters[msg.CharacterID]
+		}
+		doc.Messages = append(doc.Messages, exported)
+	}
+	return doc
+}
+
+// Markdown 将对话渲染为Markdown
+// 回复按聊天气泡分段，图片以Markdown图片语法引用
+func (d Document) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.Title)
+	if !d.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "> 导出自 %s 的对话\n\n", d.CreatedAt.Format("2006-01-02 15:04"))
+	}
+
+	for _, msg := range d.Messages {
+		speaker := "我"
+		if msg.Role == "assistant" {
+			speaker = msg.Character
+			if speaker == "" {
+				speaker = "AI"
+			}
+		}
+		fmt.Fprintf(&b, "**%s**", speaker)
+		if !msg.CreatedAt.IsZero() {
+			fmt.Fprintf(&b, " · %s", msg.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		b.WriteString("\n\n")
+
+		if msg.Role == "assistant" {
+			b.WriteString(strings.Join(ai.SplitSegmentTexts(msg.Content), "\n\n"))
+		} else {
+			b.WriteString(msg.Content)
+		}
+		for _, attachment := range msg.Attachments {
+			fmt.Fprintf(&b, "\n\n![图片](%s)", attachment.URL)
+		}
+		b.WriteString("\n\n---\n\n")
+	}
+	return b.String()
+}
+
+// WriteJSONL 将消息逐行写入w，每行一条消息
+func (d Document) WriteJSONL(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, msg := range d.Messages {
+		if err := encoder.Encode(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse 解析导入的对话，自动识别格式
+// 支持本站导出的单个对话JSON，以及ChatGPT导出的对话数组或单个对话
+func Parse(data []byte) ([]Document, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var conversations []chatGPTConversation
+		if err := json.Unmarshal(data, &conversations); err != nil {
+			return nil, fmt.Errorf("解析ChatGPT导出文件失败: %w", err)
+		}
+		docs := make([]Document, 0, len(conversations))
+		for _, conversation := range conversations {
+			if doc := conversation.document(); len(doc.Messages) > 0 {
+				docs = append(docs, doc)
+			}
+		}
+		return docs, nil
+	}
+
+	var probe struct {
+		Mapping  json.RawMessage `json:"mapping"`
+		Messages json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, ErrUnknownFormat
+	}
+
+	switch {
+	case probe.Mapping != nil:
+		var conversation chatGPTConversation
+		if err := json.Unmarshal(data, &conversation); err != nil {
+			return nil, fmt.Errorf("解析ChatGPT对话失败: %w", err)
+		}
+		return []Document{conversation.document()}, nil
+
+	case probe.Messages != nil:
+		var doc Document
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析对话失败: %w", err)
+		}
+		messages := doc.Messages[:0]
+		for _, msg := range doc.Messages {
+			if (msg.Role == "user" || msg.Role == "assistant") && (msg.Content != "" || len(msg.Attachments) > 0) {
+				messages = append(messages, msg)
+			}
+		}
+		doc.Messages = messages
+		return []Document{doc}, nil
+
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
@@ -0,0 +1,124 @@
+package chatexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"personal-website/internal/models"
+)
+
+// 测试导出：只导出可见消息，Markdown按气泡分段
+func TestFromConversation(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	conversation := models.Conversation{Title: "和莫诺聊天", CreatedAt: created}
+	messages := []models.ChatMessage{
+		{MessageType: "user", Content: "你好", CreatedAt: created},
+		{MessageType: "assistant", Content: "", ToolCalls: models.JSON(`[{"id":"call_1"}]`), CharacterID: 1},
+		{MessageType: "tool", Content: "{}", ToolCallID: "call_1"},
+		{MessageType: "assistant", Content: "你好呀$今天想聊什么", CharacterID: 1, CreatedAt: created},
+	}
+
+	doc := FromConversation(conversation, messages, map[uint]string{1: "莫诺"})
+	if len(doc.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(doc.Messages))
+	}
+	if doc.Messages[1].Character != "莫诺" {
+		t.Errorf("Expected character name, got %q", doc.Messages[1].Character)
+	}
+
+	markdown := doc.Markdown()
+	for _, want := range []string{"# 和莫诺聊天", "**莫诺**", "你好呀\n\n今天想聊什么"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", want, markdown)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := doc.WriteJSONL(&buf); err != nil {
+		t.Fatalf("WriteJSONL failed: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("Expected 2 lines, got %d", lines)
+	}
+}
+
+// 测试本站JSON格式导出后可以重新导入
+func TestParse_RoundTrip(t *testing.T) {
+	doc := Document{
+		Version: FormatVersion,
+		Title:   "测试",
+		Messages: []Message{
+			{Role: "user", Content: "问题"},
+			{Role: "system", Content: "应被忽略"},
+			{Role: "assistant", Content: "回答", Character: "莫诺"},
+		},
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	docs, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(docs) != 1 || docs[0].Title != "测试" || len(docs[0].Messages) != 2 {
+		t.Fatalf("Unexpected result: %+v", docs)
+	}
+	if docs[0].Messages[1].Character != "莫诺" {
+		t.Errorf("Expected character kept, got %+v", docs[0].Messages[1])
+	}
+}
+
+// 测试ChatGPT导出格式：沿current_node取当前分支，跳过系统消息和隐藏消息
+func TestParse_ChatGPT(t *testing.T) {
+	data := `[{
+		"title": "旅行计划",
+		"create_time": 1700000000.5,
+		"current_node": "d",
+		"mapping": {
+			"root": {"id": "root", "message": null, "parent": null},
+			"s": {"id": "s", "parent": "root", "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}},
+			"a": {"id": "a", "parent": "s", "message": {"author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["去哪玩？"]}}},
+			"b": {"id": "b", "parent": "a", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["旧回答"]}}},
+			"c": {"id": "c", "parent": "a", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["去杭州"]}}},
+			"d": {"id": "d", "parent": "c", "message": {"author": {"role": "user"}, "content": {"content_type": "multimodal_text", "parts": [{"content_type": "image_asset_pointer"}, "这里怎么样"]}}}
+		}
+	}, {
+		"title": "空对话",
+		"mapping": {}
+	}]`
+
+	docs, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("Expected empty conversation skipped, got %d docs", len(docs))
+	}
+
+	doc := docs[0]
+	if doc.Title != "旅行计划" || doc.CreatedAt.Unix() != 1700000000 {
+		t.Errorf("Unexpected document header: %+v", doc)
+	}
+	var contents []string
+	for _, msg := range doc.Messages {
+		contents = append(contents, msg.Role+":"+msg.Content)
+	}
+	expected := []string{"user:去哪玩？", "assistant:去杭州", "user:[图片]\n这里怎么样"}
+	if strings.Join(contents, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, contents)
+	}
+}
+
+// 测试无法识别的格式
+func TestParse_Unknown(t *testing.T) {
+	for _, data := range []string{`{"foo": 1}`, `not json`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package chatexport
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+)
+
+// chatGPTConversation ChatGPT导出文件中的一个对话
+// 消息以树的形式保存在mapping中，current_node为当前显示分支的最后一个节点
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+// chatGPTNode 对话树中的节点，根节点没有消息
+type chatGPTNode struct {
+	Message *chatGPTMessage `json:"message"`
+	Parent  string          `json:"parent"`
+}
+
+// chatGPTMessage ChatGPT的消息
+// content.parts中文本为字符串，图片等其他内容为对象
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		IsVisuallyHiddenFromConversation bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// document 将ChatGPT对话转换为导出格式，只保留当前分支上的用户消息和回复
+// 图片等非文本内容用占位文本代替，系统消息和工具调用过程不导入
+func (c chatGPTConversation) document() Document {
+	doc := Document{
+		Version:   FormatVersion,
+		Title:     c.Title,
+		CreatedAt: unixTime(c.CreateTime),
+	}
+
+	// 从当前节点向上找到根节点，再翻转为正序
+	var path []chatGPTMessage
+	seen := make(map[string]bool)
+	for id := c.currentNode(); id != "" && !seen[id]; {
+		seen[id] = true
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if node.Message != nil {
+			path = append(path, *node.Message)
+		}
+		id = node.Parent
+	}
+
+	for i := len(path) - 1; i >= 0; i-- {
+		msg := path[i]
+		role := msg.Author.Role
+		if (role != "user" && role != "assistant") || msg.Metadata.IsVisuallyHiddenFromConversation {
+			continue
+		}
+		content := msg.text()
+		if content == "" {
+			continue
+		}
+		doc.Messages = append(doc.Messages, Message{
+			Role:      role,
+			Content:   content,
+			CreatedAt: unixTime(msg.CreateTime),
+		})
+	}
+	return doc
+}
+
+// currentNode 当前分支的最后一个节点，旧版导出文件没有current_node时取没有子节点的最新节点
+func (c chatGPTConversation) currentNode() string {
+	if c.CurrentNode != "" {
+		return c.CurrentNode
+	}
+
+	hasChild := make(map[string]bool)
+	for _, node := range c.Mapping {
+		hasChild[node.Parent] = true
+	}
+	var latest string
+	var latestTime float64 = -1
+	for id, node := range c.Mapping {
+		if hasChild[id] || node.Message == nil {
+			continue
+		}
+		if node.Message.CreateTime > latestTime {
+			latest, latestTime = id, node.Message.CreateTime
+		}
+	}
+	return latest
+}
+
+// text 消息的文本内容，非文本部分用占位文本代替
+func (m chatGPTMessage) text() string {
+	switch m.Content.ContentType {
+	case "text", "multimodal_text", "":
+	default:
+		// 代码执行、浏览结果等中间内容不导入
+		return ""
+	}
+
+	var parts []string
+	for _, raw := range m.Content.Parts {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			if text = strings.TrimSpace(text); text != "" {
+				parts = append(parts, text)
+			}
+			continue
+		}
+		parts = append(parts, "[图片]")
+	}
+	return strings.Join(parts, "\n")
+}
+
+// unixTime 将ChatGPT使用的浮点秒时间戳转换为时间，0表示未知
+func unixTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
@@ -0,0 +1,96 @@
+// Package finetune 将访客好评的对话导出为微调数据集
+// 输出格式为OpenAI chat微调使用的JSONL，每行一条样本
+package finetune
+
+import (
+	"encoding/json"
+	"io"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+)
+
+// Example 一条微调样本，第一条为系统提示，最后一条为好评的回复
+type Example struct {
+	Messages []ai.ChatMessage `json:"messages"`
+}
+
+// BuildExamples 从一个对话中提取指定角色的微调样本
+// messages为对话的全部消息（包括其他分支，按ID升序）
+// 每条好评回复与其之前的对话组成一条样本；好评回复之后还有好评回复时只保留更长的样本；
+// 样本中有差评回复、导入的消息、未完成（失败、取消）的消息或命中审核和泄露检查的消息时整条丢弃。
+// 工具调用的中间过程不导出，图片用占位文本代替
+func BuildExamples(systemPrompt string, characterID uint, messages []models.ChatMessage) []Example {
+	tree := models.NewMessageTree(messages)
+
+	var liked []models.ChatMessage
+	for _, msg := range messages {
+		if msg.MessageType == "assistant" && msg.CharacterID == characterID && msg.Rating == models.RatingUp && !msg.Imported {
+			liked = append(liked, msg)
+		}
+	}
+
+	var examples []Example
+	for _, msg := range liked {
+		if hasLikedDescendant(tree, msg.ID, liked) {
+			continue
+		}
+		if example, ok := buildExample(systemPrompt, tree.Path(msg.ID)); ok {
+			examples = append(examples, example)
+		}
+	}
+	return examples
+}
+
+// hasLikedDescendant 判断消息之后的对话中是否还有好评回复
+func hasLikedDescendant(tree *models.MessageTree, id uint, liked []models.ChatMessage) bool {
+	for _, other := range liked {
+		if tree.IsAncestor(id, other.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildExample 将对话路径转换为样本，路径中有不能用于训练的消息或没有用户消息时返回false
+func buildExample(systemPrompt string, path []models.ChatMessage) (Example, bool) {
+	messages := []ai.ChatMessage{{Role: "system", Content: systemPrompt}}
+	hasUser := false
+	for _, msg := range path {
+		if !trainable(msg) {
+			return Example{}, false
+		}
+		if !msg.Visible() {
+			continue
+		}
+		if msg.MessageType == "user" {
+			hasUser = true
+		}
+		messages = append(messages, ai.ChatMessage{Role: msg.MessageType, Content: msg.TextContent()})
+	}
+	if !hasUser {
+		return Example{}, false
+	}
+	return Example{Messages: messages}, true
+}
+
+// trainable 消息能否出现在微调样本中
+// 差评回复、访客导入的消息、没有正常完成的消息，以及命中审核（包括只遮盖）或泄露检查被替换的回复都不能用于训练
+func trainable(msg models.ChatMessage) bool {
+	return msg.Rating != models.RatingDown &&
+		!msg.Imported &&
+		msg.Status == models.MessageStatusCompleted &&
+		!msg.Flagged &&
+		msg.ModerationReason == ""
+}
+
+// WriteJSONL 将样本逐行写入w
+func WriteJSONL(w io.Writer, examples []Example) error {
+	encoder := json.NewEncoder(w)
+	for _, example := range examples {
+		if err := encoder.Encode(example); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package finetune
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"personal-website/internal/models"
+)
+
+// msg 创建测试用的聊天记录
+func msg(id, parentID uint, role, content string, rating int) models.ChatMessage {
+	return models.ChatMessage{ID: id, ParentID: parentID, CharacterID: 1, MessageType: role, Content: content, Rating: rating, Status: models.MessageStatusCompleted}
+}
+
+// 测试样本提取：只保留最长的好评路径，跳过差评分支
+func TestBuildExamples(t *testing.T) {
+	messages := []models.ChatMessage{
+		msg(1, 0, "user", "你好", 0),
+		msg(2, 1, "assistant", "你好呀", models.RatingUp),
+		msg(3, 2, "user", "讲个笑话", 0),
+		msg(4, 3, "assistant", "不好笑的笑话", models.RatingDown),
+		msg(5, 3, "assistant", "好笑的笑话", models.RatingUp),
+		msg(6, 5, "user", "再来一个", 0),
+		msg(7, 6, "assistant", "第二个笑话", models.RatingUp),
+		msg(8, 4, "user", "不好笑", 0),
+		msg(9, 8, "assistant", "抱歉", models.RatingUp),
+	}
+
+	examples := BuildExamples("你是莫诺", 1, messages)
+	if len(examples) != 1 {
+		t.Fatalf("Expected 1 example, got %d", len(examples))
+	}
+
+	got := examples[0].Messages
+	if len(got) != 7 {
+		t.Fatalf("Expected 7 messages, got %d", len(got))
+	}
+	if got[0].Role != "system" || got[0].Content != "你是莫诺" {
+		t.Errorf("Expected system prompt first, got %+v", got[0])
+	}
+	if got[4].Content != "好笑的笑话" || got[6].Content != "第二个笑话" {
+		t.Errorf("Unexpected path: %+v", got)
+	}
+}
+
+// 测试其他角色的回复和工具调用过程不导出
+func TestBuildExamples_Filter(t *testing.T) {
+	tool := msg(2, 1, "tool", "", 0)
+	tool.ToolCallID = "call_1"
+	other := msg(4, 3, "assistant", "别的角色", models.RatingUp)
+	other.CharacterID = 2
+	messages := []models.ChatMessage{
+		msg(1, 0, "user", "最新文章", 0),
+		tool,
+		msg(3, 2, "assistant", "最新文章是……", models.RatingUp),
+		other,
+	}
+
+	examples := BuildExamples("prompt", 1, messages)
+	if len(examples) != 1 || len(examples[0].Messages) != 3 {
+		t.Fatalf("Expected 1 example with 3 messages, got %+v", examples)
+	}
+}
+
+// 测试导入的、未完成的和命中审核的消息所在的路径不导出
+func TestBuildExamples_Untrainable(t *testing.T) {
+	imported := msg(2, 1, "assistant", "编造的回复", models.RatingUp)
+	imported.Imported = true
+	cancelled := msg(4, 3, "assistant", "说到一半", models.RatingUp)
+	cancelled.Status = models.MessageStatusCancelled
+	flagged := msg(6, 5, "assistant", "拒绝话术", models.RatingUp)
+	flagged.Flagged = true
+	masked := msg(8, 7, "assistant", "**的回复", models.RatingUp)
+	masked.ModerationReason = "keyword:profanity"
+	importedUser := msg(10, 0, "user", "导入的问题", 0)
+	importedUser.Imported = true
+
+	messages := []models.ChatMessage{
+		msg(1, 0, "user", "a", 0), imported,
+		msg(3, 0, "user", "b", 0), cancelled,
+		msg(5, 0, "user", "c", 0), flagged,
+		msg(7, 0, "user", "d", 0), masked,
+		importedUser, msg(11, 10, "assistant", "真实的回复", models.RatingUp),
+	}
+	if examples := BuildExamples("prompt", 1, messages); len(examples) != 0 {
+		t.Errorf("Expected no examples, got %+v", examples)
+	}
+}
+
+// 测试JSONL输出：每行一条样本
+func TestWriteJSONL(t *testing.T) {
+	examples := BuildExamples("角色", 1, []models.ChatMessage{
+		msg(1, 0, "user", "a", 0),
+		msg(2, 1, "assistant", "b", models.RatingUp),
+	})
+
+	var buf bytes.Buffer
+	if err := WriteJSONL(&buf, examples); err != nil {
+		t.Fatalf("WriteJSONL failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(lines))
+	}
+	expected := `{"messages":[{"role":"system","content":"角色"},{"role":"user","content":"a"},{"role":"assistant","content":"b"}]}`
+	if lines[0] != expected {
+		t.Errorf("Expected %s, got %s", expected, lines[0])
+	}
+}
@@ -0,0 +1,91 @@
+package guard
+
+import "unicode"
+
+// 泄露检测参数
+const (
+	// shingleRunes 比较时使用的连续字符片段长度，短于此长度的重复视为巧合
+	shingleRunes = 10
+	// leakMinRunes 系统提示被照搬多少个字符视为泄露，系统提示较短时为其一半
+	leakMinRunes = 40
+)
+
+// LeakDetector 检查回复是否照搬了系统提示
+// 比较时忽略空白、标点和大小写，避免模型改动格式后绕过检测
+type LeakDetector struct {
+	prompt    []rune
+	threshold int
+}
+
+// NewLeakDetector 根据系统提示创建检测器，系统提示过短时返回nil，nil检测器不会判定泄露
+func NewLeakDetector(systemPrompt string) *LeakDetector {
+	prompt := normalizeLeakText(systemPrompt)
+	if len(prompt) < 2*shingleRunes {
+		return nil
+	}
+	threshold := leakMinRunes
+	if half := len(prompt) / 2; half < threshold {
+		threshold = half
+	}
+	return &LeakDetector{prompt: prompt, threshold: threshold}
+}
+
+// Overlap 回复中照搬的系统提示字符数
+// 将回复切分为固定长度的片段，统计系统提示中被这些片段覆盖的字符数
+func (d *LeakDetector) Overlap(reply string) int {
+	if d == nil {
+		return 0
+	}
+	text := normalizeLeakText(reply)
+	if len(text) < shingleRunes {
+		return 0
+	}
+
+	shingles := make(map[string]struct{}, len(text))
+	for i := 0; i+shingleRunes <= len(text); i++ {
+		shingles[string(text[i:i+shingleRunes])] = struct{}{}
+	}
+
+	covered := 0
+	end := 0 // 已覆盖区间的结束位置
+	for i := 0; i+shingleRunes <= len(d.prompt); i++ {
+		if _, ok := shingles[string(d.prompt[i:i+shingleRunes])]; !ok {
+			continue
+		}
+		start := i
+		if start < end {
+			start = end
+		}
+		covered += i + shingleRunes - start
+		end = i + shingleRunes
+	}
+	return covered
+}
+
+// Leaked 回复是否泄露了系统提示
+func (d *LeakDetector) Leaked(reply string) bool {
+	if d == nil {
+		return false
+	}
+	return d.Overlap(reply) >= d.threshold
+}
+
+// Threshold 判定泄露需要照搬的字符数，nil检测器为0
+func (d *LeakDetector) Threshold() int {
+	if d == nil {
+		return 0
+	}
+	return d.threshold
+}
+
+// normalizeLeakText 只保留字母和数字，统一大小写和全半角
+func normalizeLeakText(text string) []rune {
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		r = normalizeRune(r)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
@@ -0,0 +1,188 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeywordRule 关键词规则，一个分类下的一组词
+type KeywordRule struct {
+	Category string
+	Action   Action
+	Words    []string
+}
+
+// keyword 自动机中的一个词
+type keyword struct {
+	runes    int
+	category string
+	action   Action
+	// 词的首尾是否为英文字母或数字，是时要求命中位置处于单词边界，避免 ass 命中 class
+	wordStart, wordEnd bool
+}
+
+// acNode 自动机的状态
+// outputs包含沿失败链可达的全部词，匹配时不需要再沿失败链查找
+type acNode struct {
+	next    map[rune]int
+	fail    int
+	outputs []int
+}
+
+// KeywordChecker 基于Aho-Corasick自动机（DFA）的关键词检查器
+// 一次扫描即可匹配全部词；匹配前统一转为小写并将全角字母数字转为半角，中英文词可以混合
+type KeywordChecker struct {
+	nodes    []acNode
+	keywords []keyword
+}
+
+// NewKeywordChecker 根据关键词规则构建检查器，没有任何词时返回nil
+func NewKeywordChecker(rules []KeywordRule) *KeywordChecker {
+	k := &KeywordChecker{nodes: []acNode{{next: map[rune]int{}}}}
+	for _, rule := range rules {
+		for _, word := range rule.Words {
+			k.add(word, rule.Category, rule.Action)
+		}
+	}
+	if len(k.keywords) == 0 {
+		return nil
+	}
+	k.build()
+	return k
+}
+
+// add 将一个词加入字典树
+func (k *KeywordChecker) add(word, category string, action Action) {
+	runes := []rune(strings.TrimSpace(word))
+	if len(runes) == 0 {
+		return
+	}
+
+	state := 0
+	for _, r := range runes {
+		r = normalizeRune(r)
+		next, ok := k.nodes[state].next[r]
+		if !ok {
+			next = len(k.nodes)
+			k.nodes = append(k.nodes, acNode{next: map[rune]int{}})
+			k.nodes[state].next[r] = next
+		}
+		state = next
+	}
+
+	k.nodes[state].outputs = append(k.nodes[state].outputs, len(k.keywords))
+	k.keywords = append(k.keywords, keyword{
+		runes:     len(runes),
+		category:  category,
+		action:    action,
+		wordStart: isWordRune(runes[0]),
+		wordEnd:   isWordRune(runes[len(runes)-1]),
+	})
+}
+
+// build 按层次遍历计算失败指针，并把失败链上的词合并到每个状态
+func (k *KeywordChecker) build() {
+	queue := make([]int, 0, len(k.nodes))
+	for _, child := range k.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range k.nodes[state].next {
+			fail := k.nodes[state].fail
+			for fail > 0 {
+				if _, ok := k.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = k.nodes[fail].fail
+			}
+			if next, ok := k.nodes[fail].next[r]; ok && next != child {
+				fail = next
+			} else {
+				fail = 0
+			}
+			k.nodes[child].fail = fail
+			k.nodes[child].outputs = append(k.nodes[child].outputs, k.nodes[fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// longest 最长的词的字符数
+func (k *KeywordChecker) longest() int {
+	longest := 0
+	for _, word := range k.keywords {
+		longest = max(longest, word.runes)
+	}
+	return longest
+}
+
+// Name 检查器名称
+func (k *KeywordChecker) Name() string {
+	return "keyword"
+}
+
+// Check 扫描文本，返回全部命中的词
+func (k *KeywordChecker) Check(_ context.Context, text string) ([]Match, error) {
+	// offsets[i]为第i个字符在原文中的字节位置，最后一项为原文长度
+	offsets := make([]int, 0, len(text)+1)
+	runes := make([]rune, 0, len(text))
+	for i, r := range text {
+		offsets = append(offsets, i)
+		runes = append(runes, r)
+	}
+	offsets = append(offsets, len(text))
+
+	var matches []Match
+	state := 0
+	for i, r := range runes {
+		r = normalizeRune(r)
+		for state > 0 {
+			if _, ok := k.nodes[state].next[r]; ok {
+				break
+			}
+			state = k.nodes[state].fail
+		}
+		if next, ok := k.nodes[state].next[r]; ok {
+			state = next
+		}
+
+		for _, index := range k.nodes[state].outputs {
+			word := k.keywords[index]
+			start := i - word.runes + 1
+			if word.wordStart && start > 0 && isWordRune(runes[start-1]) {
+				continue
+			}
+			if word.wordEnd && i+1 < len(runes) && isWordRune(runes[i+1]) {
+				continue
+			}
+			matches = append(matches, Match{
+				Checker:  k.Name(),
+				Category: word.category,
+				Action:   word.action,
+				Start:    offsets[start],
+				End:      offsets[i+1],
+				Text:     text[offsets[start]:offsets[i+1]],
+			})
+		}
+	}
+	return matches, nil
+}
+
+// normalizeRune 统一大小写和全半角
+func normalizeRune(r rune) rune {
+	// 全角ASCII字符（！到～）转为半角
+	if r >= 0xFF01 && r <= 0xFF5E {
+		r -= 0xFEE0
+	}
+	return unicode.ToLower(r)
+}
+
+// isWordRune 是否为英文字母或数字（包括全角）
+func isWordRune(r rune) bool {
+	r = normalizeRune(r)
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
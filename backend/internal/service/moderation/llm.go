@@ -0,0 +1,103 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"personal-website/internal/service/ai"
+)
+
+// llmInstruction 大模型分类器的指令
+const llmInstruction = `你是内容安全审核员。判断用户提供的文本是否包含以下不当内容：色情、暴力、违法犯罪、仇恨歧视、政治敏感、自我伤害、诈骗广告。
+只输出一个JSON对象，不要输出其他内容，格式为：{"flagged": true或false, "category": "命中的分类，没有命中时为空"}`
+
+// llmMaxInputRunes 发送给大模型审核的最大字符数，超出部分截断
+const llmMaxInputRunes = 4000
+
+// LLMChecker 基于大模型的分类检查器
+// 比关键词和正则慢且有成本，适合作为兜底；命中时整段文本按action处理
+type LLMChecker struct {
+	manager  *ai.AIManager
+	provider string
+	action   Action
+}
+
+// NewLLMChecker 创建大模型分类检查器，provider为空时返回nil
+// action为命中后的处理方式，mask对整段文本没有意义，按flag处理
+func NewLLMChecker(manager *ai.AIManager, provider string, action Action) *LLMChecker {
+	if provider == "" {
+		return nil
+	}
+	if action == ActionMask {
+		action = ActionFlag
+	}
+	return &LLMChecker{manager: manager, provider: provider, action: action}
+}
+
+// Name 检查器名称
+func (c *LLMChecker) Name() string {
+	return "llm"
+}
+
+// Check 让大模型判断文本是否不当
+func (c *LLMChecker) Check(ctx context.Context, text string) ([]Match, error) {
+	// 保留调用方的会话等归属信息，只修改用途
+	labels := ai.UsageLabelsFrom(ctx)
+	labels.Purpose = ai.PurposeModeration
+	ctx = ai.WithUsageLabels(ctx, labels)
+	resp, err := c.manager.ChatCompletion(ctx, c.provider, &ai.ChatCompletionRequest{
+		Messages: []ai.ChatMessage{
+			{Role: "system", Content: llmInstruction},
+			{Role: "user", Content: ai.TruncateRunes(text, llmMaxInputRunes)},
+		},
+		MaxTokens: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("审核响应为空")
+	}
+
+	verdict, err := parseVerdict(resp.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	if !verdict.Flagged {
+		return nil, nil
+	}
+	category := verdict.Category
+	if category == "" {
+		category = "unknown"
+	}
+	return []Match{{
+		Checker:  c.Name(),
+		Category: category,
+		Action:   c.action,
+		Start:    0,
+		End:      len(text),
+		Text:     text,
+	}}, nil
+}
+
+// llmVerdict 大模型的审核结论
+type llmVerdict struct {
+	Flagged  bool   `json:"flagged"`
+	Category string `json:"category"`
+}
+
+// parseVerdict 解析大模型输出的JSON，兼容包在代码块或附带说明文字的输出
+func parseVerdict(content string) (llmVerdict, error) {
+	var verdict llmVerdict
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return verdict, fmt.Errorf("无法解析审核结果: %s", ai.TruncateRunes(content, 100))
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return verdict, fmt.Errorf("无法解析审核结果: %w", err)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,183 @@
+// Package moderation 对聊天的输入和输出进行内容审核
+// 审核由多个检查器组成：关键词（DFA）、正则和可选的大模型分类，每条规则指定命中后的处理方式
+package moderation
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Action 规则命中后的处理方式
+type Action string
+
+const (
+	// ActionFlag 放行并标记，等待管理员复核
+	ActionFlag Action = "flag"
+	// ActionMask 将命中的内容替换为*后放行
+	ActionMask Action = "mask"
+	// ActionBlock 拒绝整条内容
+	ActionBlock Action = "block"
+)
+
+// ErrInvalidAction 不支持的处理方式
+var ErrInvalidAction = errors.New("处理方式只支持 block、mask、flag")
+
+// ParseAction 解析处理方式
+func ParseAction(value string) (Action, error) {
+	switch action := Action(strings.TrimSpace(value)); action {
+	case ActionFlag, ActionMask, ActionBlock:
+		return action, nil
+	default:
+		return "", ErrInvalidAction
+	}
+}
+
+// severity 处理方式的严重程度，多条规则命中时取最严重的
+func (a Action) severity() int {
+	switch a {
+	case ActionBlock:
+		return 3
+	case ActionMask:
+		return 2
+	case ActionFlag:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Match 一次规则命中
+// Start/End为命中内容在原文中的字节位置，大模型分类器的命中覆盖全文
+type Match struct {
+	Checker  string `json:"checker"`
+	Category string `json:"category"`
+	Action   Action `json:"action"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Text     string `json:"text"`
+}
+
+// Checker 内容检查器
+type Checker interface {
+	// Name 检查器名称，用于日志和命中记录
+	Name() string
+	// Check 检查文本，返回全部命中
+	Check(ctx context.Context, text string) ([]Match, error)
+}
+
+// Result 审核结果
+// Action为命中规则中最严重的处理方式，没有命中时为空；Text为按mask规则遮盖后的文本
+type Result struct {
+	Action  Action
+	Matches []Match
+	Text    string
+}
+
+// Blocked 内容是否被拒绝
+func (r Result) Blocked() bool {
+	return r.Action == ActionBlock
+}
+
+// Flagged 内容是否需要复核，任何一条flag规则命中都需要复核
+func (r Result) Flagged() bool {
+	for _, match := range r.Matches {
+		if match.Action == ActionFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// Reason 命中原因，格式为 检查器:分类，多个原因用逗号分隔
+func (r Result) Reason() string {
+	seen := make(map[string]bool)
+	reasons := make([]string, 0, len(r.Matches))
+	for _, match := range r.Matches {
+		reason := match.Checker + ":" + match.Category
+		if !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	return strings.Join(reasons, ",")
+}
+
+// Pipeline 按顺序执行多个检查器
+// 某个检查器出错时记录日志并跳过，不影响其他检查器；已经命中block规则时不再执行之后的检查器
+type Pipeline struct {
+	checkers []Checker
+}
+
+// NewPipeline 创建审核流程，nil检查器会被忽略
+func NewPipeline(checkers ...Checker) *Pipeline {
+	p := &Pipeline{}
+	for _, checker := range checkers {
+		if checker != nil {
+			p.checkers = append(p.checkers, checker)
+		}
+	}
+	return p
+}
+
+// Check 审核文本
+func (p *Pipeline) Check(ctx context.Context, text string) Result {
+	result := Result{Text: text}
+	if p == nil || strings.TrimSpace(text) == "" {
+		return result
+	}
+
+	for _, checker := range p.checkers {
+		matches, err := checker.Check(ctx, text)
+		if err != nil {
+			log.Printf("[Moderation] 检查器 %s 执行失败，跳过: %v", checker.Name(), err)
+			continue
+		}
+		for _, match := range matches {
+			if match.Action.severity() > result.Action.severity() {
+				result.Action = match.Action
+			}
+		}
+		result.Matches = append(result.Matches, matches...)
+		if result.Blocked() {
+			break
+		}
+	}
+
+	result.Text = mask(text, result.Matches)
+	return result
+}
+
+// mask 将mask规则命中的内容按字符替换为*
+func mask(text string, matches []Match) string {
+	var spans []Match
+	for _, match := range matches {
+		if match.Action == ActionMask && match.Start < match.End {
+			spans = append(spans, match)
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	var b strings.Builder
+	pos := 0
+	for _, span := range spans {
+		if span.End <= pos {
+			continue
+		}
+		start := span.Start
+		if start < pos {
+			start = pos
+		}
+		b.WriteString(text[pos:start])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[start:span.End])))
+		pos = span.End
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
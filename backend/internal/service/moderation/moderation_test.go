@@ -0,0 +1,184 @@
+package moderation
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"personal-website/internal/models"
+)
+
+// 测试关键词匹配：中英文混合、大小写和全角、英文单词边界
+func TestKeywordChecker(t *testing.T) {
+	checker := NewKeywordChecker([]KeywordRule{
+		{Category: "insult", Action: ActionMask, Words: []string{"傻瓜", "idiot", "ass"}},
+		{Category: "scam", Action: ActionBlock, Words: []string{"刷单返利", "刷单"}},
+	})
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"你这个傻瓜", []string{"傻瓜"}},
+		{"You IDIOT!", []string{"IDIOT"}},
+		{"ｉｄｉｏｔ", []string{"ｉｄｉｏｔ"}},
+		{"first class passenger", nil},
+		{"kiss my ass", []string{"ass"}},
+		{"加我刷单返利", []string{"刷单", "刷单返利"}},
+		{"正常的内容", nil},
+	}
+	for _, tt := range tests {
+		matches, _ := checker.Check(context.Background(), tt.text)
+		var got []string
+		for _, match := range matches {
+			got = append(got, match.Text)
+			if tt.text[match.Start:match.End] != match.Text {
+				t.Errorf("Match position mismatch in %q: %+v", tt.text, match)
+			}
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("Check(%q) = %v, expected %v", tt.text, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("Check(%q) = %v, expected %v", tt.text, got, tt.expected)
+				break
+			}
+		}
+	}
+
+	if NewKeywordChecker(nil) != nil {
+		t.Error("Expected nil checker without words")
+	}
+}
+
+// 测试审核流程：取最严重的处理方式，按字符遮盖mask规则命中的内容
+func TestPipeline(t *testing.T) {
+	pipeline := NewPipeline(
+		NewKeywordChecker([]KeywordRule{
+			{Category: "insult", Action: ActionMask, Words: []string{"傻瓜"}},
+			{Category: "politics", Action: ActionFlag, Words: []string{"选举"}},
+			{Category: "scam", Action: ActionBlock, Words: []string{"刷单"}},
+		}),
+		NewRegexChecker([]RegexRule{
+			{Category: "phone", Action: ActionMask, Pattern: regexp.MustCompile(`1[3-9]\d{9}`)},
+		}),
+	)
+
+	result := pipeline.Check(context.Background(), "傻瓜，打13812345678")
+	if result.Action != ActionMask || result.Blocked() || result.Flagged() {
+		t.Errorf("Expected mask, got %+v", result)
+	}
+	if result.Text != "**，打***********" {
+		t.Errorf("Unexpected masked text: %q", result.Text)
+	}
+
+	result = pipeline.Check(context.Background(), "聊聊选举，你这傻瓜")
+	if result.Action != ActionMask || !result.Flagged() {
+		t.Errorf("Expected mask with flag, got %+v", result)
+	}
+	if result.Reason() != "keyword:politics,keyword:insult" {
+		t.Errorf("Unexpected reason: %q", result.Reason())
+	}
+
+	result = pipeline.Check(context.Background(), "刷单赚钱")
+	if !result.Blocked() {
+		t.Errorf("Expected block, got %+v", result)
+	}
+
+	result = pipeline.Check(context.Background(), "你好")
+	if result.Action != "" || result.Text != "你好" {
+		t.Errorf("Expected clean result, got %+v", result)
+	}
+}
+
+// 测试流式审核：末尾保留内容，遮盖后放行，命中block规则时停止放行
+func TestStream(t *testing.T) {
+	checker := NewKeywordChecker([]KeywordRule{
+		{Category: "insult", Action: ActionMask, Words: []string{"傻瓜"}},
+		{Category: "insult", Action: ActionBlock, Words: []string{"ass"}},
+		{Category: "scam", Action: ActionBlock, Words: []string{"刷单返利"}},
+	})
+	ctx := context.Background()
+	newStream := func() *Stream {
+		return &Stream{pipeline: NewPipeline(checker), window: checker.longest()}
+	}
+
+	st := newStream()
+	var released string
+	for _, delta := range []string{"你这个傻", "瓜，", "我们来上", "一节课"} {
+		text, ok := st.Feed(ctx, delta)
+		if !ok {
+			t.Fatalf("Unexpected block at %q", delta)
+		}
+		released += text
+	}
+	if released != "你这个**，我们来" {
+		t.Errorf("Unexpected released text: %q", released)
+	}
+
+	// 结尾的ass可能是asset的一部分，等下一段内容再判断
+	st = newStream()
+	if _, ok := st.Feed(ctx, "first ass"); !ok {
+		t.Error("Expected no block for a match at the end")
+	}
+	if _, ok := st.Feed(ctx, "et class"); !ok {
+		t.Error("Expected no block for asset")
+	}
+
+	st = newStream()
+	released = ""
+	blocked := false
+	for _, delta := range []string{"加我", "刷单", "返利", "，日赚百元"} {
+		text, ok := st.Feed(ctx, delta)
+		released += text
+		if !ok {
+			blocked = true
+			break
+		}
+	}
+	if !blocked || !st.Result().Blocked() {
+		t.Error("Expected block")
+	}
+	if released != "加我" {
+		t.Errorf("Expected only the text before the match released, got %q", released)
+	}
+
+	var nilStream *Stream
+	if text, ok := nilStream.Feed(ctx, "刷单返利"); !ok || text != "刷单返利" {
+		t.Errorf("Expected nil stream to pass through, got %q", text)
+	}
+}
+
+// 测试大模型审核结果的解析
+func TestParseVerdict(t *testing.T) {
+	verdict, err := parseVerdict("```json\n{\"flagged\": true, \"category\": \"诈骗广告\"}\n```")
+	if err != nil || !verdict.Flagged || verdict.Category != "诈骗广告" {
+		t.Errorf("Unexpected verdict: %+v, %v", verdict, err)
+	}
+	if _, err := parseVerdict("没有问题"); err == nil {
+		t.Error("Expected error for non-JSON output")
+	}
+}
+
+// 测试数据库规则的校验
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		rule    models.ModerationRule
+		wantErr bool
+	}{
+		{models.ModerationRule{Kind: models.ModerationKeyword, Category: "c", Action: "mask", Pattern: "a\n\nb"}, false},
+		{models.ModerationRule{Kind: models.ModerationKeyword, Category: "c", Action: "mask", Pattern: " \n "}, true},
+		{models.ModerationRule{Kind: models.ModerationRegex, Category: "c", Action: "flag", Pattern: `\d+`}, false},
+		{models.ModerationRule{Kind: models.ModerationRegex, Category: "c", Action: "flag", Pattern: `\d*`}, true},
+		{models.ModerationRule{Kind: models.ModerationRegex, Category: "c", Action: "flag", Pattern: `(`}, true},
+		{models.ModerationRule{Kind: models.ModerationKeyword, Category: "c", Action: "delete", Pattern: "a"}, true},
+		{models.ModerationRule{Kind: "llm", Category: "c", Action: "flag", Pattern: "a"}, true},
+	}
+	for i, tt := range tests {
+		if err := ValidateRule(tt.rule); (err != nil) != tt.wantErr {
+			t.Errorf("Case %d: expected error=%v, got %v", i, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package moderation
+
+import (
+	"context"
+	"regexp"
+)
+
+// RegexRule 正则规则
+type RegexRule struct {
+	Category string
+	Action   Action
+	Pattern  *regexp.Regexp
+}
+
+// RegexChecker 正则检查器，适合手机号、证件号、链接等有固定格式的内容
+type RegexChecker struct {
+	rules []RegexRule
+}
+
+// NewRegexChecker 创建正则检查器，没有规则时返回nil
+func NewRegexChecker(rules []RegexRule) *RegexChecker {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &RegexChecker{rules: rules}
+}
+
+// Name 检查器名称
+func (c *RegexChecker) Name() string {
+	return "regex"
+}
+
+// Check 依次匹配每条规则，返回全部命中
+func (c *RegexChecker) Check(_ context.Context, text string) ([]Match, error) {
+	var matches []Match
+	for _, rule := range c.rules {
+		for _, loc := range rule.Pattern.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			matches = append(matches, Match{
+				Checker:  c.Name(),
+				Category: rule.Category,
+				Action:   rule.Action,
+				Start:    loc[0],
+				End:      loc[1],
+				Text:     text[loc[0]:loc[1]],
+			})
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,158 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+
+	"gorm.io/gorm"
+)
+
+// Service 内容审核服务，规则保存在数据库中，修改后调用Reload生效
+// 所有方法都可以在nil接收者上调用，nil服务不审核任何内容
+type Service struct {
+	db  *gorm.DB
+	llm *LLMChecker
+
+	mu       sync.RWMutex
+	pipeline *Pipeline
+	// stream 流式输出和批量导入使用的关键词和正则检查器，streamWindow为流式输出需要保留的字符数
+	stream       *Pipeline
+	streamWindow int
+}
+
+// NewService 创建审核服务并加载规则
+// llmProvider为大模型分类使用的Provider名称，为空时不启用大模型分类
+func NewService(db *gorm.DB, manager *ai.AIManager, llmProvider string, llmAction Action) *Service {
+	s := &Service{db: db, llm: NewLLMChecker(manager, llmProvider, llmAction)}
+	if err := s.Reload(); err != nil {
+		log.Printf("[Moderation] 加载审核规则失败: %v", err)
+	}
+	return s
+}
+
+// Reload 从数据库重新加载启用的规则，无效的规则跳过
+func (s *Service) Reload() error {
+	if s == nil {
+		return nil
+	}
+	var rules []models.ModerationRule
+	if err := s.db.Where("is_active = ?", true).Order("id").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	var keywords []KeywordRule
+	var patterns []RegexRule
+	for _, rule := range rules {
+		keyword, pattern, err := compileRule(rule)
+		if err != nil {
+			log.Printf("[Moderation] 规则 %d 无效，跳过: %v", rule.ID, err)
+			continue
+		}
+		if keyword != nil {
+			keywords = append(keywords, *keyword)
+		}
+		if pattern != nil {
+			patterns = append(patterns, *pattern)
+		}
+	}
+
+	// 按成本从低到高执行，命中block后不再调用大模型
+	var checkers []Checker
+	window := 0
+	if checker := NewKeywordChecker(keywords); checker != nil {
+		checkers = append(checkers, checker)
+		window = checker.longest()
+	}
+	if checker := NewRegexChecker(patterns); checker != nil {
+		checkers = append(checkers, checker)
+		window = max(window, streamRegexRunes)
+	}
+	var stream *Pipeline
+	if len(checkers) > 0 {
+		stream = NewPipeline(checkers...)
+	}
+	if s.llm != nil {
+		checkers = append(checkers, s.llm)
+	}
+
+	s.mu.Lock()
+	s.pipeline = NewPipeline(checkers...)
+	s.stream, s.streamWindow = stream, window
+	s.mu.Unlock()
+
+	log.Printf("[Moderation] 加载了 %d 条审核规则", len(keywords)+len(patterns))
+	return nil
+}
+
+// Check 审核文本，没有配置任何检查器时原样放行
+func (s *Service) Check(ctx context.Context, text string) Result {
+	if s == nil {
+		return Result{Text: text}
+	}
+	s.mu.RLock()
+	pipeline := s.pipeline
+	s.mu.RUnlock()
+	return pipeline.Check(ctx, text)
+}
+
+// CheckRules 只按关键词和正则规则审核，用于批量导入等不适合逐条调用大模型的场景
+func (s *Service) CheckRules(ctx context.Context, text string) Result {
+	if s == nil {
+		return Result{Text: text}
+	}
+	s.mu.RLock()
+	pipeline := s.stream
+	s.mu.RUnlock()
+	return pipeline.Check(ctx, text)
+}
+
+// ValidateRule 校验规则，保存前调用
+func ValidateRule(rule models.ModerationRule) error {
+	_, _, err := compileRule(rule)
+	return err
+}
+
+// compileRule 将数据库中的规则转换为关键词规则或正则规则
+func compileRule(rule models.ModerationRule) (*KeywordRule, *RegexRule, error) {
+	action, err := ParseAction(rule.Action)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.TrimSpace(rule.Category) == "" {
+		return nil, nil, fmt.Errorf("分类不能为空")
+	}
+
+	switch rule.Kind {
+	case models.ModerationKeyword:
+		var words []string
+		for _, line := range strings.Split(rule.Pattern, "\n") {
+			if word := strings.TrimSpace(line); word != "" {
+				words = append(words, word)
+			}
+		}
+		if len(words) == 0 {
+			return nil, nil, fmt.Errorf("词表不能为空")
+		}
+		return &KeywordRule{Category: rule.Category, Action: action, Words: words}, nil, nil
+
+	case models.ModerationRegex:
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("正则表达式错误: %w", err)
+		}
+		if pattern.MatchString("") {
+			return nil, nil, fmt.Errorf("正则表达式不能匹配空字符串")
+		}
+		return nil, &RegexRule{Category: rule.Category, Action: action, Pattern: pattern}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("规则类型只支持 %s、%s", models.ModerationKeyword, models.ModerationRegex)
+	}
+}
@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+)
+
+// streamRegexRunes 流式审核时为正则规则保留的字符数，正则的命中长度无法预知，按常见的手机号、证件号、链接取值
+const streamRegexRunes = 32
+
+// Stream 流式输出的增量审核，只使用关键词和正则检查器，大模型分类在输出结束后执行
+// 末尾保留最长规则长度的内容暂不放行，保证命中的内容在放行之前已经完整出现；
+// 命中mask规则的内容遮盖后放行，命中block规则时停止放行
+// nil的Stream不审核，增量内容原样放行
+type Stream struct {
+	pipeline *Pipeline
+	window   int
+
+	content  strings.Builder
+	released int
+	result   Result
+}
+
+// Stream 创建一次流式输出的增量审核，没有关键词和正则规则时返回nil
+func (s *Service) Stream() *Stream {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	pipeline, window := s.stream, s.streamWindow
+	s.mu.RUnlock()
+	if pipeline == nil {
+		return nil
+	}
+	return &Stream{pipeline: pipeline, window: window}
+}
+
+// Feed 追加增量内容，返回可以放行的内容；命中block规则时返回false，此后不应再输出任何内容
+// 结尾处的命中可能还不完整（如 ass 之后是 et），等下一段内容再判断是否拒绝
+func (st *Stream) Feed(ctx context.Context, delta string) (string, bool) {
+	if st == nil {
+		return delta, true
+	}
+	st.content.WriteString(delta)
+	text := st.content.String()
+	st.result = st.pipeline.Check(ctx, text)
+	for _, match := range st.result.Matches {
+		if match.Action == ActionBlock && match.End < len(text) {
+			return "", false
+		}
+	}
+
+	// 遮盖按字符替换，遮盖后的字符位置与原文一致
+	runes := []rune(st.result.Text)
+	end := len(runes) - st.window
+	if end <= st.released {
+		return "", true
+	}
+	released := string(runes[st.released:end])
+	st.released = end
+	return released, true
+}
+
+// Result 最近一次审核的结果
+func (st *Stream) Result() Result {
+	if st == nil {
+		return Result{}
+	}
+	return st.result
+}
@@ -0,0 +1,251 @@
+// Package prompt 渲染AI角色的系统提示模板
+// 系统提示使用 text/template 语法，可引用当前时间、访客称呼、站长名称以及最新文章和精选项目等实时数据
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"personal-website/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultSiteOwner 未配置SITE_OWNER时的站长称呼
+	defaultSiteOwner = "站长"
+	// defaultVisitorName 访客未提供称呼时使用的名称
+	defaultVisitorName = "访客"
+	// maxVisitorNameRunes 访客称呼的最大字符数
+	maxVisitorNameRunes = 20
+	// latestArticlesLimit 模板中最新文章的数量
+	latestArticlesLimit = 5
+	// featuredProjectsLimit 模板中精选项目的数量
+	featuredProjectsLimit = 10
+	// dataCacheDuration 文章和项目数据的缓存时间
+	dataCacheDuration = time.Minute
+)
+
+// ArticleRef 模板中的文章信息
+type ArticleRef struct {
+	ID      uint
+	Title   string
+	Summary string
+}
+
+// ArticleList 文章列表，直接输出时每行一篇
+type ArticleList []ArticleRef
+
+// String 格式化为每行一篇的列表
+func (l ArticleList) String() string {
+	if len(l) == 0 {
+		return "（暂无文章）"
+	}
+	lines := make([]string, len(l))
+	for i, a := range l {
+		lines[i] = fmt.Sprintf("- 《%s》(文章ID: %d)", a.Title, a.ID)
+		if a.Summary != "" {
+			lines[i] += "：" + a.Summary
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ProjectRef 模板中的项目信息
+type ProjectRef struct {
+	Name         string
+	Description  string
+	Technologies []string
+	URL          string
+}
+
+// ProjectList 项目列表，直接输出时每行一个
+type ProjectList []ProjectRef
+
+// String 格式化为每行一个的列表
+func (l ProjectList) String() string {
+	if len(l) == 0 {
+		return "（暂无项目）"
+	}
+	lines := make([]string, len(l))
+	for i, p := range l {
+		lines[i] = "- " + p.Name
+		if len(p.Technologies) > 0 {
+			lines[i] += "（" + strings.Join(p.Technologies, "、") + "）"
+		}
+		if p.Description != "" {
+			lines[i] += "：" + p.Description
+		}
+		if p.URL != "" {
+			lines[i] += " " + p.URL
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Vars 系统提示模板可用的变量
+type Vars struct {
+	Now              string
+	VisitorName      string
+	SiteOwner        string
+	LatestArticles   ArticleList
+	FeaturedProjects ProjectList
+}
+
+// sampleVars 校验模板时使用的示例数据
+var sampleVars = Vars{
+	Now:              "2024年01月01日 12:00 星期一",
+	VisitorName:      defaultVisitorName,
+	SiteOwner:        defaultSiteOwner,
+	LatestArticles:   ArticleList{{ID: 1, Title: "示例文章", Summary: "摘要"}},
+	FeaturedProjects: ProjectList{{Name: "示例项目", Technologies: []string{"Go"}}},
+}
+
+// hasTemplate 判断文本是否包含模板语法，不包含时无需解析和查询数据
+func hasTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// parse 解析模板
+func parse(text string) (*template.Template, error) {
+	return template.New("system_prompt").Parse(text)
+}
+
+// Validate 校验系统提示模板
+// 除语法错误外，用示例数据执行一次，提前发现引用了不存在的变量等运行时错误
+func Validate(text string) error {
+	if !hasTemplate(text) {
+		return nil
+	}
+	tmpl, err := parse(text)
+	if err != nil {
+		return fmt.Errorf("系统提示模板语法错误: %w", err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, sampleVars); err != nil {
+		return fmt.Errorf("系统提示模板执行错误: %w", err)
+	}
+	return nil
+}
+
+// Render 使用给定变量渲染系统提示
+func Render(text string, vars Vars) (string, error) {
+	if !hasTemplate(text) {
+		return text, nil
+	}
+	tmpl, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// FormatNow 格式化当前时间，包含星期以便模型理解“今天”“周末”等说法
+func FormatNow(now time.Time) string {
+	weekdays := [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+	return now.Format("2006年01月02日 15:04 ") + weekdays[now.Weekday()]
+}
+
+// SanitizeVisitorName 清理访客提供的称呼
+// 称呼会进入系统提示，去除换行和模板符号，并限制长度，避免被用来注入指令
+func SanitizeVisitorName(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.NewReplacer("{", "", "}", "").Replace(name)
+	if utf8.RuneCountInString(name) > maxVisitorNameRunes {
+		name = string([]rune(name)[:maxVisitorNameRunes])
+	}
+	if name == "" {
+		return defaultVisitorName
+	}
+	return name
+}
+
+// Renderer 从数据库加载实时数据并渲染系统提示
+// 文章和项目数据短时间缓存，避免每次聊天都查询数据库
+type Renderer struct {
+	db        *gorm.DB
+	siteOwner string
+
+	mu       sync.Mutex
+	articles ArticleList
+	projects ProjectList
+	loadedAt time.Time
+}
+
+// NewRenderer 创建渲染器
+// siteOwner: 站长称呼，为空时使用默认值
+func NewRenderer(db *gorm.DB, siteOwner string) *Renderer {
+	if siteOwner == "" {
+		siteOwner = defaultSiteOwner
+	}
+	return &Renderer{db: db, siteOwner: siteOwner}
+}
+
+// Render 渲染系统提示，不包含模板语法时原样返回
+func (r *Renderer) Render(ctx context.Context, text, visitorName string) (string, error) {
+	if !hasTemplate(text) {
+		return text, nil
+	}
+	articles, projects := r.data(ctx)
+	return Render(text, Vars{
+		Now:              FormatNow(time.Now()),
+		VisitorName:      SanitizeVisitorName(visitorName),
+		SiteOwner:        r.siteOwner,
+		LatestArticles:   articles,
+		FeaturedProjects: projects,
+	})
+}
+
+// data 获取最新文章和精选项目，缓存过期时重新查询
+// 查询失败时继续使用旧数据
+func (r *Renderer) data(ctx context.Context) (ArticleList, ProjectList) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.loadedAt.IsZero() && time.Since(r.loadedAt) < dataCacheDuration {
+		return r.articles, r.projects
+	}
+
+	var articles []models.Article
+	if err := r.db.WithContext(ctx).Select("id, title, summary").
+		Where("is_published = ?", true).
+		Order("created_at DESC").Limit(latestArticlesLimit).
+		Find(&articles).Error; err != nil {
+		log.Printf("[Prompt] 查询最新文章失败: %v", err)
+		return r.articles, r.projects
+	}
+
+	var projects []models.Project
+	if err := r.db.WithContext(ctx).
+		Where("featured = ?", true).
+		Order("created_at DESC").Limit(featuredProjectsLimit).
+		Find(&projects).Error; err != nil {
+		log.Printf("[Prompt] 查询精选项目失败: %v", err)
+		return r.articles, r.projects
+	}
+
+	r.articles = make(ArticleList, len(articles))
+	for i, a := range articles {
+		r.articles[i] = ArticleRef{ID: a.ID, Title: a.Title, Summary: a.Summary}
+	}
+	r.projects = make(ProjectList, len(projects))
+	for i, p := range projects {
+		url := p.DemoURL
+		if url == "" {
+			url = p.GithubURL
+		}
+		r.projects[i] = ProjectRef{Name: p.Name, Description: p.Description, Technologies: p.Technologies, URL: url}
+	}
+	r.loadedAt = time.Now()
+	return r.articles, r.projects
+}
@@ -0,0 +1,67 @@
+package rag
+
+import "strings"
+
+// Chunk 将文本按字符数切分为带重叠的分块
+// 优先在段落边界切分，单个段落过长时按字符硬切
+// 相邻分块重叠overlap个字符（最多为size的一半），避免语义在边界处断开
+func Chunk(text string, size, overlap int) []string {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	if overlap > size/2 {
+		overlap = size / 2
+	}
+
+	var chunks []string
+	var current []rune
+	// fresh 上次输出后新加入的字符数，只有重叠部分时不再输出
+	fresh := 0
+	flush := func() {
+		if fresh > 0 {
+			chunks = append(chunks, strings.TrimSpace(string(current)))
+		}
+		if len(current) > overlap {
+			current = append([]rune(nil), current[len(current)-overlap:]...)
+		}
+		fresh = 0
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		runes := []rune(strings.TrimSpace(paragraph))
+		if len(runes) == 0 {
+			continue
+		}
+
+		// 当前块放不下这个段落时先输出当前块
+		if fresh > 0 && len(current)+1+len(runes) > size {
+			flush()
+		}
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+
+		for {
+			room := size - len(current)
+			if room <= 0 {
+				current = current[:0]
+				continue
+			}
+			if len(runes) <= room {
+				current = append(current, runes...)
+				fresh += len(runes)
+				break
+			}
+			current = append(current, runes[:room]...)
+			fresh += room
+			runes = runes[room:]
+			flush()
+		}
+	}
+
+	flush()
+	return chunks
+}
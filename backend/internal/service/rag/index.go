@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Entry 索引中的一个文章分块
+type Entry struct {
+	ArticleID uint
+	Title     string
+	Content   string
+	Vector    []float32
+}
+
+// Hit 检索命中的分块
+type Hit struct {
+	Entry
+	Score float32
+}
+
+// Index 内存向量索引
+// 向量在写入时归一化，检索时用点积计算余弦相似度；博客文章规模较小，线性扫描足够
+type Index struct {
+	mu        sync.RWMutex
+	byArticle map[uint][]Entry
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	return &Index{byArticle: make(map[uint][]Entry)}
+}
+
+// Replace 替换文章的全部分块
+func (idx *Index) Replace(articleID uint, entries []Entry) {
+	normalized := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if v := normalize(e.Vector); v != nil {
+			e.Vector = v
+			normalized = append(normalized, e)
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if len(normalized) == 0 {
+		delete(idx.byArticle, articleID)
+		return
+	}
+	idx.byArticle[articleID] = normalized
+}
+
+// Remove 移除文章的全部分块
+func (idx *Index) Remove(articleID uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.byArticle, articleID)
+}
+
+// Len 索引中的分块数
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	n := 0
+	for _, entries := range idx.byArticle {
+		n += len(entries)
+	}
+	return n
+}
+
+// Search 返回与查询向量最相似的k个分块，按相似度从高到低排列
+// 相似度低于minScore的分块不返回
+func (idx *Index) Search(query []float32, k int, minScore float32) []Hit {
+	q := normalize(query)
+	if q == nil || k <= 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	var hits []Hit
+	for _, entries := range idx.byArticle {
+		for _, e := range entries {
+			if len(e.Vector) != len(q) {
+				continue
+			}
+			if score := dot(q, e.Vector); score >= minScore {
+				hits = append(hits, Hit{Entry: e, Score: score})
+			}
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// normalize 返回归一化后的向量副本，零向量返回nil
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+// dot 计算两个向量的点积
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,102 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 测试分块：短文本一块，长段落按字符硬切并保留重叠
+func TestChunk(t *testing.T) {
+	if chunks := Chunk("第一段\n\n第二段", 100, 10); len(chunks) != 1 || chunks[0] != "第一段\n第二段" {
+		t.Errorf("Expected paragraphs merged into one chunk, got %q", chunks)
+	}
+
+	if chunks := Chunk("  \n\n ", 100, 10); len(chunks) != 0 {
+		t.Errorf("Expected no chunks for blank text, got %q", chunks)
+	}
+
+	long := strings.Repeat("字", 250)
+	chunks := Chunk(long, 100, 20)
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 100 {
+			t.Errorf("Chunk %d has %d runes, exceeds size", i, n)
+		}
+	}
+	total := 0
+	for _, chunk := range chunks {
+		total += utf8.RuneCountInString(chunk)
+	}
+	if total != 250+2*20 {
+		t.Errorf("Expected overlapped total %d, got %d", 250+2*20, total)
+	}
+}
+
+// 测试分块优先在段落边界切分
+func TestChunk_ParagraphBoundary(t *testing.T) {
+	a := strings.Repeat("a", 60)
+	b := strings.Repeat("b", 60)
+	chunks := Chunk(a+"\n\n"+b, 100, 0)
+	if len(chunks) != 2 || chunks[0] != a || chunks[1] != b {
+		t.Errorf("Expected split at paragraph boundary, got %q", chunks)
+	}
+}
+
+// 测试余弦检索：按相似度排序、过滤低分、替换和移除文章
+func TestIndex_Search(t *testing.T) {
+	idx := NewIndex()
+	idx.Replace(1, []Entry{{ArticleID: 1, Title: "Go", Content: "go", Vector: []float32{1, 0}}})
+	idx.Replace(2, []Entry{{ArticleID: 2, Title: "Rust", Content: "rust", Vector: []float32{0, 3}}})
+	idx.Replace(3, []Entry{{ArticleID: 3, Title: "Mixed", Content: "mixed", Vector: []float32{2, 2}}})
+
+	hits := idx.Search([]float32{5, 1}, 2, 0.3)
+	if len(hits) != 2 || hits[0].ArticleID != 1 || hits[1].ArticleID != 3 {
+		t.Fatalf("Unexpected hits: %+v", hits)
+	}
+	if hits[0].Score < 0.98 || hits[0].Score > 1.0001 {
+		t.Errorf("Expected cosine close to 1, got %f", hits[0].Score)
+	}
+
+	idx.Remove(1)
+	idx.Replace(3, nil)
+	if hits := idx.Search([]float32{1, 0}, 5, 0.3); len(hits) != 0 {
+		t.Errorf("Expected no hits after removal, got %+v", hits)
+	}
+	if idx.Len() != 1 {
+		t.Errorf("Expected 1 entry left, got %d", idx.Len())
+	}
+}
+
+// 测试检索结果格式化：同一文章只引用一次，编号与来源一致
+func TestResult_ContextMessage(t *testing.T) {
+	result := &Result{Hits: []Hit{
+		{Entry: Entry{ArticleID: 7, Title: "Go并发", Content: "channel"}, Score: 0.9},
+		{Entry: Entry{ArticleID: 9, Title: "Gin入门", Content: "router"}, Score: 0.8},
+		{Entry: Entry{ArticleID: 7, Title: "Go并发", Content: "select"}, Score: 0.7},
+	}}
+
+	sources := result.Sources()
+	if len(sources) != 2 || sources[0].ArticleID != 7 || sources[1].ArticleID != 9 {
+		t.Fatalf("Unexpected sources: %+v", sources)
+	}
+
+	msg, ok := result.ContextMessage()
+	if !ok || msg.Role != "system" {
+		t.Fatalf("Expected system message, got %+v", msg)
+	}
+	if strings.Count(msg.Content, "[1] 《Go并发》(文章ID: 7)") != 2 || !strings.Contains(msg.Content, "[2] 《Gin入门》(文章ID: 9)") {
+		t.Errorf("Unexpected context: %s", msg.Content)
+	}
+
+	if _, ok := (&Result{}).ContextMessage(); ok {
+		t.Error("Expected no message for empty result")
+	}
+
+	var s *Service
+	if err := s.RemoveArticle(1); err != nil {
+		t.Errorf("nil service should be no-op, got %v", err)
+	}
+}
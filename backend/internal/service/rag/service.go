@@ -0,0 +1,283 @@
+// Package rag 提供基于文章内容的检索增强
+// 已发布的文章被切分为分块并向量化后存入数据库，启动时加载到内存索引，聊天时检索相关分块注入上下文
+package rag
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+
+	"gorm.io/gorm"
+)
+
+// 分块和检索参数
+const (
+	// chunkSize 每个分块的最大字符数
+	chunkSize = 500
+	// chunkOverlap 相邻分块重叠的字符数
+	chunkOverlap = 80
+	// embedBatchSize 单次向量化请求的最大分块数
+	embedBatchSize = 16
+	// DefaultTopK 默认注入上下文的分块数
+	DefaultTopK = 4
+	// minScore 相似度下限，低于此值的分块与问题无关
+	minScore = 0.3
+)
+
+// Source 回复引用的文章，前端据此生成链接
+type Source struct {
+	ArticleID uint    `json:"article_id"`
+	Title     string  `json:"title"`
+	Score     float32 `json:"score"`
+}
+
+// Result 检索结果
+type Result struct {
+	Hits []Hit
+}
+
+// Service 文章检索服务
+// 所有方法都可以在nil接收者上调用，未配置向量Provider时RAG整体关闭
+type Service struct {
+	db       *gorm.DB
+	manager  *ai.AIManager
+	provider string
+	index    *Index
+}
+
+// NewService 创建检索服务
+// provider: 用于向量化的Provider名称，为空时返回nil（关闭RAG）
+func NewService(db *gorm.DB, manager *ai.AIManager, provider string) *Service {
+	if provider == "" {
+		return nil
+	}
+	return &Service{
+		db:       db,
+		manager:  manager,
+		provider: provider,
+		index:    NewIndex(),
+	}
+}
+
+// embedder 获取向量化Provider，Provider可能在运行时重新加载
+func (s *Service) embedder() (ai.Embedder, error) {
+	return s.manager.GetEmbedder(s.provider)
+}
+
+// Load 从数据库加载当前向量模型生成的分块到内存索引
+func (s *Service) Load() error {
+	if s == nil {
+		return nil
+	}
+	embedder, err := s.embedder()
+	if err != nil {
+		return err
+	}
+
+	var chunks []models.ArticleChunk
+	if err := s.db.Where("embedding_model = ?", embedder.EmbeddingModel()).
+		Order("article_id, chunk_index").Find(&chunks).Error; err != nil {
+		return fmt.Errorf("加载文章分块失败: %w", err)
+	}
+
+	titles, err := s.publishedTitles()
+	if err != nil {
+		return err
+	}
+
+	grouped := make(map[uint][]Entry)
+	for _, chunk := range chunks {
+		title, ok := titles[chunk.ArticleID]
+		if !ok {
+			continue
+		}
+		grouped[chunk.ArticleID] = append(grouped[chunk.ArticleID], Entry{
+			ArticleID: chunk.ArticleID,
+			Title:     title,
+			Content:   chunk.Content,
+			Vector:    chunk.Embedding,
+		})
+	}
+	for articleID, entries := range grouped {
+		s.index.Replace(articleID, entries)
+	}
+
+	log.Printf("[RAG] 已加载 %d 篇文章的 %d 个分块", len(grouped), s.index.Len())
+	return nil
+}
+
+// publishedTitles 获取已发布文章的标题
+func (s *Service) publishedTitles() (map[uint]string, error) {
+	var articles []models.Article
+	if err := s.db.Select("id, title").Where("is_published = ?", true).Find(&articles).Error; err != nil {
+		return nil, fmt.Errorf("查询文章失败: %w", err)
+	}
+	titles := make(map[uint]string, len(articles))
+	for _, a := range articles {
+		titles[a.ID] = a.Title
+	}
+	return titles, nil
+}
+
+// Backfill 加载已有分块，并为尚未向量化（或向量模型已更换）的已发布文章建立索引
+func (s *Service) Backfill(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if err := s.Load(); err != nil {
+		log.Printf("[RAG] 加载索引失败: %v", err)
+		return
+	}
+	embedder, err := s.embedder()
+	if err != nil {
+		return
+	}
+
+	var articles []models.Article
+	if err := s.db.WithContext(ctx).
+		Where("is_published = ?", true).
+		Where("id NOT IN (?)", s.db.Model(&models.ArticleChunk{}).Select("article_id").Where("embedding_model = ?", embedder.EmbeddingModel())).
+		Find(&articles).Error; err != nil {
+		log.Printf("[RAG] 查询待索引文章失败: %v", err)
+		return
+	}
+
+	for i := range articles {
+		if err := s.IndexArticle(ctx, &articles[i]); err != nil {
+			log.Printf("[RAG] 文章 %d 建立索引失败: %v", articles[i].ID, err)
+		}
+	}
+	if len(articles) > 0 {
+		log.Printf("[RAG] 补建了 %d 篇文章的索引", len(articles))
+	}
+}
+
+// IndexArticle 为文章重建分块和向量
+// 未发布的文章只清除旧索引
+func (s *Service) IndexArticle(ctx context.Context, article *models.Article) error {
+	if s == nil {
+		return nil
+	}
+	if !article.IsPublished {
+		return s.RemoveArticle(article.ID)
+	}
+
+	embedder, err := s.embedder()
+	if err != nil {
+		return err
+	}
+
+	// 标题和摘要并入正文一起切分，使按标题提问也能命中
+	text := article.Title + "\n\n" + article.Summary + "\n\n" + article.Content
+	contents := Chunk(text, chunkSize, chunkOverlap)
+
+	vectors := make([][]float32, 0, len(contents))
+	for start := 0; start < len(contents); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(contents) {
+			end = len(contents)
+		}
+		batch, err := embedder.Embed(ctx, contents[start:end])
+		if err != nil {
+			return fmt.Errorf("向量化失败: %w", err)
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	chunks := make([]models.ArticleChunk, len(contents))
+	entries := make([]Entry, len(contents))
+	for i, content := range contents {
+		chunks[i] = models.ArticleChunk{
+			ArticleID:      article.ID,
+			ChunkIndex:     i,
+			Content:        content,
+			Embedding:      vectors[i],
+			EmbeddingModel: embedder.EmbeddingModel(),
+		}
+		entries[i] = Entry{ArticleID: article.ID, Title: article.Title, Content: content, Vector: vectors[i]}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("article_id = ?", article.ID).Delete(&models.ArticleChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.Create(&chunks).Error
+	})
+	if err != nil {
+		return fmt.Errorf("保存文章分块失败: %w", err)
+	}
+
+	s.index.Replace(article.ID, entries)
+	log.Printf("[RAG] 文章 %d 已建立索引，共 %d 个分块", article.ID, len(chunks))
+	return nil
+}
+
+// RemoveArticle 删除文章的分块和索引
+func (s *Service) RemoveArticle(articleID uint) error {
+	if s == nil {
+		return nil
+	}
+	s.index.Remove(articleID)
+	if err := s.db.Where("article_id = ?", articleID).Delete(&models.ArticleChunk{}).Error; err != nil {
+		return fmt.Errorf("删除文章分块失败: %w", err)
+	}
+	return nil
+}
+
+// Search 检索与问题最相关的k个文章分块
+func (s *Service) Search(ctx context.Context, query string, k int) (*Result, error) {
+	if s == nil || s.index.Len() == 0 || strings.TrimSpace(query) == "" {
+		return &Result{}, nil
+	}
+	embedder, err := s.embedder()
+	if err != nil {
+		return nil, err
+	}
+
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("问题向量化失败: %w", err)
+	}
+	return &Result{Hits: s.index.Search(vectors[0], k, minScore)}, nil
+}
+
+// Sources 命中分块所属的文章，同一篇文章只保留最高分
+func (r *Result) Sources() []Source {
+	var sources []Source
+	seen := make(map[uint]bool)
+	for _, hit := range r.Hits {
+		if seen[hit.ArticleID] {
+			continue
+		}
+		seen[hit.ArticleID] = true
+		sources = append(sources, Source{ArticleID: hit.ArticleID, Title: hit.Title, Score: hit.Score})
+	}
+	return sources
+}
+
+// ContextMessage 将命中分块格式化为系统消息，要求模型按编号引用
+// 没有命中时返回false
+func (r *Result) ContextMessage() (ai.ChatMessage, bool) {
+	sources := r.Sources()
+	if len(sources) == 0 {
+		return ai.ChatMessage{}, false
+	}
+	number := make(map[uint]int, len(sources))
+	for i, source := range sources {
+		number[source.ArticleID] = i + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("以下是站长博客文章中与访客问题相关的片段。回答时优先依据这些内容，引用时在句末标注来源编号，如[1]；片段与问题无关时忽略它们，不要编造文章中没有的内容。\n")
+	for _, hit := range r.Hits {
+		fmt.Fprintf(&b, "\n[%d] 《%s》(文章ID: %d)\n%s\n", number[hit.ArticleID], hit.Title, hit.ArticleID, hit.Content)
+	}
+	return ai.ChatMessage{Role: "system", Content: b.String()}, true
+}
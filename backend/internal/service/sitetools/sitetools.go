@@ -0,0 +1,307 @@
+// Package sitetools 提供AI聊天可调用的站内工具
+// 工具直接查询本站的文章、项目和留言数据，使模型能够基于真实数据回答访客的问题
+package sitetools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+	"personal-website/pkg/ratelimit"
+
+	"gorm.io/gorm"
+)
+
+// 工具返回数据的限制，避免工具结果占用过多上下文
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 10
+	maxProjects        = 20
+	// maxArticleRunes get_article返回的正文最大字符数
+	maxArticleRunes = 3000
+	// maxMessageRunes leave_message留言内容的最大字符数
+	maxMessageRunes = 1000
+)
+
+// visitorKey 访客信息在context中的键
+type visitorKey struct{}
+
+// Visitor 发起聊天的访客信息，用于leave_message记录来源
+type Visitor struct {
+	IP        string
+	UserAgent string
+}
+
+// WithVisitor 将访客信息写入context，工具执行时读取
+func WithVisitor(ctx context.Context, visitor Visitor) context.Context {
+	return context.WithValue(ctx, visitorKey{}, visitor)
+}
+
+// visitorFrom 从context读取访客信息
+func visitorFrom(ctx context.Context) Visitor {
+	visitor, _ := ctx.Value(visitorKey{}).(Visitor)
+	return visitor
+}
+
+// NewToolbox 创建包含全部站内工具的工具集合
+// messageLimiter 为留言接口的按IP限流器，leave_message与留言接口共享额度，为nil时不限制
+func NewToolbox(db *gorm.DB, messageLimiter *ratelimit.Limiter) *ai.Toolbox {
+	toolbox := ai.NewToolbox()
+	Register(toolbox, db, messageLimiter)
+	return toolbox
+}
+
+// Register 将站内工具注册到工具集合
+func Register(toolbox *ai.Toolbox, db *gorm.DB, messageLimiter *ratelimit.Limiter) {
+	t := &siteTools{db: db, messageLimiter: messageLimiter}
+
+	toolbox.Register(ai.ToolFunction{
+		Name:        "search_articles",
+		Description: "按关键词搜索站长发布的博客文章，返回标题、摘要和标签。回答站长写过什么、对某个技术的看法等问题时使用。",
+		Parameters: objectSchema(map[string]interface{}{
+			"query": map[string]interface{}{"type": "string", "description": "搜索关键词，如 Go、性能优化"},
+			"limit": map[string]interface{}{"type": "integer", "description": "返回数量，默认5，最多10"},
+		}, "query"),
+	}, t.searchArticles)
+
+	toolbox.Register(ai.ToolFunction{
+		Name:        "get_article",
+		Description: "根据文章ID获取文章正文（过长时截断）。需要引用文章具体内容时使用，ID来自search_articles的结果。",
+		Parameters: objectSchema(map[string]interface{}{
+			"id": map[string]interface{}{"type": "integer", "description": "文章ID"},
+		}, "id"),
+	}, t.getArticle)
+
+	toolbox.Register(ai.ToolFunction{
+		Name:        "list_projects",
+		Description: "列出站长的全部项目，包含简介、技术栈和链接。",
+		Parameters:  objectSchema(map[string]interface{}{}),
+	}, t.listProjects)
+
+	toolbox.Register(ai.ToolFunction{
+		Name:        "get_featured_projects",
+		Description: "列出站长的精选项目，介绍代表作时优先使用。",
+		Parameters:  objectSchema(map[string]interface{}{}),
+	}, t.getFeaturedProjects)
+
+	toolbox.Register(ai.ToolFunction{
+		Name:        "leave_message",
+		Description: "代访客给站长留言。只有在访客明确要求留言并提供了称呼和邮箱时才调用，调用前需向访客确认内容。",
+		Parameters: objectSchema(map[string]interface{}{
+			"name":    map[string]interface{}{"type": "string", "description": "访客称呼"},
+			"email":   map[string]interface{}{"type": "string", "description": "访客邮箱，用于站长回复"},
+			"content": map[string]interface{}{"type": "string", "description": "留言内容"},
+		}, "name", "email", "content"),
+	}, t.leaveMessage)
+}
+
+// objectSchema 构建JSON Schema对象定义
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// siteTools 站内工具实现
+type siteTools struct {
+	db             *gorm.DB
+	messageLimiter *ratelimit.Limiter
+}
+
+// articleBrief 文章摘要信息
+type articleBrief struct {
+	ID        uint     `json:"id"`
+	Title     string   `json:"title"`
+	Summary   string   `json:"summary"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// projectBrief 项目信息
+type projectBrief struct {
+	ID           uint     `json:"id"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Technologies []string `json:"technologies"`
+	GithubURL    string   `json:"github_url,omitempty"`
+	DemoURL      string   `json:"demo_url,omitempty"`
+}
+
+// searchArticles 按关键词搜索已发布的文章
+func (t *siteTools) searchArticles(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("参数错误: %w", err)
+	}
+	args.Query = strings.TrimSpace(args.Query)
+	if args.Query == "" {
+		return "", fmt.Errorf("query不能为空")
+	}
+	if args.Limit <= 0 {
+		args.Limit = defaultSearchLimit
+	}
+	if args.Limit > maxSearchLimit {
+		args.Limit = maxSearchLimit
+	}
+
+	pattern := "%" + escapeLike(args.Query) + "%"
+	var articles []models.Article
+	if err := t.db.WithContext(ctx).
+		Where("is_published = ?", true).
+		Where("title LIKE ? OR summary LIKE ? OR tags LIKE ? OR content LIKE ?", pattern, pattern, pattern, pattern).
+		Order("created_at DESC").
+		Limit(args.Limit).
+		Find(&articles).Error; err != nil {
+		return "", fmt.Errorf("查询文章失败: %w", err)
+	}
+
+	briefs := make([]articleBrief, 0, len(articles))
+	for _, a := range articles {
+		briefs = append(briefs, articleBrief{
+			ID:        a.ID,
+			Title:     a.Title,
+			Summary:   a.Summary,
+			Tags:      a.Tags,
+			CreatedAt: a.CreatedAt.Format("2006-01-02"),
+		})
+	}
+	return marshalResult(map[string]interface{}{"articles": briefs, "count": len(briefs)})
+}
+
+// getArticle 获取已发布文章的正文
+func (t *siteTools) getArticle(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("参数错误: %w", err)
+	}
+
+	var article models.Article
+	if err := t.db.WithContext(ctx).Where("id = ? AND is_published = ?", args.ID, true).First(&article).Error; err != nil {
+		return "", fmt.Errorf("文章 %d 不存在", args.ID)
+	}
+
+	content, truncated := truncateRunes(article.Content, maxArticleRunes)
+	return marshalResult(map[string]interface{}{
+		"id":         article.ID,
+		"title":      article.Title,
+		"tags":       article.Tags,
+		"created_at": article.CreatedAt.Format("2006-01-02"),
+		"content":    content,
+		"truncated":  truncated,
+	})
+}
+
+// listProjects 列出全部项目
+func (t *siteTools) listProjects(ctx context.Context, arguments json.RawMessage) (string, error) {
+	return t.queryProjects(t.db.WithContext(ctx))
+}
+
+// getFeaturedProjects 列出精选项目
+func (t *siteTools) getFeaturedProjects(ctx context.Context, arguments json.RawMessage) (string, error) {
+	return t.queryProjects(t.db.WithContext(ctx).Where("featured = ?", true))
+}
+
+// queryProjects 查询项目并转换为工具结果
+func (t *siteTools) queryProjects(query *gorm.DB) (string, error) {
+	var projects []models.Project
+	if err := query.Order("created_at DESC").Limit(maxProjects).Find(&projects).Error; err != nil {
+		return "", fmt.Errorf("查询项目失败: %w", err)
+	}
+
+	briefs := make([]projectBrief, 0, len(projects))
+	for _, p := range projects {
+		briefs = append(briefs, projectBrief{
+			ID:           p.ID,
+			Name:         p.Name,
+			Description:  p.Description,
+			Technologies: p.Technologies,
+			GithubURL:    p.GithubURL,
+			DemoURL:      p.DemoURL,
+		})
+	}
+	return marshalResult(map[string]interface{}{"projects": briefs, "count": len(briefs)})
+}
+
+// leaveMessage 代访客留言
+func (t *siteTools) leaveMessage(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("参数错误: %w", err)
+	}
+	args.Name = strings.TrimSpace(args.Name)
+	args.Email = strings.TrimSpace(args.Email)
+	args.Content = strings.TrimSpace(args.Content)
+	if args.Name == "" || args.Content == "" {
+		return "", fmt.Errorf("称呼和留言内容不能为空")
+	}
+	if !strings.Contains(args.Email, "@") {
+		return "", fmt.Errorf("邮箱格式不正确")
+	}
+	if utf8.RuneCountInString(args.Content) > maxMessageRunes {
+		return "", fmt.Errorf("留言内容不能超过%d字", maxMessageRunes)
+	}
+
+	// 与留言接口共用同一个IP的额度，避免通过聊天绕过留言限流
+	visitor := visitorFrom(ctx)
+	if result := t.messageLimiter.Allow(visitor.IP); !result.Allowed {
+		return "", fmt.Errorf("留言过于频繁，请%d分钟后再试", int(math.Ceil(result.RetryAfter.Minutes())))
+	}
+	message := models.Message{
+		Name:      args.Name,
+		Email:     args.Email,
+		Content:   args.Content,
+		IPAddress: visitor.IP,
+		UserAgent: visitor.UserAgent,
+	}
+	if err := t.db.WithContext(ctx).Create(&message).Error; err != nil {
+		return "", fmt.Errorf("保存留言失败: %w", err)
+	}
+
+	return marshalResult(map[string]interface{}{
+		"success":    true,
+		"message_id": message.ID,
+		"created_at": message.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// marshalResult 将工具结果序列化为JSON字符串
+func marshalResult(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// escapeLike 转义LIKE查询中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// truncateRunes 按字符截断文本，返回是否发生了截断
+func truncateRunes(s string, max int) (string, bool) {
+	if utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	return string([]rune(s)[:max]), true
+}
@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// alertThresholds 预算告警的阈值，花费达到预算的这些比例时各告警一次
+var alertThresholds = []float64{0.8, 1}
+
+// Budget 费用预算，为0表示不限制
+// 预算只用于告警，超出后不会拒绝请求
+type Budget struct {
+	Daily   float64 `json:"daily"`
+	Monthly float64 `json:"monthly"`
+}
+
+// BudgetFromEnv 从环境变量 AI_BUDGET_DAILY、AI_BUDGET_MONTHLY 读取预算
+func BudgetFromEnv() Budget {
+	return Budget{
+		Daily:   envFloat("AI_BUDGET_DAILY"),
+		Monthly: envFloat("AI_BUDGET_MONTHLY"),
+	}
+}
+
+// envFloat 读取浮点数环境变量，未设置或格式错误时返回0
+func envFloat(key string) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("[Usage] 环境变量 %s 格式错误，忽略: %s", key, value)
+		return 0
+	}
+	return f
+}
+
+// BudgetStatus 一个统计周期的预算使用情况
+// Key为周期标识，如 2006-01-02 或 2006-01；Ratio为已花费占预算的比例，没有预算时为0
+type BudgetStatus struct {
+	Period   string  `json:"period"`
+	Key      string  `json:"key"`
+	Limit    float64 `json:"limit"`
+	Spent    float64 `json:"spent"`
+	Ratio    float64 `json:"ratio"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// newBudgetStatus 计算预算使用情况
+func newBudgetStatus(period, key string, limit, spent float64) BudgetStatus {
+	status := BudgetStatus{Period: period, Key: key, Limit: limit, Spent: spent}
+	if limit > 0 {
+		status.Ratio = spent / limit
+		status.Exceeded = spent >= limit
+	}
+	return status
+}
+
+// crossedThreshold 已达到的最高告警阈值，未达到任何阈值或没有预算时返回0
+func (s BudgetStatus) crossedThreshold() float64 {
+	crossed := 0.0
+	for _, threshold := range alertThresholds {
+		if s.Limit > 0 && s.Ratio >= threshold {
+			crossed = threshold
+		}
+	}
+	return crossed
+}
+
+// alerter 记录每个周期已告警的阈值，同一周期的同一阈值只告警一次
+type alerter struct {
+	mu      sync.Mutex
+	alerted map[string]float64
+}
+
+// check 返回本次新达到的阈值，没有新达到的阈值时返回false
+func (a *alerter) check(status BudgetStatus) (float64, bool) {
+	threshold := status.crossedThreshold()
+	if threshold == 0 {
+		return 0, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.alerted == nil {
+		a.alerted = make(map[string]float64)
+	}
+	key := status.Period + ":" + status.Key
+	if a.alerted[key] >= threshold {
+		return 0, false
+	}
+	a.alerted[key] = threshold
+	return threshold, true
+}
@@ -0,0 +1,212 @@
+// Package usage 记录AI调用的Token用量并统计费用
+// 每次调用Provider写入一条用量记录，按价格表计算费用，花费接近或超出预算时告警
+package usage
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"personal-website/internal/models"
+	"personal-website/internal/service/ai"
+
+	"gorm.io/gorm"
+)
+
+// maxErrorLength 用量记录中保存的错误信息最大长度
+const maxErrorLength = 500
+
+// Ledger 用量账本
+type Ledger struct {
+	db     *gorm.DB
+	budget Budget
+	alerts alerter
+
+	mu     sync.RWMutex
+	prices PriceTable
+
+	// pending 已记录但还没有写入数据库的Token数，按会话统计，保证会话配额检查不漏算正在保存的用量
+	pendingMu sync.Mutex
+	pending   map[string]int64
+}
+
+// NewLedger 创建用量账本并加载价格表
+func NewLedger(db *gorm.DB, budget Budget) *Ledger {
+	ledger := &Ledger{db: db, budget: budget}
+	if err := ledger.ReloadPrices(); err != nil {
+		log.Printf("[Usage] 加载模型价格失败: %v", err)
+	}
+	return ledger
+}
+
+// ReloadPrices 从数据库重新加载价格表，修改价格后调用
+// 已记录的费用不会按新价格重新计算
+func (l *Ledger) ReloadPrices() error {
+	var prices []models.ModelPrice
+	if err := l.db.Find(&prices).Error; err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.prices = prices
+	l.mu.Unlock()
+	return nil
+}
+
+// Prices 当前的价格表
+func (l *Ledger) Prices() PriceTable {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.prices
+}
+
+// Budget 当前的预算配置
+func (l *Ledger) Budget() Budget {
+	return l.budget
+}
+
+// Record 记录一次调用的用量，作为 ai.UsageRecorder 使用
+// 写入数据库和预算检查在后台执行，不阻塞AI调用；保存完成前用量计入会话的待保存Token数
+func (l *Ledger) Record(event ai.UsageEvent) {
+	record := l.newRecord(event, time.Now())
+	l.addPending(record.SessionID, int64(record.TotalTokens))
+	go func() {
+		l.save(record)
+		l.addPending(record.SessionID, -int64(record.TotalTokens))
+	}()
+}
+
+// addPending 调整会话待保存的Token数
+func (l *Ledger) addPending(sessionID string, tokens int64) {
+	if sessionID == "" || tokens == 0 {
+		return
+	}
+	l.pendingMu.Lock()
+	defer l.pendingMu.Unlock()
+	if l.pending == nil {
+		l.pending = make(map[string]int64)
+	}
+	l.pending[sessionID] += tokens
+	if l.pending[sessionID] == 0 {
+		delete(l.pending, sessionID)
+	}
+}
+
+// pendingTokens 会话待保存的Token数
+func (l *Ledger) pendingTokens(sessionID string) int64 {
+	l.pendingMu.Lock()
+	defer l.pendingMu.Unlock()
+	return l.pending[sessionID]
+}
+
+// newRecord 根据用量事件构建用量记录并计算费用
+func (l *Ledger) newRecord(event ai.UsageEvent, now time.Time) models.UsageRecord {
+	record := models.UsageRecord{
+		Provider:         event.Provider,
+		ProviderID:       event.ProviderID,
+		Model:            event.Model,
+		Purpose:          event.Labels.Purpose,
+		SessionID:        event.Labels.SessionID,
+		ConversationID:   event.Labels.ConversationID,
+		CharacterID:      event.Labels.CharacterID,
+		PromptTokens:     event.Usage.PromptTokens,
+		CompletionTokens: event.Usage.CompletionTokens,
+		TotalTokens:      event.Usage.TotalTokens,
+		Estimated:        event.Estimated,
+		LatencyMs:        event.Latency.Milliseconds(),
+		Success:          event.Err == nil,
+		CreatedAt:        now,
+	}
+	if event.Err != nil {
+		record.Error = ai.TruncateRunes(event.Err.Error(), maxErrorLength)
+	}
+	record.Cost = l.Prices().Cost(record.Provider, record.Model, record.PromptTokens, record.CompletionTokens)
+	return record
+}
+
+// save 保存用量记录，产生费用时检查预算
+func (l *Ledger) save(record models.UsageRecord) {
+	if err := l.db.Create(&record).Error; err != nil {
+		log.Printf("[Usage] 保存用量记录失败: %v", err)
+		return
+	}
+	if record.Cost > 0 {
+		l.checkBudget(record.CreatedAt)
+	}
+}
+
+// checkBudget 检查预算，新达到告警阈值时记录告警日志
+func (l *Ledger) checkBudget(now time.Time) {
+	statuses, err := l.BudgetStatus(now)
+	if err != nil {
+		log.Printf("[Usage] 统计预算使用情况失败: %v", err)
+		return
+	}
+	for _, status := range statuses {
+		if threshold, ok := l.alerts.check(status); ok {
+			log.Printf("[Usage] 预算告警: %s %s 已花费 %.4f，达到预算 %.4f 的 %.0f%%",
+				status.Period, status.Key, status.Spent, status.Limit, threshold*100)
+		}
+	}
+}
+
+// BudgetStatus 统计now所在的天和月的预算使用情况，只返回设置了预算的周期
+func (l *Ledger) BudgetStatus(now time.Time) ([]BudgetStatus, error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	statuses := make([]BudgetStatus, 0, 2)
+	if l.budget.Daily > 0 {
+		spent, err := l.spentSince(day)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, newBudgetStatus(PeriodDaily, day.Format("2006-01-02"), l.budget.Daily, spent))
+	}
+	if l.budget.Monthly > 0 {
+		spent, err := l.spentSince(month)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, newBudgetStatus(PeriodMonthly, month.Format("2006-01"), l.budget.Monthly, spent))
+	}
+	return statuses, nil
+}
+
+// spentSince 统计since之后的总费用
+func (l *Ledger) spentSince(since time.Time) (float64, error) {
+	var spent float64
+	err := l.db.Model(&models.UsageRecord{}).
+		Where("created_at >= ?", since).
+		Select("COALESCE(SUM(cost), 0)").
+		Scan(&spent).Error
+	return spent, err
+}
+
+// SessionTokens 统计会话自since以来消耗的Token数，包括后台生成摘要的用量和还没有写入数据库的用量
+// 记录刚写入数据库时可能被重复计算一次，宁可多算也不让并发的请求超出配额
+func (l *Ledger) SessionTokens(sessionID string, since time.Time) (int64, error) {
+	var tokens int64
+	err := l.db.Model(&models.UsageRecord{}).
+		Where("session_id = ? AND created_at >= ?", sessionID, since).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Scan(&tokens).Error
+	return tokens + l.pendingTokens(sessionID), err
+}
+
+// DailyRows 按天、Provider和角色汇总[from, to)之间的用量
+func (l *Ledger) DailyRows(from, to time.Time) ([]DailyRow, error) {
+	var rows []DailyRow
+	err := l.db.Model(&models.UsageRecord{}).
+		Select(`DATE_FORMAT(created_at, '%Y-%m-%d') AS day, provider, character_id,
+			COUNT(*) AS calls,
+			SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failures,
+			SUM(prompt_tokens) AS prompt_tokens,
+			SUM(completion_tokens) AS completion_tokens,
+			SUM(total_tokens) AS total_tokens,
+			SUM(cost) AS cost`).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("day, provider, character_id").
+		Scan(&rows).Error
+	return rows, err
+}
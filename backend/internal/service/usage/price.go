@@ -0,0 +1,49 @@
+package usage
+
+import (
+	"strings"
+
+	"personal-website/internal/models"
+)
+
+// tokensPerPriceUnit 价格的计量单位，价格为每百万Token的费用
+const tokensPerPriceUnit = 1_000_000
+
+// PriceTable 模型价格表
+type PriceTable []models.ModelPrice
+
+// Lookup 查找模型的价格
+// 指定Provider的价格优先于通用价格，同一优先级下模型名前缀最长的价格优先
+func (t PriceTable) Lookup(provider, model string) (models.ModelPrice, bool) {
+	var best models.ModelPrice
+	found := false
+	for _, price := range t {
+		if price.Provider != "" && price.Provider != provider {
+			continue
+		}
+		if !strings.HasPrefix(model, price.Model) {
+			continue
+		}
+		if !found || betterPrice(price, best) {
+			best, found = price, true
+		}
+	}
+	return best, found
+}
+
+// betterPrice 价格a是否比b更精确
+func betterPrice(a, b models.ModelPrice) bool {
+	if (a.Provider != "") != (b.Provider != "") {
+		return a.Provider != ""
+	}
+	return len(a.Model) > len(b.Model)
+}
+
+// Cost 计算一次调用的费用，没有配置价格时返回0
+func (t PriceTable) Cost(provider, model string, promptTokens, completionTokens int) float64 {
+	price, ok := t.Lookup(provider, model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.InputPrice + float64(completionTokens)*price.OutputPrice) / tokensPerPriceUnit
+}
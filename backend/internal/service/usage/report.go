@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"errors"
+	"sort"
+)
+
+// 统计周期
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+)
+
+// 分组维度
+const (
+	GroupByProvider  = "provider"
+	GroupByCharacter = "character"
+)
+
+// ErrInvalidReport 统计周期或分组维度不支持
+var ErrInvalidReport = errors.New("period只支持daily/monthly，group_by只支持provider/character")
+
+// DailyRow 按天、Provider和角色聚合的用量，由数据库汇总得到
+// Day格式为 2006-01-02
+type DailyRow struct {
+	Day              string
+	Provider         string
+	CharacterID      uint
+	Calls            int64
+	Failures         int64
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	Cost             float64
+}
+
+// ReportRow 统计报表中的一行
+// Period为日期（2006-01-02）或月份（2006-01），按Provider分组时CharacterID为0，按角色分组时Provider为空
+type ReportRow struct {
+	Period           string  `json:"period"`
+	Provider         string  `json:"provider,omitempty"`
+	CharacterID      uint    `json:"character_id,omitempty"`
+	Calls            int64   `json:"calls"`
+	Failures         int64   `json:"failures"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Rollup 将按天聚合的用量汇总为指定周期和维度的报表
+// 结果按周期倒序、费用倒序排列
+func Rollup(rows []DailyRow, period, groupBy string) ([]ReportRow, error) {
+	if (period != PeriodDaily && period != PeriodMonthly) || (groupBy != GroupByProvider && groupBy != GroupByCharacter) {
+		return nil, ErrInvalidReport
+	}
+
+	type key struct {
+		period      string
+		provider    string
+		characterID uint
+	}
+	index := make(map[key]int)
+	report := make([]ReportRow, 0)
+	for _, row := range rows {
+		k := key{period: row.Day}
+		if period == PeriodMonthly && len(row.Day) >= 7 {
+			k.period = row.Day[:7]
+		}
+		if groupBy == GroupByProvider {
+			k.provider = row.Provider
+		} else {
+			k.characterID = row.CharacterID
+		}
+
+		i, ok := index[k]
+		if !ok {
+			i = len(report)
+			index[k] = i
+			report = append(report, ReportRow{Period: k.period, Provider: k.provider, CharacterID: k.characterID})
+		}
+		r := &report[i]
+		r.Calls += row.Calls
+		r.Failures += row.Failures
+		r.PromptTokens += row.PromptTokens
+		r.CompletionTokens += row.CompletionTokens
+		r.TotalTokens += row.TotalTokens
+		r.Cost += row.Cost
+	}
+
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Period != report[j].Period {
+			return report[i].Period > report[j].Period
+		}
+		return report[i].Cost > report[j].Cost
+	})
+	return report, nil
+}
@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"personal-website/internal/service/ai"
+)
+
+// 测试价格匹配：指定Provider优先，其次是最长的模型前缀
+func TestPriceTable_Lookup(t *testing.T) {
+	table := PriceTable{
+		{ID: 1, Model: "gpt-4o", InputPrice: 2.5, OutputPrice: 10},
+		{ID: 2, Model: "gpt-4o-mini", InputPrice: 0.15, OutputPrice: 0.6},
+		{ID: 3, Provider: "azure", Model: "gpt-4o", InputPrice: 3, OutputPrice: 12},
+	}
+
+	tests := []struct {
+		provider, model string
+		expectedID      uint
+		found           bool
+	}{
+		{"openai", "gpt-4o-2024-08-06", 1, true},
+		{"openai", "gpt-4o-mini", 2, true},
+		{"azure", "gpt-4o-mini", 3, true},
+		{"openai", "deepseek-chat", 0, false},
+	}
+	for _, tt := range tests {
+		price, found := table.Lookup(tt.provider, tt.model)
+		if found != tt.found || price.ID != tt.expectedID {
+			t.Errorf("Lookup(%s, %s) = %d, %v; expected %d, %v", tt.provider, tt.model, price.ID, found, tt.expectedID, tt.found)
+		}
+	}
+}
+
+// 测试费用按每百万Token计算
+func TestPriceTable_Cost(t *testing.T) {
+	table := PriceTable{{Model: "deepseek-chat", InputPrice: 2, OutputPrice: 8}}
+
+	cost := table.Cost("deepseek", "deepseek-chat", 1000, 500)
+	if math.Abs(cost-0.006) > 1e-9 {
+		t.Errorf("Expected cost 0.006, got %v", cost)
+	}
+	if cost := table.Cost("glm", "glm-4", 1000, 500); cost != 0 {
+		t.Errorf("Expected 0 for unpriced model, got %v", cost)
+	}
+}
+
+// 测试用量记录的构建：失败的调用记录错误信息，费用按价格表计算
+func TestLedger_NewRecord(t *testing.T) {
+	ledger := &Ledger{prices: PriceTable{{Model: "deepseek-chat", InputPrice: 2, OutputPrice: 8}}}
+	now := time.Now()
+
+	record := ledger.newRecord(ai.UsageEvent{
+		Labels:     ai.UsageLabels{Purpose: ai.PurposeChat, SessionID: "s1", ConversationID: 5, CharacterID: 2},
+		Provider:   "deepseek",
+		ProviderID: 7,
+		Model:      "deepseek-chat",
+		Usage:      ai.ChatUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+		Latency:    1500 * time.Millisecond,
+	}, now)
+	if !record.Success || record.LatencyMs != 1500 || record.ProviderID != 7 || record.CharacterID != 2 {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if math.Abs(record.Cost-0.006) > 1e-9 {
+		t.Errorf("Expected cost 0.006, got %v", record.Cost)
+	}
+
+	failed := ledger.newRecord(ai.UsageEvent{Provider: "deepseek", Model: "deepseek-chat", Err: errors.New("timeout")}, now)
+	if failed.Success || failed.Error != "timeout" || failed.Cost != 0 {
+		t.Errorf("Unexpected failed record: %+v", failed)
+	}
+}
+
+// 测试待保存的Token数：按会话累加，保存完成后扣除
+func TestLedger_Pending(t *testing.T) {
+	ledger := &Ledger{}
+	ledger.addPending("s1", 1500)
+	ledger.addPending("s1", 500)
+	ledger.addPending("s2", 300)
+	ledger.addPending("", 100)
+	if tokens := ledger.pendingTokens("s1"); tokens != 2000 {
+		t.Errorf("Expected 2000 pending tokens, got %d", tokens)
+	}
+
+	ledger.addPending("s1", -1500)
+	ledger.addPending("s1", -500)
+	if tokens := ledger.pendingTokens("s1"); tokens != 0 {
+		t.Errorf("Expected no pending tokens after saving, got %d", tokens)
+	}
+	if len(ledger.pending) != 1 {
+		t.Errorf("Expected saved sessions to be removed, got %v", ledger.pending)
+	}
+}
+
+// 测试报表汇总：按月合并，按Provider或角色分组
+func TestRollup(t *testing.T) {
+	rows := []DailyRow{
+		{Day: "2026-09-30", Provider: "deepseek", CharacterID: 1, Calls: 1, TotalTokens: 100, Cost: 1},
+		{Day: "2026-10-01", Provider: "deepseek", CharacterID: 1, Calls: 2, TotalTokens: 200, Cost: 2},
+		{Day: "2026-10-02", Provider: "deepseek", CharacterID: 2, Calls: 3, TotalTokens: 300, Cost: 3},
+		{Day: "2026-10-02", Provider: "glm", CharacterID: 1, Calls: 1, Failures: 1, Cost: 6},
+	}
+
+	report, err := Rollup(rows, PeriodMonthly, GroupByProvider)
+	if err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+	if len(report) != 3 {
+		t.Fatalf("Expected 3 rows, got %+v", report)
+	}
+	if report[0].Period != "2026-10" || report[0].Provider != "glm" || report[0].Failures != 1 {
+		t.Errorf("Expected glm first in October, got %+v", report[0])
+	}
+	if report[1].Provider != "deepseek" || report[1].Calls != 5 || report[1].TotalTokens != 500 || report[1].Cost != 5 {
+		t.Errorf("Unexpected deepseek row: %+v", report[1])
+	}
+	if report[2].Period != "2026-09" {
+		t.Errorf("Expected September last, got %+v", report[2])
+	}
+
+	report, err = Rollup(rows, PeriodDaily, GroupByCharacter)
+	if err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+	if len(report) != 4 || report[0].Period != "2026-10-02" || report[0].CharacterID != 1 || report[0].Provider != "" {
+		t.Errorf("Unexpected daily report: %+v", report)
+	}
+
+	if _, err := Rollup(rows, "weekly", GroupByProvider); !errors.Is(err, ErrInvalidReport) {
+		t.Errorf("Expected ErrInvalidReport, got %v", err)
+	}
+}
+
+// 测试预算告警：每个阈值在同一周期只告警一次
+func TestAlerter(t *testing.T) {
+	var alerts alerter
+
+	steps := []struct {
+		spent     float64
+		threshold float64
+		alert     bool
+	}{
+		{5, 0, false},
+		{8, 0.8, true},
+		{9, 0, false},
+		{10, 1, true},
+		{12, 0, false},
+	}
+	for _, step := range steps {
+		status := newBudgetStatus(PeriodDaily, "2026-10-17", 10, step.spent)
+		threshold, alert := alerts.check(status)
+		if alert != step.alert || threshold != step.threshold {
+			t.Errorf("spent %v: expected (%v, %v), got (%v, %v)", step.spent, step.threshold, step.alert, threshold, alert)
+		}
+	}
+
+	// 新的周期重新告警
+	if _, alert := alerts.check(newBudgetStatus(PeriodDaily, "2026-10-18", 10, 10)); !alert {
+		t.Error("Expected alert for new period")
+	}
+	// 没有预算时不告警
+	if _, alert := alerts.check(newBudgetStatus(PeriodMonthly, "2026-10", 0, 100)); alert {
+		t.Error("Expected no alert without budget")
+	}
+}
@@ -11,6 +11,12 @@ CREATE TABLE IF NOT EXISTS ai_characters (
     avatar VARCHAR(255) COMMENT '角色头像URL',
     personality_tags JSON COMMENT '性格标签',
     greeting_message TEXT COMMENT '欢迎消息',
+    preferred_provider VARCHAR(50) COMMENT '首选Provider，为空使用默认Provider',
+    fallback_provider VARCHAR(50) COMMENT '备用Provider',
+    temperature FLOAT COMMENT '温度参数，为空使用Provider默认值',
+    top_p FLOAT COMMENT '核采样参数',
+    max_tokens BIGINT DEFAULT 0 COMMENT '最大回复Token数，0使用默认值',
+    stop_sequences JSON COMMENT '停止序列',
     is_active BOOLEAN DEFAULT TRUE COMMENT '是否启用',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
@@ -19,16 +25,19 @@ CREATE TABLE IF NOT EXISTS ai_characters (
 -- ========== AI提供商配置表 ==========
 CREATE TABLE IF NOT EXISTS ai_providers (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-    name VARCHAR(50) NOT NULL UNIQUE COMMENT '提供商名称',
+    name VARCHAR(50) NOT NULL UNIQUE COMMENT '提供商实例名称',
+    kind VARCHAR(50) COMMENT '提供商类型(glm/deepseek/qwen/kimi/openai/openai_compatible)',
     display_name VARCHAR(100) COMMENT '显示名称',
     api_endpoint VARCHAR(255) COMMENT 'API端点',
     model_name VARCHAR(100) COMMENT '模型名称',
     max_tokens INT DEFAULT 4000 COMMENT '最大Token数',
     temperature DECIMAL(3,2) DEFAULT 0.70 COMMENT '温度参数',
+    options JSON COMMENT '厂商差异配置',
     api_key_encrypted TEXT COMMENT '加密的API密钥',
     is_active BOOLEAN DEFAULT TRUE COMMENT '是否启用',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_kind (kind)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
 -- ========== 聊天记录表 ==========
@@ -77,12 +86,17 @@ ON DUPLICATE KEY UPDATE updated_at = CURRENT_TIMESTAMP;
 -- 注意：api_key_encrypted 字段需要填写加密后的API密钥
 -- 如果使用环境变量配置，可以跳过此步骤
 
-INSERT INTO ai_providers (name, display_name, api_endpoint, model_name, max_tokens, temperature, is_active) VALUES
-('glm', '智谱GLM-4', 'https://open.bigmodel.cn/api/paas/v4', 'glm-4-flash', 4000, 0.70, FALSE),
-('deepseek', 'DeepSeek Chat', 'https://api.deepseek.com/v1', 'deepseek-chat', 4000, 0.70, FALSE),
-('qwen', '通义千问Turbo', 'https://dashscope.aliyuncs.com/compatible-mode/v1', 'qwen-turbo', 4000, 0.70, FALSE),
-('kimi', 'Kimi Chat', 'https://api.moonshot.cn/v1', 'moonshot-v1-8k', 4000, 0.70, FALSE)
+INSERT INTO ai_providers (name, kind, display_name, api_endpoint, model_name, max_tokens, temperature, is_active) VALUES
+('glm', 'glm', '智谱GLM-4', 'https://open.bigmodel.cn/api/paas/v4', 'glm-4-flash', 4000, 0.70, FALSE),
+('deepseek', 'deepseek', 'DeepSeek Chat', 'https://api.deepseek.com/v1', 'deepseek-chat', 4000, 0.70, FALSE),
+('qwen', 'qwen', '通义千问Turbo', 'https://dashscope.aliyuncs.com/compatible-mode/v1', 'qwen-turbo', 4000, 0.70, FALSE),
+('kimi', 'kimi', 'Kimi Chat', 'https://api.moonshot.cn/v1', 'moonshot-v1-8k', 4000, 0.70, FALSE)
 ON DUPLICATE KEY UPDATE updated_at = CURRENT_TIMESTAMP;
 
+-- 新增厂商只需添加一条openai_compatible配置，差异通过options声明，例如：
+-- INSERT INTO ai_providers (name, kind, display_name, api_endpoint, model_name, options, is_active) VALUES
+-- ('zhipu-proxy', 'openai_compatible', '自建代理', 'https://llm.example.com/v1', 'my-model',
+--  '{"tag": "Proxy", "auth_header": "api-key", "auth_scheme": "-", "stream_usage": "true"}', FALSE);
+
 -- 提示：配置API密钥后，需要将is_active设置为TRUE
 -- UPDATE ai_providers SET api_key_encrypted = '你的加密密钥', is_active = TRUE WHERE name = 'glm';
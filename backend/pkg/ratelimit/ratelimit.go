@@ -0,0 +1,228 @@
+// Package ratelimit 提供按键区分的令牌桶限流
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidRate 无法解析的限流配置
+var ErrInvalidRate = errors.New("限流配置格式应为 次数/周期，如 20/m，周期支持 s、m、h、d")
+
+// sweepInterval 清理空闲令牌桶的间隔
+const sweepInterval = time.Minute
+
+// Rate 限流速率：每个周期最多Limit次，令牌匀速补充，桶的容量也为Limit
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRate 解析限流配置，如 20/m、100/h、1000/d
+// 空字符串、off或0表示不限流，返回零值
+func ParseRate(value string) (Rate, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" || value == "0" {
+		return Rate{}, nil
+	}
+
+	count, unit, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rate{}, ErrInvalidRate
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit < 0 {
+		return Rate{}, ErrInvalidRate
+	}
+
+	var period time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	case "d":
+		period = 24 * time.Hour
+	default:
+		return Rate{}, ErrInvalidRate
+	}
+	if limit == 0 {
+		return Rate{}, nil
+	}
+	return Rate{Limit: limit, Period: period}, nil
+}
+
+// Enabled 是否启用限流
+func (r Rate) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+// String 返回配置格式的字符串
+func (r Rate) String() string {
+	if !r.Enabled() {
+		return "off"
+	}
+	units := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h", 24 * time.Hour: "d"}
+	if unit, ok := units[r.Period]; ok {
+		return fmt.Sprintf("%d/%s", r.Limit, unit)
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+// Result 一次限流检查的结果
+// Remaining为剩余可用次数；Reset为令牌桶补满所需的时间；RetryAfter为被拒绝时到下一个令牌可用的时间
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// bucket 令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 令牌桶限流器，每个键一个令牌桶
+// 令牌桶补满后不再需要保存，定期清理以免键的数量无限增长
+type Limiter struct {
+	rate      Rate
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter 创建限流器，rate未启用时返回nil，nil限流器不限制任何请求
+func NewLimiter(rate Rate) *Limiter {
+	if !rate.Enabled() {
+		return nil
+	}
+	return &Limiter{
+		rate:    rate,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Rate 限流速率
+func (l *Limiter) Rate() Rate {
+	if l == nil {
+		return Rate{}
+	}
+	return l.rate
+}
+
+// Allow 检查并消耗一个令牌
+func (l *Limiter) Allow(key string) Result {
+	return l.take(key, true)
+}
+
+// Peek 检查是否还有令牌，不消耗
+func (l *Limiter) Peek(key string) Result {
+	return l.take(key, false)
+}
+
+// Check 一个限流维度：用哪个限流器检查哪个键
+type Check struct {
+	Limiter *Limiter
+	Key     string
+}
+
+// AllowAll 同一请求需要通过多个限流器时使用，全部有令牌才放行
+// 依次消耗每个限流器的令牌，任一被拒绝时退还已消耗的令牌，被拒绝的请求不占用其他维度的额度；
+// 每一步都在对应限流器的锁内完成，并发请求不会在检查和消耗之间抢走令牌
+// 返回被拒绝的结果和对应的下标；全部通过时返回剩余次数最少的结果，下标为-1
+func AllowAll(checks ...Check) (Result, int) {
+	strictest := Result{Allowed: true}
+	for i, check := range checks {
+		result := check.Limiter.Allow(check.Key)
+		if !result.Allowed {
+			for _, taken := range checks[:i] {
+				taken.Limiter.refund(taken.Key)
+			}
+			return result, i
+		}
+		if i == 0 || result.Remaining < strictest.Remaining {
+			strictest = result
+		}
+	}
+	return strictest, -1
+}
+
+// refund 退还一个令牌
+func (l *Limiter) refund(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 令牌桶已被清理说明已经补满，不需要退还
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(l.rate.Limit), b.tokens+1)
+	}
+}
+
+// take 补充令牌后检查，consume为true且有令牌时消耗一个
+func (l *Limiter) take(key string, consume bool) Result {
+	if l == nil {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	limit := float64(l.rate.Limit)
+	perToken := l.rate.Period / time.Duration(l.rate.Limit)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit, last: now}
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(limit, b.tokens+float64(elapsed)/float64(perToken))
+		b.last = now
+	}
+
+	result := Result{Limit: l.rate.Limit}
+	if b.tokens >= 1 {
+		result.Allowed = true
+		if consume {
+			b.tokens--
+			l.buckets[key] = b
+		}
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((limit - b.tokens) * float64(perToken))
+	return result
+}
+
+// sweep 清理已经补满的令牌桶
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	perToken := float64(l.rate.Period / time.Duration(l.rate.Limit))
+	for key, b := range l.buckets {
+		if b.tokens+float64(now.Sub(b.last))/perToken >= float64(l.rate.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
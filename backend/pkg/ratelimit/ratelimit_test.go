@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试限流配置的解析
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Rate
+		wantErr  bool
+	}{
+		{"20/m", Rate{Limit: 20, Period: time.Minute}, false},
+		{" 5 / h ", Rate{Limit: 5, Period: time.Hour}, false},
+		{"1000/d", Rate{Limit: 1000, Period: 24 * time.Hour}, false},
+		{"", Rate{}, false},
+		{"off", Rate{}, false},
+		{"0/m", Rate{}, false},
+		{"20", Rate{}, true},
+		{"x/m", Rate{}, true},
+		{"20/w", Rate{}, true},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.value)
+		if (err != nil) != tt.wantErr || rate != tt.expected {
+			t.Errorf("ParseRate(%q) = %+v, %v; expected %+v, error=%v", tt.value, rate, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+// 测试令牌桶：用完后拒绝，按速率补充
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewLimiter(Rate{Limit: 3, Period: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		result := limiter.Allow("ip")
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("Request %d: expected allowed with %d remaining, got %+v", i, 2-i, result)
+		}
+	}
+
+	result := limiter.Allow("ip")
+	if result.Allowed {
+		t.Fatal("Expected fourth request to be rejected")
+	}
+	if result.RetryAfter != 20*time.Second || result.Reset != time.Minute {
+		t.Errorf("Expected retry after 20s and reset in 1m, got %+v", result)
+	}
+
+	// 其他键不受影响
+	if !limiter.Allow("other").Allowed {
+		t.Error("Expected other key to be allowed")
+	}
+
+	// 20秒后补充一个令牌
+	now = now.Add(20 * time.Second)
+	if !limiter.Allow("ip").Allowed {
+		t.Error("Expected request to be allowed after refill")
+	}
+	if limiter.Allow("ip").Allowed {
+		t.Error("Expected request to be rejected again")
+	}
+}
+
+// 测试Peek不消耗令牌
+func TestLimiter_Peek(t *testing.T) {
+	limiter := NewLimiter(Rate{Limit: 1, Period: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Peek("session").Allowed {
+			t.Fatal("Expected peek to be allowed")
+		}
+	}
+	limiter.Allow("session")
+	if limiter.Peek("session").Allowed {
+		t.Error("Expected peek to be rejected after token is used")
+	}
+}
+
+// 测试多个限流器：任一拒绝时不消耗其他限流器的令牌
+func TestAllowAll(t *testing.T) {
+	ipLimiter := NewLimiter(Rate{Limit: 5, Period: time.Hour})
+	sessionLimiter := NewLimiter(Rate{Limit: 1, Period: time.Hour})
+	checks := []Check{{ipLimiter, "ip"}, {sessionLimiter, "session"}}
+
+	result, rejected := AllowAll(checks...)
+	if !result.Allowed || rejected != -1 || result.Remaining != 0 {
+		t.Fatalf("Expected allowed with strictest remaining 0, got %+v (rejected %d)", result, rejected)
+	}
+
+	for i := 0; i < 3; i++ {
+		if result, rejected := AllowAll(checks...); result.Allowed || rejected != 1 {
+			t.Fatalf("Expected session limiter to reject, got %+v (rejected %d)", result, rejected)
+		}
+	}
+	if remaining := ipLimiter.Peek("ip").Remaining; remaining != 4 {
+		t.Errorf("Expected rejected requests not to use ip tokens, got %d remaining", remaining)
+	}
+
+	// nil限流器不限制
+	if result, rejected := AllowAll(Check{nil, "ip"}, Check{ipLimiter, "other"}); !result.Allowed || rejected != -1 {
+		t.Errorf("Expected nil limiter to allow, got %+v", result)
+	}
+}
+
+// 测试清理已补满的令牌桶
+func TestLimiter_Sweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewLimiter(Rate{Limit: 2, Period: time.Minute})
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("a")
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("b")
+
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("Expected refilled bucket to be removed")
+	}
+	if _, ok := limiter.buckets["b"]; !ok {
+		t.Error("Expected active bucket to be kept")
+	}
+}
+
+// 测试nil限流器不限制请求
+func TestLimiter_Disabled(t *testing.T) {
+	limiter := NewLimiter(Rate{})
+	if limiter != nil {
+		t.Fatal("Expected nil limiter for disabled rate")
+	}
+	if !limiter.Allow("any").Allowed {
+		t.Error("Expected nil limiter to allow")
+	}
+}
@@ -0,0 +1,74 @@
+// Package session 签发和校验访客的匿名会话令牌
+// 令牌格式为 <会话ID>.<签名>，签名为服务端密钥对会话ID的HMAC-SHA256，客户端无法伪造他人的会话
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strings"
+)
+
+// idBytes 会话ID的随机字节数
+const idBytes = 16
+
+// ErrInvalidToken 令牌格式错误或签名不匹配
+var ErrInvalidToken = errors.New("无效的会话令牌")
+
+// Signer 会话令牌签发器
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner 使用指定密钥创建签发器
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// NewSignerFromEnv 从环境变量SESSION_SECRET读取密钥创建签发器
+// 未设置时使用随机密钥，服务重启后之前签发的令牌全部失效，生产环境必须设置
+func NewSignerFromEnv() *Signer {
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		return NewSigner([]byte(secret))
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("[Session] 生成会话密钥失败: %v", err)
+	}
+	log.Println("[Session] 未设置SESSION_SECRET，使用随机密钥，重启后访客需要重新获取会话")
+	return NewSigner(secret)
+}
+
+// Issue 签发新的会话，返回会话ID和令牌
+func (s *Signer) Issue() (id, token string, err error) {
+	buf := make([]byte, idBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	id = hex.EncodeToString(buf)
+	return id, id + "." + s.sign(id), nil
+}
+
+// Verify 校验令牌并返回其中的会话ID
+func (s *Signer) Verify(token string) (string, error) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || len(id) != 2*idBytes {
+		return "", ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(id))) {
+		return "", ErrInvalidToken
+	}
+	return id, nil
+}
+
+// sign 计算会话ID的签名
+func (s *Signer) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
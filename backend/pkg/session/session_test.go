@@ -0,0 +1,60 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+// 测试签发的令牌可以校验通过并还原会话ID
+func TestIssueVerify(t *testing.T) {
+	signer := NewSigner([]byte("test-secret"))
+
+	id, token, err := signer.Issue()
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if !strings.HasPrefix(token, id+".") {
+		t.Errorf("Expected token to start with session id, got %q", token)
+	}
+
+	got, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got != id {
+		t.Errorf("Expected session id %q, got %q", id, got)
+	}
+
+	other, _, _ := signer.Issue()
+	if other == id {
+		t.Error("Expected different session ids")
+	}
+}
+
+// 测试篡改、伪造和其他密钥签发的令牌校验失败
+func TestVerify_Invalid(t *testing.T) {
+	signer := NewSigner([]byte("test-secret"))
+	id := strings.Repeat("a", 2*idBytes)
+	token := id + "." + signer.sign(id)
+	if _, err := signer.Verify(token); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"id only", id},
+		{"empty signature", id + "."},
+		{"tampered id", strings.Repeat("b", 2*idBytes) + "." + signer.sign(id)},
+		{"tampered signature", token[:len(token)-1] + "x"},
+		{"other secret", id + "." + NewSigner([]byte("other-secret")).sign(id)},
+		{"client chosen id", "session_123_abc." + signer.sign("session_123_abc")},
+	}
+	for _, tt := range tests {
+		if _, err := signer.Verify(tt.token); err != ErrInvalidToken {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", tt.name, err)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 import { useState, useEffect, useRef, useCallback } from 'react'
-import api from '../services/api'
+import api, { ensureSession } from '../services/api'
 import type { 
   Message, 
   AICharacter, 
@@ -65,26 +65,12 @@ const Icons = {
 }
 
 
-// 生成会话ID
-const generateSessionId = (): string => {
-  return `session_${Date.now()}_${Math.random().toString(36).substring(2, 11)}`
-}
-
-// 从localStorage获取或创建会话ID
-const getOrCreateSessionId = (): string => {
-  const stored = localStorage.getItem('chat_session_id')
-  if (stored) return stored
-  const newId = generateSessionId()
-  localStorage.setItem('chat_session_id', newId)
-  return newId
-}
-
 export default function Chat() {
   // 状态
   const [messages, setMessages] = useState<Message[]>([])
   const [input, setInput] = useState('')
   const [isLoading, setIsLoading] = useState(false)
-  const [sessionId] = useState(getOrCreateSessionId)
+  const [sessionId, setSessionId] = useState('')
   
   // 对话管理
   const [conversations, setConversations] = useState<Conversation[]>([])
@@ -107,10 +93,18 @@ export default function Chat() {
   const messagesEndRef = useRef<HTMLDivElement>(null)
   const inputRef = useRef<HTMLTextAreaElement>(null)
 
+  // 获取访客会话
+  useEffect(() => {
+    ensureSession()
+      .then(setSessionId)
+      .catch(() => setError('获取会话失败，请刷新页面重试'))
+  }, [])
+
   // 加载对话列表
   const loadConversations = useCallback(async () => {
+    if (!sessionId) return
     try {
-      const data = await api.get(`/conversations?session_id=${sessionId}`) as ConversationsResponse
+      const data = await api.get('/conversations') as ConversationsResponse
       setConversations(data.conversations || [])
     } catch (err) {
       console.error('加载对话列表失败:', err)
@@ -229,7 +223,7 @@ export default function Chat() {
 
   // 发送消息
   const handleSend = async () => {
-    if (!input.trim() || isLoading) return
+    if (!input.trim() || isLoading || !sessionId) return
 
     const userMessage: Message = {
       role: 'user',
@@ -247,7 +241,6 @@ export default function Chat() {
         message: userMessage.content,
         character_id: selectedCharacter?.id || 0,
         provider: selectedModel?.name || '',
-        session_id: sessionId,
         conversation_id: currentConversation?.id || 0,
       }) as ChatResponse
 
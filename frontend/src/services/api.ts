@@ -1,4 +1,5 @@
 import axios, { AxiosError, AxiosRequestConfig } from 'axios'
+import type { SessionResponse } from '../types/chat'
 
 // API错误类型
 interface APIError {
@@ -7,6 +8,10 @@ interface APIError {
   details?: string
 }
 
+// 访客匿名会话，令牌由服务端签发，聊天和对话接口需要携带
+const SESSION_ID_KEY = 'chat_session_id'
+const SESSION_TOKEN_KEY = 'chat_session_token'
+
 // 创建axios实例
 const axiosInstance = axios.create({
   baseURL: '/api/v1',
@@ -23,6 +28,10 @@ axiosInstance.interceptors.request.use(
     if (token) {
       config.headers.Authorization = `Bearer ${token}`
     }
+    const sessionToken = localStorage.getItem(SESSION_TOKEN_KEY)
+    if (sessionToken) {
+      config.headers['X-Session-Token'] = sessionToken
+    }
     return config
   },
   (error) => {
@@ -37,9 +46,11 @@ axiosInstance.interceptors.response.use(
     // 处理401未授权
     if (error.response?.status === 401) {
       localStorage.removeItem('token')
-      // 只在非聊天页面跳转
+      // 只在非聊天页面跳转，聊天页面清除失效的会话令牌，刷新后重新获取
       if (!window.location.pathname.includes('/chat')) {
         window.location.href = '/admin'
+      } else {
+        localStorage.removeItem(SESSION_TOKEN_KEY)
       }
     }
 
@@ -75,4 +86,16 @@ const api = {
   },
 }
 
+// 获取访客会话ID，本地没有会话令牌时向服务端申请
+export const ensureSession = async (): Promise<string> => {
+  const sessionId = localStorage.getItem(SESSION_ID_KEY)
+  if (sessionId && localStorage.getItem(SESSION_TOKEN_KEY)) {
+    return sessionId
+  }
+  const data = await api.post<SessionResponse>('/session')
+  localStorage.setItem(SESSION_ID_KEY, data.session_id)
+  localStorage.setItem(SESSION_TOKEN_KEY, data.session_token)
+  return data.session_id
+}
+
 export default api
@@ -34,12 +34,17 @@ export interface AIModel {
   display_name: string
 }
 
-// 聊天请求
+// 访客匿名会话
+export interface SessionResponse {
+  session_id: string
+  session_token: string
+}
+
+// 聊天请求，会话由请求头中的会话令牌确定
 export interface ChatRequest {
   message: string
   character_id?: number
   provider?: string
-  session_id: string
   conversation_id?: number
 }
 